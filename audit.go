@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type AuditSettings struct {
+	// File is the JSON Lines audit log; empty disables auditing.
+	File string `yaml:"file"`
+	// MaxSizeMB rotates the file once it would grow past this size; 0
+	// never rotates.
+	MaxSizeMB int `yaml:"max_size_mb"`
+	// MaxBackups is how many rotated files to keep; 0 keeps them all.
+	MaxBackups int `yaml:"max_backups"`
+	// Redact are regular expressions whose matches in queries and URLs
+	// are replaced by [redacted].
+	Redact []string `yaml:"redact"`
+}
+
+// auditRotatedLayout is the time suffix of rotated audit files, e.g.
+// audit.jsonl.20261018T173540.123. Files rotated within the same
+// millisecond get a counter too, e.g. audit.jsonl.20261018T173540.123-1.
+const auditRotatedLayout = "20060102T150405.000"
+
+// AuditRecord is one line of the audit log: a tool call, who made it and
+// what came of it.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	Client     string    `json:"client"`
+	Tool       string    `json:"tool"`
+	Query      string    `json:"query,omitempty"`
+	Queries    []string  `json:"queries,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Status     string    `json:"status"` // ok or error
+	ErrorCode  ErrorCode `json:"error_code,omitempty"`
+	Results    *int      `json:"results,omitempty"`
+	Upstream   int64     `json:"upstream_requests"`
+	Bytes      int64     `json:"bytes_fetched"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// AuditLog appends a record of every tool call to a JSON Lines file,
+// rotating it by size. Records are only ever appended; rotated files are
+// left alone except for pruning past MaxBackups.
+type AuditLog struct {
+	mu       sync.Mutex
+	settings AuditSettings
+	redact   []*regexp.Regexp
+	file     *os.File
+	size     int64
+}
+
+func NewAuditLog(settings AuditSettings) (*AuditLog, error) {
+	a := &AuditLog{}
+	commit, _, err := a.PrepareReconfigure(settings)
+	if err != nil {
+		return nil, err
+	}
+	commit()
+	return a, nil
+}
+
+// PrepareReconfigure compiles new settings and opens the new file, if
+// it's a different one. commit switches to them, closing the old file;
+// abort closes the new file instead. Until either is called the
+// previous settings stay in effect.
+func (a *AuditLog) PrepareReconfigure(settings AuditSettings) (commit, abort func(), err error) {
+	redact, err := compileRedactions(settings.Redact)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a.mu.Lock()
+	reopen := settings.File != a.settings.File
+	a.mu.Unlock()
+	var file *os.File
+	var size int64
+	if reopen && settings.File != "" {
+		if file, size, err = openAuditFile(settings.File); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	commit = func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if reopen {
+			if a.file != nil {
+				a.file.Close()
+			}
+			a.file, a.size = file, size
+		}
+		a.settings, a.redact = settings, redact
+	}
+	abort = func() {
+		if file != nil {
+			file.Close()
+		}
+	}
+	return commit, abort, nil
+}
+
+func compileRedactions(patterns []string) ([]*regexp.Regexp, error) {
+	var redact []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %q: %w", pattern, err)
+		}
+		redact = append(redact, re)
+	}
+	return redact, nil
+}
+
+func openAuditFile(path string) (*os.File, int64, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, 0, fmt.Errorf("audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("audit log: %w", err)
+	}
+	return file, info.Size(), nil
+}
+
+func (a *AuditLog) enabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file != nil
+}
+
+// write redacts record and appends it. Failures are logged rather than
+// failing the tool call.
+func (a *AuditLog) write(record *AuditRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return
+	}
+
+	record.Query = a.redactText(record.Query)
+	for i := range record.Queries {
+		record.Queries[i] = a.redactText(record.Queries[i])
+	}
+	record.URL = a.redactText(record.URL)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(record)
+	data := buf.Bytes()
+
+	if limit := int64(a.settings.MaxSizeMB) << 20; limit > 0 && a.size > 0 && a.size+int64(len(data)) > limit {
+		if err := a.rotate(); err != nil {
+			log.Printf("warning: failed to rotate the audit log: %v", err)
+			if a.file == nil {
+				return
+			}
+		}
+	}
+	n, err := a.file.Write(data)
+	a.size += int64(n)
+	if err != nil {
+		log.Printf("warning: failed to write the audit log: %v", err)
+	}
+}
+
+func (a *AuditLog) redactText(s string) string {
+	for _, re := range a.redact {
+		s = re.ReplaceAllString(s, "[redacted]")
+	}
+	return s
+}
+
+// rotate renames the file with a timestamp suffix and starts a new one.
+// The caller holds a.mu.
+func (a *AuditLog) rotate() error {
+	path := a.settings.File
+	a.file.Close()
+	renameErr := os.Rename(path, backupName(path, time.Now()))
+
+	// Keep appending to the same path either way
+	file, size, err := openAuditFile(path)
+	a.file, a.size = file, size
+	if err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	return a.pruneBackups()
+}
+
+// backupName picks the name to rotate path to at now, adding a counter
+// if a file rotated in the same millisecond already has the name.
+func backupName(path string, now time.Time) string {
+	name := path + "." + now.UTC().Format(auditRotatedLayout)
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(name); errors.Is(err, fs.ErrNotExist) {
+			return name
+		}
+		name = fmt.Sprintf("%s.%s-%d", path, now.UTC().Format(auditRotatedLayout), n)
+	}
+}
+
+// auditBackup is a rotated audit file, ordered by when it was rotated.
+type auditBackup struct {
+	name    string
+	rotated time.Time
+	n       int
+}
+
+// pruneBackups deletes the oldest rotated files past MaxBackups.
+func (a *AuditLog) pruneBackups() error {
+	if a.settings.MaxBackups <= 0 {
+		return nil
+	}
+	path := a.settings.File
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return err
+	}
+	var backups []auditBackup
+	for _, match := range matches {
+		stamp, counter, hasCounter := strings.Cut(strings.TrimPrefix(match, path+"."), "-")
+		rotated, err := time.Parse(auditRotatedLayout, stamp)
+		if err != nil {
+			continue
+		}
+		n := 0
+		if hasCounter {
+			if n, err = strconv.Atoi(counter); err != nil || n < 1 {
+				continue
+			}
+		}
+		backups = append(backups, auditBackup{match, rotated, n})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].rotated.Equal(backups[j].rotated) {
+			return backups[i].rotated.Before(backups[j].rotated)
+		}
+		return backups[i].n < backups[j].n
+	})
+	for len(backups) > a.settings.MaxBackups {
+		if err := os.Remove(backups[0].name); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// callStats counts what one tool call fetched upstream, for its audit
+// record. usageTransport updates it.
+type callStats struct {
+	upstream atomic.Int64
+	bytes    atomic.Int64
+}
+
+type callStatsKey struct{}
+
+// Middleware is MCP middleware that records every tool call, including
+// those refused for scope or quota, in the audit log.
+func (a *AuditLog) Middleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if method != "tools/call" || !a.enabled() {
+			return next(ctx, method, req)
+		}
+
+		stats := &callStats{}
+		start := time.Now()
+		result, err := next(context.WithValue(ctx, callStatsKey{}, stats), method, req)
+
+		params := req.(*mcp.CallToolRequest).Params
+		record := &AuditRecord{
+			Time:       start.UTC(),
+			Client:     clientIdentity(req),
+			Tool:       params.Name,
+			Upstream:   stats.upstream.Load(),
+			Bytes:      stats.bytes.Load(),
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		record.setArguments(params.Arguments)
+		record.setResult(result, err)
+		a.write(record)
+		return result, err
+	}
+}
+
+// setArguments records what the call looked up: its query, queries or
+// URL (url_read's may be inside a cursor).
+func (r *AuditRecord) setArguments(raw json.RawMessage) {
+	var args struct {
+		Query   string   `json:"query"`
+		Queries []string `json:"queries"`
+		URL     string   `json:"url"`
+		Cursor  string   `json:"cursor"`
+	}
+	_ = json.Unmarshal(raw, &args)
+	r.Query, r.Queries, r.URL = args.Query, args.Queries, args.URL
+	if r.URL == "" && args.Cursor != "" {
+		if cursor, err := decodeReadCursor(args.Cursor); err == nil {
+			r.URL = cursor.URL
+		}
+	}
+}
+
+// setResult records the status, error code and result count from the
+// call's structured output.
+func (r *AuditRecord) setResult(result mcp.Result, err error) {
+	r.Status = "ok"
+	callResult, _ := result.(*mcp.CallToolResult)
+	if err != nil || callResult == nil {
+		r.Status, r.ErrorCode = "error", ErrInternal
+		return
+	}
+	if callResult.IsError {
+		r.Status = "error"
+	}
+	if callResult.StructuredContent == nil {
+		return
+	}
+
+	data, _ := json.Marshal(callResult.StructuredContent)
+	var out struct {
+		Results     []json.RawMessage `json:"results"`
+		Suggestions []json.RawMessage `json:"suggestions"`
+		Error       *ToolError        `json:"error"`
+	}
+	if json.Unmarshal(data, &out) != nil {
+		return
+	}
+	if out.Error != nil {
+		r.Status, r.ErrorCode = "error", out.Error.Code
+		return
+	}
+	count := len(out.Results) + len(out.Suggestions)
+	r.Results = &count
+}
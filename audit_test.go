@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newTestAuditLog(t *testing.T, settings AuditSettings) *AuditLog {
+	t.Helper()
+	if settings.File == "" {
+		settings.File = filepath.Join(t.TempDir(), "audit.jsonl")
+	}
+	a, err := NewAuditLog(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.file.Close() })
+	return a
+}
+
+func readAuditRecords(t *testing.T, path string) []AuditRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 2<<20)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("%s: bad line: %v", path, err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// writeLargeRecords writes n records of about 300KB each, so a 1MB
+// audit log rotates every three records. Record i's tool is "tool-i".
+func writeLargeRecords(a *AuditLog, n int) {
+	filler := strings.Repeat("x", 300<<10)
+	for i := range n {
+		a.write(&AuditRecord{Tool: fmt.Sprintf("tool-%d", i+1), Query: filler, Status: "ok"})
+	}
+}
+
+// auditFiles returns the rotated files of the audit log at path, oldest
+// first, followed by the current one.
+func auditFiles(t *testing.T, path string) []string {
+	t.Helper()
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(backups, path)
+}
+
+func TestAuditRotation(t *testing.T) {
+	a := newTestAuditLog(t, AuditSettings{MaxSizeMB: 1})
+	writeLargeRecords(a, 10)
+
+	// Records 1-3, 4-6 and 7-9 were rotated out, even if that happened
+	// within a millisecond, and none was lost
+	files := auditFiles(t, a.settings.File)
+	if len(files) != 4 {
+		t.Fatalf("got files %v, want 3 rotated ones and the current one", files)
+	}
+	var tools []string
+	for _, file := range files {
+		info, _ := os.Stat(file)
+		if info.Size() > 1<<20 {
+			t.Errorf("%s is %d bytes, over the 1MB limit", file, info.Size())
+		}
+		for _, r := range readAuditRecords(t, file) {
+			tools = append(tools, r.Tool)
+		}
+	}
+	if len(tools) != 10 || tools[0] != "tool-1" || tools[9] != "tool-10" {
+		t.Errorf("records across the files = %v, want tool-1 to tool-10 in order", tools)
+	}
+}
+
+func TestAuditPruneBackups(t *testing.T) {
+	a := newTestAuditLog(t, AuditSettings{MaxSizeMB: 1, MaxBackups: 2})
+	// Not a backup, so never pruned
+	notBackup := a.settings.File + ".bak"
+	os.WriteFile(notBackup, nil, 0o600)
+	writeLargeRecords(a, 10)
+
+	files := slices.DeleteFunc(auditFiles(t, a.settings.File), func(f string) bool { return f == notBackup })
+	if len(files) != 3 {
+		t.Fatalf("got files %v, want 2 rotated ones and the current one", files)
+	}
+	if first := readAuditRecords(t, files[0]); first[0].Tool != "tool-4" {
+		t.Errorf("oldest kept backup starts with %s, want tool-4", first[0].Tool)
+	}
+	if _, err := os.Stat(notBackup); err != nil {
+		t.Errorf("a file that isn't a backup was removed: %v", err)
+	}
+}
+
+func TestAuditBackupNames(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+	now := time.Date(2026, 10, 18, 17, 35, 40, 123e6, time.UTC)
+
+	var names []string
+	for range 3 {
+		name := backupName(path, now)
+		os.WriteFile(name, nil, 0o600)
+		names = append(names, filepath.Base(name))
+	}
+	want := []string{"audit.jsonl.20261018T173540.123", "audit.jsonl.20261018T173540.123-1", "audit.jsonl.20261018T173540.123-2"}
+	if !slices.Equal(names, want) {
+		t.Errorf("backup names = %v, want %v", names, want)
+	}
+
+	// Counters sort numerically, after the backups of earlier times
+	for _, suffix := range []string{"20261018T173539.999", "20261018T173540.123-10"} {
+		os.WriteFile(path+"."+suffix, nil, 0o600)
+	}
+	a := &AuditLog{settings: AuditSettings{File: path, MaxBackups: 2}}
+	if err := a.pruneBackups(); err != nil {
+		t.Fatal(err)
+	}
+	left, _ := filepath.Glob(path + ".*")
+	for i := range left {
+		left[i] = filepath.Base(left[i])
+	}
+	if want := []string{"audit.jsonl.20261018T173540.123-10", "audit.jsonl.20261018T173540.123-2"}; !slices.Equal(left, want) {
+		t.Errorf("kept %v, want %v", left, want)
+	}
+}
+
+// callTool calls handler with a tools/call request from client.
+func callTool(t *testing.T, handler mcp.MethodHandler, client string, scopes []string, tool string, args any) *mcp.CallToolResult {
+	t.Helper()
+	raw, _ := json.Marshal(args)
+	req := &mcp.CallToolRequest{
+		Params: &mcp.CallToolParamsRaw{Name: tool, Arguments: raw},
+		Extra:  &mcp.RequestExtra{TokenInfo: &auth.TokenInfo{Scopes: scopes, Extra: map[string]any{"subject": client}}},
+	}
+	result, err := handler(context.Background(), "tools/call", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.(*mcp.CallToolResult)
+}
+
+func TestAuditRedaction(t *testing.T) {
+	a := newTestAuditLog(t, AuditSettings{Redact: []string{`api_key=[^&\s]+`, `\b\d{3}-\d{2}-\d{4}\b`}})
+	handler := a.Middleware(func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		return &mcp.CallToolResult{}, nil
+	})
+	all := []string{allToolsScope}
+
+	callTool(t, handler, "alice", all, "web_search", WebSearchArgs{Query: "records for 123-45-6789"})
+	callTool(t, handler, "alice", all, "multi_search", MultiSearchArgs{Queries: []string{"ssn 123-45-6789", "plain"}})
+	callTool(t, handler, "alice", all, "url_read", URLReadArgs{URL: "https://api.example.com/v1?api_key=s3cret&q=1"})
+	cursor := encodeReadCursor(&readCursor{URL: "https://api.example.com/doc?api_key=s3cret", Offset: 100})
+	callTool(t, handler, "alice", all, "url_read", URLReadArgs{Cursor: cursor})
+
+	records := readAuditRecords(t, a.settings.File)
+	if len(records) != 4 {
+		t.Fatalf("got %d records, want 4", len(records))
+	}
+	if records[0].Query != "records for [redacted]" {
+		t.Errorf("query = %q", records[0].Query)
+	}
+	if want := []string{"ssn [redacted]", "plain"}; !slices.Equal(records[1].Queries, want) {
+		t.Errorf("queries = %q, want %q", records[1].Queries, want)
+	}
+	if records[2].URL != "https://api.example.com/v1?[redacted]&q=1" {
+		t.Errorf("url = %q", records[2].URL)
+	}
+	if records[3].URL != "https://api.example.com/doc?[redacted]" {
+		t.Errorf("url from the cursor = %q", records[3].URL)
+	}
+	data, _ := os.ReadFile(a.settings.File)
+	if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), "6789") {
+		t.Errorf("the audit log holds redacted text:\n%s", data)
+	}
+}
+
+func TestAuditRecordsRefusedCalls(t *testing.T) {
+	a := newTestAuditLog(t, AuditSettings{})
+	usage := NewUsage(QuotaSettings{QuotaLimits: QuotaLimits{CallsPerDay: 1}})
+	tool := func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		output := &WebSearchOutput{Query: "q", Results: []RankedResult{{Title: "a"}, {Title: "b"}}}
+		return &mcp.CallToolResult{StructuredContent: output}, nil
+	}
+	// The same order as the server's middleware
+	handler := a.Middleware(requireScopes(usage.Middleware(tool)))
+	scopes := []string{"tools:web_search"}
+
+	if result := callTool(t, handler, "alice", scopes, "url_read", URLReadArgs{URL: "https://example.com/"}); !result.IsError {
+		t.Error("url_read without its scope succeeded")
+	}
+	if result := callTool(t, handler, "alice", scopes, "web_search", WebSearchArgs{Query: "first"}); result.IsError {
+		t.Error("first web_search failed")
+	}
+	if result := callTool(t, handler, "alice", scopes, "web_search", WebSearchArgs{Query: "second"}); !result.IsError {
+		t.Error("web_search past the quota succeeded")
+	}
+
+	records := readAuditRecords(t, a.settings.File)
+	want := []struct {
+		tool, status string
+		code         ErrorCode
+	}{
+		{"url_read", "error", ErrPermissionDenied},
+		{"web_search", "ok", ""},
+		{"web_search", "error", ErrQuotaExceeded},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i, w := range want {
+		r := records[i]
+		if r.Client != "alice" || r.Tool != w.tool || r.Status != w.status || r.ErrorCode != w.code {
+			t.Errorf("record %d = %+v, want %s %s %s", i+1, r, w.tool, w.status, w.code)
+		}
+	}
+	if records[0].URL != "https://example.com/" || records[2].Query != "second" {
+		t.Errorf("refused calls' arguments weren't recorded: %+v, %+v", records[0], records[2])
+	}
+	if records[1].Results == nil || *records[1].Results != 2 {
+		t.Errorf("result count = %v, want 2", records[1].Results)
+	}
+}
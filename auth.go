@@ -0,0 +1,614 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512" // registers SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+// AuthSettings configures authentication of MCP clients in HTTP mode.
+// Any combination of the three token kinds can be enabled.
+type AuthSettings struct {
+	// TokensFile lists static bearer tokens and their scopes.
+	TokensFile string `yaml:"tokens_file"`
+	// HMACSecret verifies HS256/HS384/HS512-signed JWTs.
+	HMACSecret string `yaml:"hmac_secret"`
+	// JWKSFile holds the public keys that verify RS*/ES*-signed JWTs
+	// issued by an OAuth 2.0 authorization server.
+	JWKSFile string `yaml:"jwks_file"`
+	// Issuer and Audience, if set, must match a JWT's iss and aud.
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+func (s AuthSettings) enabled() bool {
+	return s.TokensFile != "" || s.HMACSecret != "" || s.JWKSFile != ""
+}
+
+// Scopes are "tools:<name>" for one tool or "tools:*" for all of them,
+// and "resources:<scheme>" for one resource (e.g. resources:usage for
+// usage://mcp-searxng) or "resources:*" for all of them. The cache stats
+// at /cachez are treated as a resource too.
+const (
+	allToolsScope     = "tools:*"
+	allResourcesScope = "resources:*"
+	cacheStatsScope   = "resources:cache"
+)
+
+// toolAllowed reports whether scopes grant calling the tool.
+func toolAllowed(scopes []string, tool string) bool {
+	return slices.Contains(scopes, allToolsScope) || slices.Contains(scopes, "tools:"+tool)
+}
+
+// resourceScope is the scope that grants reading the resource at uri.
+func resourceScope(uri string) string {
+	scheme, _, _ := strings.Cut(uri, "://")
+	return "resources:" + scheme
+}
+
+// resourceAllowed reports whether scopes grant reading the resource.
+func resourceAllowed(scopes []string, uri string) bool {
+	return slices.Contains(scopes, allResourcesScope) || slices.Contains(scopes, resourceScope(uri))
+}
+
+// staticToken is an entry of the tokens file.
+type staticToken struct {
+	Name    string    `yaml:"name"`
+	Token   string    `yaml:"token"`
+	SHA256  string    `yaml:"sha256"` // hex SHA-256 of the token, instead of the token itself
+	Scopes  []string  `yaml:"scopes"`
+	Expires time.Time `yaml:"expires"`
+}
+
+// authKeys is everything tokens are checked against. A reload replaces
+// it as a whole.
+type authKeys struct {
+	enabled    bool
+	tokens     map[[32]byte]staticToken // by SHA-256 of the token
+	hmacSecret []byte
+	jwks       []jsonWebKey
+	jwksFile   string
+	issuer     string
+	audience   string
+}
+
+// Authenticator verifies the bearer tokens of MCP clients.
+type Authenticator struct {
+	mu   sync.RWMutex
+	keys *authKeys
+
+	// refreshMu serializes JWKS refreshes; lastRefresh rate-limits them
+	refreshMu   sync.Mutex
+	lastRefresh time.Time
+}
+
+func NewAuthenticator(settings AuthSettings) (*Authenticator, error) {
+	keys, err := loadAuthKeys(settings)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{keys: keys}, nil
+}
+
+// PrepareReconfigure re-reads the tokens and JWKS files and returns
+// commit, which switches to the new keys. Until then the current keys
+// stay in effect.
+func (a *Authenticator) PrepareReconfigure(settings AuthSettings) (commit func(), err error) {
+	keys, err := loadAuthKeys(settings)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.keys = keys
+	}, nil
+}
+
+func (a *Authenticator) current() *authKeys {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.keys
+}
+
+// minHMACSecret is the shortest HMAC secret accepted, in bytes.
+const minHMACSecret = 32
+
+func loadAuthKeys(settings AuthSettings) (*authKeys, error) {
+	keys := &authKeys{
+		enabled:  settings.enabled(),
+		issuer:   settings.Issuer,
+		audience: settings.Audience,
+	}
+
+	if settings.TokensFile != "" {
+		tokens, err := loadStaticTokens(settings.TokensFile)
+		if err != nil {
+			return nil, err
+		}
+		keys.tokens = tokens
+	}
+
+	if settings.HMACSecret != "" {
+		if len(settings.HMACSecret) < minHMACSecret {
+			return nil, fmt.Errorf("hmac_secret must be at least %d characters", minHMACSecret)
+		}
+		keys.hmacSecret = []byte(settings.HMACSecret)
+	}
+
+	if settings.JWKSFile != "" {
+		jwks, err := loadJWKS(settings.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keys.jwks = jwks
+		keys.jwksFile = settings.JWKSFile
+	}
+
+	return keys, nil
+}
+
+// loadStaticTokens reads a tokens file:
+//
+//	tokens:
+//	  - name: ci
+//	    sha256: 9f86d081884c7d65...   # or token: <the token itself>
+//	    scopes: [tools:web_search, tools:url_read]
+//	    expires: 2027-01-01T00:00:00Z # optional
+func loadStaticTokens(path string) (map[[32]byte]staticToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tokens file: %w", err)
+	}
+	var file struct {
+		Tokens []staticToken `yaml:"tokens"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("tokens file %s: %w", path, err)
+	}
+
+	tokens := make(map[[32]byte]staticToken)
+	for i, t := range file.Tokens {
+		var sum [32]byte
+		switch {
+		case t.Token != "" && t.SHA256 != "":
+			return nil, fmt.Errorf("tokens file %s: token %d (%s): set token or sha256, not both", path, i+1, t.Name)
+		case t.Token != "":
+			sum = sha256.Sum256([]byte(t.Token))
+		case t.SHA256 != "":
+			b, err := hex.DecodeString(t.SHA256)
+			if err != nil || len(b) != len(sum) {
+				return nil, fmt.Errorf("tokens file %s: token %d (%s): sha256 must be 64 hex digits", path, i+1, t.Name)
+			}
+			copy(sum[:], b)
+		default:
+			return nil, fmt.Errorf("tokens file %s: token %d (%s): token or sha256 is required", path, i+1, t.Name)
+		}
+		t.Token = ""
+		tokens[sum] = t
+	}
+	return tokens, nil
+}
+
+// Verify checks a bearer token. It implements auth.TokenVerifier.
+func (a *Authenticator) Verify(ctx context.Context, token string, req *http.Request) (*auth.TokenInfo, error) {
+	keys := a.current()
+
+	if strings.Count(token, ".") == 2 {
+		info, err := keys.verifyJWT(token)
+		if errors.Is(err, errUnknownKey) && a.refreshJWKS(keys) {
+			return a.current().verifyJWT(token)
+		}
+		return info, err
+	}
+
+	t, ok := keys.tokens[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown token", auth.ErrInvalidToken)
+	}
+	expires := t.Expires
+	if expires.IsZero() {
+		// The SDK requires an expiry; static tokens without one stay
+		// valid, so report one just ahead
+		expires = time.Now().Add(time.Hour)
+	}
+	return &auth.TokenInfo{
+		Scopes:     t.Scopes,
+		Expiration: expires,
+		Extra:      map[string]any{"subject": t.Name},
+	}, nil
+}
+
+// jwksRefreshInterval is the least time between re-reads of the JWKS
+// file for tokens signed with an unknown key, so tokens with made-up key
+// IDs can't make every request read it.
+const jwksRefreshInterval = time.Minute
+
+// refreshJWKS re-reads the JWKS file after a token named a key that stale
+// doesn't have, so a key the authorization server rotated in is accepted
+// before the next reload. It reports whether the keys may have changed.
+func (a *Authenticator) refreshJWKS(stale *authKeys) bool {
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+
+	if a.current() != stale {
+		return true
+	}
+	if stale.jwksFile == "" || time.Since(a.lastRefresh) < jwksRefreshInterval {
+		return false
+	}
+	a.lastRefresh = time.Now()
+
+	jwks, err := loadJWKS(stale.jwksFile)
+	if err != nil {
+		log.Printf("warning: re-reading the JWKS file for an unknown key: %v", err)
+		return false
+	}
+	keys := *stale
+	keys.jwks = jwks
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.keys == stale {
+		a.keys = &keys
+	}
+	return true
+}
+
+// Middleware requires a valid bearer token on every request to h, if
+// authentication is configured. It's checked per request, so a reload
+// can turn authentication on.
+func (a *Authenticator) Middleware(h http.Handler) http.Handler {
+	protected := auth.RequireBearerToken(a.Verify, nil)(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.current().enabled {
+			h.ServeHTTP(w, r)
+			return
+		}
+		protected.ServeHTTP(w, r)
+	})
+}
+
+// requireHTTPScope only lets a request through to h if its token has
+// one of scopes. It goes inside Middleware, which verified the token;
+// without authentication there's no token and nothing is restricted.
+func requireHTTPScope(h http.Handler, scopes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := auth.TokenInfoFromContext(r.Context())
+		if info != nil && !slices.ContainsFunc(scopes, func(s string) bool { return slices.Contains(info.Scopes, s) }) {
+			http.Error(w, "insufficient scope: this token needs one of "+strings.Join(scopes, ", "), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// jwtClaims are the JWT claims we check.
+type jwtClaims struct {
+	Issuer    string      `json:"iss"`
+	Subject   string      `json:"sub"`
+	Audience  flexStrings `json:"aud"`
+	Expires   float64     `json:"exp"`
+	NotBefore float64     `json:"nbf"`
+	Scope     string      `json:"scope"` // space-separated, per RFC 8693
+	Scp       flexStrings `json:"scp"`   // list, as some servers issue
+}
+
+// verifyJWT checks a JWT's signature (HMAC with the shared secret, or
+// RSA/ECDSA with a key from the JWKS) and its claims.
+func (keys *authKeys) verifyJWT(token string) (*auth.TokenInfo, error) {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", auth.ErrInvalidToken, fmt.Sprintf(format, args...))
+	}
+
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, invalid("malformed JWT header")
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, invalid("malformed JWT claims")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("malformed JWT signature")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch {
+	case strings.HasPrefix(header.Alg, "HS"):
+		if keys.hmacSecret == nil {
+			return nil, invalid("HMAC-signed tokens are not accepted")
+		}
+		hashAlg, ok := jwtHashes[header.Alg]
+		if !ok {
+			return nil, invalid("unsupported algorithm %q", header.Alg)
+		}
+		mac := hmac.New(hashAlg.New, keys.hmacSecret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, invalid("bad signature")
+		}
+	case strings.HasPrefix(header.Alg, "RS"), strings.HasPrefix(header.Alg, "ES"):
+		if len(keys.jwks) == 0 {
+			return nil, invalid("tokens signed with %s are not accepted", header.Alg)
+		}
+		if !keys.hasKey(header.Kid) {
+			return nil, fmt.Errorf("%w: %w %q", auth.ErrInvalidToken, errUnknownKey, header.Kid)
+		}
+		if !keys.verifyWithJWKS(header.Alg, header.Kid, signed, signature) {
+			return nil, invalid("bad signature or wrong algorithm for key %q", header.Kid)
+		}
+	default:
+		return nil, invalid("unsupported algorithm %q", header.Alg)
+	}
+
+	now := float64(time.Now().Unix())
+	switch {
+	case claims.Expires == 0:
+		return nil, invalid("token has no exp claim")
+	case claims.Expires < now:
+		return nil, invalid("token expired")
+	case claims.NotBefore > now:
+		return nil, invalid("token not valid yet")
+	case keys.issuer != "" && claims.Issuer != keys.issuer:
+		return nil, invalid("issuer %q is not %q", claims.Issuer, keys.issuer)
+	case keys.audience != "" && !slices.Contains(claims.Audience, keys.audience):
+		return nil, invalid("token is not for audience %q", keys.audience)
+	}
+
+	scopes := append(strings.Fields(claims.Scope), claims.Scp...)
+	return &auth.TokenInfo{
+		Scopes:     scopes,
+		Expiration: time.Unix(int64(claims.Expires), 0),
+		Extra:      map[string]any{"subject": claims.Subject},
+	}, nil
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "RS256": crypto.SHA256, "ES256": crypto.SHA256,
+	"HS384": crypto.SHA384, "RS384": crypto.SHA384, "ES384": crypto.SHA384,
+	"HS512": crypto.SHA512, "RS512": crypto.SHA512, "ES512": crypto.SHA512,
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// errUnknownKey reports a JWT whose kid isn't in the JWKS.
+var errUnknownKey = errors.New("unknown key")
+
+// hasKey reports whether the JWKS has a key with the ID kid. Tokens
+// without a kid are tried against every key.
+func (keys *authKeys) hasKey(kid string) bool {
+	return kid == "" || slices.ContainsFunc(keys.jwks, func(key jsonWebKey) bool { return key.Kid == kid })
+}
+
+func (keys *authKeys) verifyWithJWKS(alg, kid string, signed, signature []byte) bool {
+	hashAlg, ok := jwtHashes[alg]
+	if !ok {
+		return false
+	}
+	h := hashAlg.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	for _, key := range keys.jwks {
+		if kid != "" && key.Kid != kid || key.Alg != "" && key.Alg != alg {
+			continue
+		}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			if strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(pub, hashAlg, digest, signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			if strings.HasPrefix(alg, "ES") && len(signature) == 2*size {
+				r := new(big.Int).SetBytes(signature[:size])
+				s := new(big.Int).SetBytes(signature[size:])
+				if ecdsa.Verify(pub, digest, r, s) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// jsonWebKey is an RSA or EC public key from a JWKS (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	public crypto.PublicKey
+}
+
+func loadJWKS(path string) ([]jsonWebKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS file: %w", err)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("JWKS file %s: %w", path, err)
+	}
+
+	var keys []jsonWebKey
+	for i, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		pub, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS file %s: key %d (kid %q): %w", path, i+1, key.Kid, err)
+		}
+		key.public = pub
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS file %s: no signing keys", path)
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(field, value string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("invalid %q", field)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// signHMACToken mints an HS256 JWT for subject with scopes, valid for ttl.
+// It carries the configured issuer and audience so the server accepts it.
+func signHMACToken(settings AuthSettings, subject string, scopes []string, ttl time.Duration) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	fields := map[string]any{
+		"sub":   subject,
+		"scope": strings.Join(scopes, " "),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(ttl).Unix(),
+	}
+	if settings.Issuer != "" {
+		fields["iss"] = settings.Issuer
+	}
+	if settings.Audience != "" {
+		fields["aud"] = settings.Audience
+	}
+	claims, _ := json.Marshal(fields)
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(sha256.New, []byte(settings.HMACSecret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// requireScopes is MCP middleware that only lets a client call, and see
+// in tools/list, the tools its token is scoped for, and likewise read and
+// list resources. Requests without a token (stdio) aren't restricted.
+func requireScopes(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		extra := req.GetExtra()
+		if extra == nil || extra.TokenInfo == nil {
+			return next(ctx, method, req)
+		}
+		scopes := extra.TokenInfo.Scopes
+
+		switch method {
+		case "tools/call":
+			name := req.(*mcp.CallToolRequest).Params.Name
+			if !toolAllowed(scopes, name) {
+				terr := newToolError(ErrPermissionDenied, nil, "this token may not call %s", name)
+				result := toolErrorResult("", terr)
+				result.StructuredContent = &ErrorOutput{Error: terr}
+				return result, nil
+			}
+		case "tools/list":
+			result, err := next(ctx, method, req)
+			if list, ok := result.(*mcp.ListToolsResult); ok && err == nil {
+				list.Tools = slices.DeleteFunc(list.Tools, func(t *mcp.Tool) bool {
+					return !toolAllowed(scopes, t.Name)
+				})
+			}
+			return result, err
+		case "resources/read":
+			uri := req.(*mcp.ReadResourceRequest).Params.URI
+			if !resourceAllowed(scopes, uri) {
+				return nil, newToolError(ErrPermissionDenied, nil, "this token may not read %s; it needs the %s or %s scope",
+					uri, resourceScope(uri), allResourcesScope)
+			}
+		case "resources/list":
+			result, err := next(ctx, method, req)
+			if list, ok := result.(*mcp.ListResourcesResult); ok && err == nil {
+				list.Resources = slices.DeleteFunc(list.Resources, func(r *mcp.Resource) bool {
+					return !resourceAllowed(scopes, r.URI)
+				})
+			}
+			return result, err
+		}
+		return next(ctx, method, req)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const testHMACSecret = "0123456789abcdef0123456789abcdef"
+
+// testKeys are the signing keys behind a test JWKS.
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{rsa: rsaKey, ec: ecKey}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "alg": "RS256", "use": "sig",
+		"n": b64(key.N.Bytes()), "e": b64([]byte{1, 0, 1})}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "alg": "ES256", "crv": "P-256",
+		"x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32)))}
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	t.Helper()
+	data, _ := json.Marshal(map[string]any{"keys": keys})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// makeJWT signs header and claims with sign, which gets the signing input.
+func makeJWT(header, claims map[string]any, sign func(signed []byte) []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := b64(h) + "." + b64(c)
+	return signed + "." + b64(sign([]byte(signed)))
+}
+
+func signHS256(secret []byte) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+func signRS256(key *rsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		return sig
+	}
+}
+
+func signES256(key *ecdsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+}
+
+func unsigned([]byte) []byte { return nil }
+
+// validClaims are accepted by an authenticator with the issuer and
+// audience of newTestAuthenticator.
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":   "alice",
+		"iss":   "https://auth.example.com/",
+		"aud":   "mcp-searxng",
+		"scope": "tools:web_search resources:usage",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func newTestAuthenticator(t *testing.T, settings AuthSettings) *Authenticator {
+	t.Helper()
+	settings.Issuer = "https://auth.example.com/"
+	settings.Audience = "mcp-searxng"
+	a, err := NewAuthenticator(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestVerifyJWT(t *testing.T) {
+	keys := newTestKeys(t)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwksFile, rsaJWK("rsa-1", keys.rsa), ecJWK("ec-1", keys.ec))
+	a := newTestAuthenticator(t, AuthSettings{HMACSecret: testHMACSecret, JWKSFile: jwksFile})
+
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: must(x509.MarshalPKIXPublicKey(&keys.rsa.PublicKey))})
+	with := func(change func(claims map[string]any)) map[string]any {
+		claims := validClaims()
+		change(claims)
+		return claims
+	}
+	header := func(alg, kid string) map[string]any {
+		h := map[string]any{"alg": alg, "typ": "JWT"}
+		if kid != "" {
+			h["kid"] = kid
+		}
+		return h
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  string // a substring of the error, or "" if it's accepted
+	}{
+		{"HS256", makeJWT(header("HS256", ""), validClaims(), signHS256([]byte(testHMACSecret))), ""},
+		{"RS256", makeJWT(header("RS256", "rsa-1"), validClaims(), signRS256(keys.rsa)), ""},
+		{"RS256 without kid", makeJWT(header("RS256", ""), validClaims(), signRS256(keys.rsa)), ""},
+		{"ES256", makeJWT(header("ES256", "ec-1"), validClaims(), signES256(keys.ec)), ""},
+		{"scp list", makeJWT(header("HS256", ""), with(func(c map[string]any) {
+			delete(c, "scope")
+			c["scp"] = []string{"tools:web_search"}
+		}), signHS256([]byte(testHMACSecret))), ""},
+
+		{"wrong secret", makeJWT(header("HS256", ""), validClaims(), signHS256([]byte(strings.Repeat("x", 32)))), "bad signature"},
+		{"tampered claims", func() string {
+			token := makeJWT(header("RS256", "rsa-1"), validClaims(), signRS256(keys.rsa))
+			parts := strings.Split(token, ".")
+			forged, _ := json.Marshal(with(func(c map[string]any) { c["scope"] = "tools:*" }))
+			return parts[0] + "." + b64(forged) + "." + parts[2]
+		}(), "bad signature"},
+		{"alg none", makeJWT(header("none", ""), validClaims(), unsigned), "unsupported algorithm"},
+		{"alg None", makeJWT(header("None", ""), validClaims(), unsigned), "unsupported algorithm"},
+		{"no alg", makeJWT(map[string]any{"typ": "JWT"}, validClaims(), unsigned), "unsupported algorithm"},
+		{"unsupported HMAC", makeJWT(header("HS1", ""), validClaims(), signHS256([]byte(testHMACSecret))), "unsupported algorithm"},
+		{"unknown kid", makeJWT(header("RS256", "rsa-2"), validClaims(), signRS256(keys.rsa)), "unknown key"},
+		{"kid of another key", makeJWT(header("RS256", "ec-1"), validClaims(), signRS256(keys.rsa)), "bad signature"},
+		{"alg the key isn't for", makeJWT(header("RS384", "rsa-1"), validClaims(), signRS256(keys.rsa)), "bad signature"},
+		{"ES256 signed by RSA", makeJWT(header("ES256", "ec-1"), validClaims(), signRS256(keys.rsa)), "bad signature"},
+		// Key confusion: an HMAC over the RSA public key must not pass
+		// as a signature by that key
+		{"HS256 with the RSA public key", makeJWT(header("HS256", "rsa-1"), validClaims(), signHS256(publicPEM)), "bad signature"},
+		{"HS256 with the RSA modulus", makeJWT(header("HS256", "rsa-1"), validClaims(), signHS256(keys.rsa.N.Bytes())), "bad signature"},
+
+		{"expired", makeJWT(header("HS256", ""), with(func(c map[string]any) { c["exp"] = time.Now().Add(-time.Minute).Unix() }),
+			signHS256([]byte(testHMACSecret))), "expired"},
+		{"no exp", makeJWT(header("HS256", ""), with(func(c map[string]any) { delete(c, "exp") }),
+			signHS256([]byte(testHMACSecret))), "no exp"},
+		{"not valid yet", makeJWT(header("HS256", ""), with(func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() }),
+			signHS256([]byte(testHMACSecret))), "not valid yet"},
+		{"wrong audience", makeJWT(header("HS256", ""), with(func(c map[string]any) { c["aud"] = []string{"other", "api"} }),
+			signHS256([]byte(testHMACSecret))), "audience"},
+		{"no audience", makeJWT(header("HS256", ""), with(func(c map[string]any) { delete(c, "aud") }),
+			signHS256([]byte(testHMACSecret))), "audience"},
+		{"wrong issuer", makeJWT(header("HS256", ""), with(func(c map[string]any) { c["iss"] = "https://evil.example.com/" }),
+			signHS256([]byte(testHMACSecret))), "issuer"},
+
+		{"malformed header", "e30x." + b64([]byte("{}")) + ".", "malformed JWT header"},
+		{"malformed signature", makeJWT(header("HS256", ""), validClaims(), unsigned) + "!", "malformed JWT signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := a.Verify(context.Background(), tt.token, nil)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if info.Extra["subject"] != "alice" || !slices.Contains(info.Scopes, "tools:web_search") {
+					t.Errorf("token info = %+v", info)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Verify accepted the token, want an error containing %q", tt.want)
+			}
+			if !errors.Is(err, auth.ErrInvalidToken) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Verify error = %v, want an invalid token error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyJWTOnlyAcceptsConfiguredKinds(t *testing.T) {
+	keys := newTestKeys(t)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwksFile, rsaJWK("rsa-1", keys.rsa))
+
+	// Without an HMAC secret, no HS* token is accepted, however signed
+	jwksOnly := newTestAuthenticator(t, AuthSettings{JWKSFile: jwksFile})
+	for _, secret := range [][]byte{keys.rsa.N.Bytes(), nil} {
+		token := makeJWT(map[string]any{"alg": "HS256", "kid": "rsa-1"}, validClaims(), signHS256(secret))
+		if _, err := jwksOnly.Verify(context.Background(), token, nil); err == nil || !strings.Contains(err.Error(), "HMAC-signed tokens are not accepted") {
+			t.Errorf("HS256 token without an HMAC secret: err = %v", err)
+		}
+	}
+
+	// Without a JWKS, no RS* or ES* token is
+	hmacOnly := newTestAuthenticator(t, AuthSettings{HMACSecret: testHMACSecret})
+	token := makeJWT(map[string]any{"alg": "RS256", "kid": "rsa-1"}, validClaims(), signRS256(keys.rsa))
+	if _, err := hmacOnly.Verify(context.Background(), token, nil); err == nil || !strings.Contains(err.Error(), "not accepted") {
+		t.Errorf("RS256 token without a JWKS: err = %v", err)
+	}
+}
+
+func TestJWKSRefreshOnUnknownKey(t *testing.T) {
+	keys := newTestKeys(t)
+	rotated := newTestKeys(t)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwksFile, rsaJWK("rsa-1", keys.rsa))
+	a := newTestAuthenticator(t, AuthSettings{JWKSFile: jwksFile})
+
+	// The authorization server rotates in a new key and signs with it
+	// before the file watcher reloads: the unknown kid re-reads the file
+	writeJWKS(t, jwksFile, rsaJWK("rsa-1", keys.rsa), rsaJWK("rsa-2", rotated.rsa))
+	token := makeJWT(map[string]any{"alg": "RS256", "kid": "rsa-2"}, validClaims(), signRS256(rotated.rsa))
+	if _, err := a.Verify(context.Background(), token, nil); err != nil {
+		t.Fatalf("token signed with the rotated key: %v", err)
+	}
+	old := makeJWT(map[string]any{"alg": "RS256", "kid": "rsa-1"}, validClaims(), signRS256(keys.rsa))
+	if _, err := a.Verify(context.Background(), old, nil); err != nil {
+		t.Errorf("token signed with the old key after the refresh: %v", err)
+	}
+
+	// A second refresh within the interval doesn't read the file, so
+	// made-up kids can't make every request read it
+	writeJWKS(t, jwksFile, rsaJWK("rsa-3", rotated.rsa))
+	token = makeJWT(map[string]any{"alg": "RS256", "kid": "rsa-3"}, validClaims(), signRS256(rotated.rsa))
+	if _, err := a.Verify(context.Background(), token, nil); !errors.Is(err, errUnknownKey) {
+		t.Errorf("refresh within %v: err = %v, want an unknown key error", jwksRefreshInterval, err)
+	}
+
+	// A bad signature by a known key doesn't refresh
+	a.lastRefresh = time.Time{}
+	forged := makeJWT(map[string]any{"alg": "RS256", "kid": "rsa-1"}, validClaims(), signRS256(rotated.rsa))
+	if _, err := a.Verify(context.Background(), forged, nil); err == nil {
+		t.Fatal("token signed with the wrong key was accepted")
+	}
+	if !a.lastRefresh.IsZero() {
+		t.Error("a bad signature re-read the JWKS file")
+	}
+}
+
+func TestJWKSRefreshKeepsKeysOnError(t *testing.T) {
+	keys := newTestKeys(t)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwksFile, rsaJWK("rsa-1", keys.rsa))
+	a := newTestAuthenticator(t, AuthSettings{JWKSFile: jwksFile})
+
+	if err := os.WriteFile(jwksFile, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	unknown := makeJWT(map[string]any{"alg": "RS256", "kid": "rsa-2"}, validClaims(), signRS256(keys.rsa))
+	if _, err := a.Verify(context.Background(), unknown, nil); err == nil {
+		t.Fatal("token with an unknown kid was accepted")
+	}
+	known := makeJWT(map[string]any{"alg": "RS256", "kid": "rsa-1"}, validClaims(), signRS256(keys.rsa))
+	if _, err := a.Verify(context.Background(), known, nil); err != nil {
+		t.Errorf("known key after a failed refresh: %v", err)
+	}
+}
+
+func TestStaticTokens(t *testing.T) {
+	dir := t.TempDir()
+	tokensFile := filepath.Join(dir, "tokens.yaml")
+	sum := sha256.Sum256([]byte("hashed-secret"))
+	expires := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	err := os.WriteFile(tokensFile, []byte(`tokens:
+  - name: ci
+    sha256: `+hex.EncodeToString(sum[:])+`
+    scopes: [tools:web_search]
+  - name: ops
+    token: plain-secret
+    scopes: ["tools:*", "resources:*"]
+  - name: old
+    token: expired-secret
+    expires: `+expires+`
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthenticator(AuthSettings{TokensFile: tokensFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for token, subject := range map[string]string{"hashed-secret": "ci", "plain-secret": "ops"} {
+		info, err := a.Verify(context.Background(), token, nil)
+		if err != nil {
+			t.Errorf("Verify(%q): %v", token, err)
+			continue
+		}
+		if info.Extra["subject"] != subject || info.Expiration.Before(time.Now()) {
+			t.Errorf("Verify(%q) = %+v, want subject %s and a future expiry", token, info, subject)
+		}
+	}
+	for _, token := range []string{"unknown", hex.EncodeToString(sum[:]), ""} {
+		if _, err := a.Verify(context.Background(), token, nil); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("Verify(%q) error = %v, want an invalid token error", token, err)
+		}
+	}
+	// The SDK rejects tokens whose expiry has passed
+	info, err := a.Verify(context.Background(), "expired-secret", nil)
+	if err != nil || !info.Expiration.Before(time.Now()) {
+		t.Errorf("expired token: info = %+v, err = %v", info, err)
+	}
+}
+
+func TestLoadStaticTokensErrors(t *testing.T) {
+	tests := map[string]string{
+		"both":         "tokens:\n  - name: a\n    token: x\n    sha256: " + strings.Repeat("0", 64) + "\n",
+		"neither":      "tokens:\n  - name: a\n    scopes: [tools:*]\n",
+		"short sha256": "tokens:\n  - name: a\n    sha256: abcd\n",
+		"bad sha256":   "tokens:\n  - name: a\n    sha256: " + strings.Repeat("z", 64) + "\n",
+		"typo":         "tokens:\n  - name: a\n    token: x\n    scope: [tools:*]\n",
+	}
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), "tokens.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadStaticTokens(path); err == nil {
+			t.Errorf("%s: loadStaticTokens accepted %q", name, content)
+		}
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	next := func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		switch method {
+		case "tools/list":
+			return &mcp.ListToolsResult{Tools: []*mcp.Tool{{Name: "web_search"}, {Name: "url_read"}}}, nil
+		case "resources/list":
+			return &mcp.ListResourcesResult{Resources: []*mcp.Resource{
+				{URI: "usage://mcp-searxng"}, {URI: "config://mcp-searxng"}, {URI: "health://mcp-searxng"},
+			}}, nil
+		case "resources/read":
+			return &mcp.ReadResourceResult{}, nil
+		}
+		return &mcp.CallToolResult{}, nil
+	}
+	handler := requireScopes(next)
+	extra := &mcp.RequestExtra{TokenInfo: &auth.TokenInfo{Scopes: []string{"tools:web_search", "resources:usage"}}}
+
+	call := func(name string, extra *mcp.RequestExtra) *mcp.CallToolResult {
+		result, err := handler(context.Background(), "tools/call",
+			&mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: name}, Extra: extra})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.(*mcp.CallToolResult)
+	}
+	if call("web_search", extra).IsError {
+		t.Error("web_search was denied")
+	}
+	if result := call("url_read", extra); !result.IsError || result.StructuredContent.(*ErrorOutput).Error.Code != ErrPermissionDenied {
+		t.Errorf("url_read without its scope: %+v", result)
+	}
+	if call("url_read", nil).IsError {
+		t.Error("a request without a token was restricted")
+	}
+
+	result, _ := handler(context.Background(), "tools/list", &mcp.ListToolsRequest{Extra: extra})
+	if tools := result.(*mcp.ListToolsResult).Tools; len(tools) != 1 || tools[0].Name != "web_search" {
+		t.Errorf("tools/list = %v, want only web_search", tools)
+	}
+
+	read := func(uri string, extra *mcp.RequestExtra) error {
+		_, err := handler(context.Background(), "resources/read",
+			&mcp.ReadResourceRequest{Params: &mcp.ReadResourceParams{URI: uri}, Extra: extra})
+		return err
+	}
+	if err := read("usage://mcp-searxng", extra); err != nil {
+		t.Errorf("reading usage:// with resources:usage: %v", err)
+	}
+	for _, uri := range []string{"config://mcp-searxng", "health://mcp-searxng"} {
+		var terr *ToolError
+		if err := read(uri, extra); !errors.As(err, &terr) || terr.Code != ErrPermissionDenied {
+			t.Errorf("reading %s without its scope: err = %v", uri, err)
+		}
+		if err := read(uri, nil); err != nil {
+			t.Errorf("reading %s without a token: %v", uri, err)
+		}
+	}
+	all := &mcp.RequestExtra{TokenInfo: &auth.TokenInfo{Scopes: []string{allResourcesScope}}}
+	if err := read("config://mcp-searxng", all); err != nil {
+		t.Errorf("reading config:// with resources:*: %v", err)
+	}
+
+	result, _ = handler(context.Background(), "resources/list", &mcp.ListResourcesRequest{Extra: extra})
+	if resources := result.(*mcp.ListResourcesResult).Resources; len(resources) != 1 || resources[0].URI != "usage://mcp-searxng" {
+		t.Errorf("resources/list = %v, want only usage://mcp-searxng", resources)
+	}
+}
+
+func TestCachezRequiresAScope(t *testing.T) {
+	cache := NewCache(60, 10)
+	t.Cleanup(cache.Destroy)
+	settings := AuthSettings{HMACSecret: testHMACSecret}
+	authenticator := newTestAuthenticator(t, settings)
+	settings.Issuer, settings.Audience = "https://auth.example.com/", "mcp-searxng"
+	handler := authenticator.Middleware(requireHTTPScope(cacheStatsHandler(cache), cacheStatsScope, allResourcesScope))
+
+	get := func(h http.Handler, scopes ...string) int {
+		req := httptest.NewRequest("GET", "/cachez", nil)
+		if scopes != nil {
+			req.Header.Set("Authorization", "Bearer "+signHMACToken(settings, "alice", scopes, time.Hour))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	tests := []struct {
+		scopes []string
+		want   int
+	}{
+		{nil, http.StatusUnauthorized},
+		{[]string{allToolsScope, "resources:usage"}, http.StatusForbidden},
+		{[]string{cacheStatsScope}, http.StatusOK},
+		{[]string{allResourcesScope}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := get(handler, tt.scopes...); got != tt.want {
+			t.Errorf("/cachez with scopes %v = %d, want %d", tt.scopes, got, tt.want)
+		}
+	}
+
+	// Without authentication there's no token to check
+	open := must(NewAuthenticator(AuthSettings{})).Middleware(requireHTTPScope(cacheStatsHandler(cache), cacheStatsScope))
+	if got := get(open); got != http.StatusOK {
+		t.Errorf("/cachez without authentication = %d, want 200", got)
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"encoding/json"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +20,9 @@ type Cache struct {
 	maxEntries    int
 	cleanupTicker *time.Ticker
 	stopCleanup   chan bool
+
+	hits   atomic.Int64
+	misses atomic.Int64
 }
 
 // NewCache creates a TTL cache. maxEntries bounds how large the cache
@@ -80,17 +86,34 @@ func (c *Cache) Get(key string) string {
 
 	entry, exists := c.data[key]
 	if !exists {
+		c.misses.Add(1)
 		return ""
 	}
 
 	if time.Now().After(entry.Expiry) {
 		// Entry expired
+		c.misses.Add(1)
 		return ""
 	}
 
+	c.hits.Add(1)
 	return entry.Value
 }
 
+// Reconfigure changes the TTL and size cap. Existing entries keep their
+// expiry; if the cap shrank, the oldest entries are evicted.
+func (c *Cache) Reconfigure(ttlSeconds int, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ttl = time.Duration(ttlSeconds) * time.Second
+	c.maxEntries = maxEntries
+	for maxEntries > 0 && len(c.data) > maxEntries {
+		c.evictOldestLocked()
+	}
+	c.cleanupTicker.Reset(time.Duration(ttlSeconds*2) * time.Second)
+}
+
 func (c *Cache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -130,5 +153,16 @@ func (c *Cache) GetStats() map[string]interface{} {
 		"size":       len(c.data),
 		"ttl":        c.ttl.Seconds(),
 		"maxEntries": c.maxEntries,
+		"hits":       c.hits.Load(),
+		"misses":     c.misses.Load(),
 	}
 }
+
+// cacheStatsHandler serves the cache's stats as JSON, at /cachez (used
+// by the "cache stats" command).
+func cacheStatsHandler(cache *Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cache.GetStats())
+	})
+}
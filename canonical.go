@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters that identify the click rather than
+// the content, so two URLs differing only in these point at the same page.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true,
+	"yclid": true, "igshid": true, "mc_cid": true, "mc_eid": true,
+	"_ga": true, "_gl": true, "ref_src": true, "ref_url": true,
+}
+
+// ampVariantParams are query parameters that usually just select an AMP
+// or tracked variant of a page, but on some sites select content, so
+// they're only ignored when deduplicating results (see dedupeKey).
+var ampVariantParams = map[string]bool{
+	"amp": true, "spm": true,
+}
+
+// canonicalizeURL maps equivalent URLs to a single key: AMP cache URLs
+// resolve to their origin, http and https are treated alike, hosts lose
+// "www." and default ports, tracking parameters and fragments are
+// dropped, remaining query parameters are sorted, and trailing slashes
+// are removed. It only applies rules that hold for every site, since it
+// is the url_read cache key and a collision would serve one page's
+// content for another.
+//
+// The result identifies a page; it is not always the best URL to fetch
+// or show, so callers keep the original URL for that.
+func canonicalizeURL(raw string) string {
+	return canonicalize(raw, false)
+}
+
+// dedupeKey is canonicalizeURL plus heuristics that fold AMP variants
+// (/amp and .amp.html paths, amp=1 and outputType=amp parameters) into
+// the page they mirror. They can wrongly merge distinct pages, e.g.
+// npmjs.com/package/amp and npmjs.com/package, which is acceptable when
+// deduplicating search results but not for caching.
+func dedupeKey(raw string) string {
+	return canonicalize(raw, true)
+}
+
+func canonicalize(raw string, foldAMP bool) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(raw)
+	}
+
+	u = resolveAMPCache(u)
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "http" {
+		scheme = "https"
+	}
+
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	path := u.EscapedPath()
+	path = strings.TrimSuffix(path, "/")
+	if foldAMP {
+		path = strings.TrimSuffix(path, "/amp")
+		if strings.HasSuffix(path, ".amp.html") {
+			path = strings.TrimSuffix(path, ".amp.html") + ".html"
+		}
+		path = strings.TrimSuffix(path, "/")
+	}
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(key)
+		}
+		if foldAMP && (ampVariantParams[lower] || lower == "outputtype" && strings.EqualFold(query.Get(key), "amp")) {
+			query.Del(key)
+		}
+	}
+
+	canonical := scheme + "://" + host + path
+	if len(query) > 0 {
+		// Encode sorts by key; sort values too so order never matters.
+		for _, values := range query {
+			sort.Strings(values)
+		}
+		canonical += "?" + query.Encode()
+	}
+
+	return canonical
+}
+
+// resolveAMPCache unwraps Google AMP viewer and AMP cache URLs, e.g.
+// https://www.google.com/amp/s/example.com/a,
+// https://example-com.cdn.ampproject.org/c/s/example.com/a or
+// https://example-com.bing-amp.com/c/s/example.com/a, to the URL of the
+// page they serve. Other hosts, amp.dev or amp.example.com included, are
+// left alone.
+func resolveAMPCache(u *url.URL) *url.URL {
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+
+	var rest string
+	switch {
+	case (host == "google.com" || strings.HasSuffix(host, ".google.com")) && strings.HasPrefix(path, "/amp/"):
+		rest = strings.TrimPrefix(path, "/amp/")
+	case strings.HasSuffix(host, ".cdn.ampproject.org"), strings.HasSuffix(host, ".bing-amp.com"):
+		// /c/ is a document, /v/ a viewer; /i/ (images) etc. are left alone.
+		for _, prefix := range []string{"/c/", "/v/"} {
+			if strings.HasPrefix(path, prefix) {
+				rest = strings.TrimPrefix(path, prefix)
+			}
+		}
+	}
+	if rest == "" {
+		return u
+	}
+
+	scheme := "http"
+	if strings.HasPrefix(rest, "s/") {
+		scheme = "https"
+		rest = strings.TrimPrefix(rest, "s/")
+	}
+
+	origin, err := url.Parse(scheme + "://" + rest)
+	if err != nil || origin.Host == "" {
+		return u
+	}
+	origin.RawQuery = u.RawQuery
+	return origin
+}
+
+// dedupeResults merges search results with the same dedupeKey.
+// The first (best-ranked) occurrence keeps its position and URL; it
+// takes the longest snippet among the duplicates and the union of their
+// engines. The second return value is how many results were merged away.
+func dedupeResults(results []SearXNGResult) ([]SearXNGResult, int) {
+	index := make(map[string]int, len(results))
+	deduped := make([]SearXNGResult, 0, len(results))
+
+	for _, r := range results {
+		key := dedupeKey(r.URL)
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(deduped)
+			deduped = append(deduped, r)
+			continue
+		}
+
+		kept := &deduped[i]
+		if len(r.Content) > len(kept.Content) {
+			kept.Content = r.Content
+		}
+		if kept.Title == "" {
+			kept.Title = r.Title
+		}
+		for _, engine := range r.Engines {
+			if !slices.Contains(kept.Engines, engine) {
+				kept.Engines = append(kept.Engines, engine)
+			}
+		}
+	}
+
+	return deduped, len(results) - len(deduped)
+}
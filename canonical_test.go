@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://example.com/a/", "https://example.com/a"},
+		{"http://www.Example.com:80/a?b=2&a=1#frag", "https://example.com/a?a=1&b=2"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"https://example.com/a?utm_source=x&fbclid=y&id=3", "https://example.com/a?id=3"},
+		{"https://www.google.com/amp/s/example.com/a", "https://example.com/a"},
+		{"https://example-com.cdn.ampproject.org/c/s/example.com/a?x=1", "https://example.com/a?x=1"},
+		{"https://example-com.bing-amp.com/c/s/example.com/a", "https://example.com/a"},
+
+		// AMP heuristics don't apply to the cache key
+		{"https://amp.dev/documentation/", "https://amp.dev/documentation"},
+		{"https://amp.example.com/a", "https://amp.example.com/a"},
+		{"https://www.npmjs.com/package/amp", "https://npmjs.com/package/amp"},
+		{"https://example.com/story.amp.html", "https://example.com/story.amp.html"},
+		{"https://example.com/a?amp=1", "https://example.com/a?amp=1"},
+		{"https://example.com/a?spm=2", "https://example.com/a?spm=2"},
+		{"https://example.com/a?outputType=amp", "https://example.com/a?outputType=amp"},
+
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		if got := canonicalizeURL(tt.raw); got != tt.want {
+			t.Errorf("canonicalizeURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizeURLKeepsDistinctPagesApart(t *testing.T) {
+	pairs := [][2]string{
+		{"https://amp.dev/documentation/", "https://dev/documentation"},
+		{"https://www.npmjs.com/package/amp", "https://www.npmjs.com/package"},
+		{"https://example.com/search?amp=1", "https://example.com/search"},
+		{"https://shop.example.com/item?spm=a1", "https://shop.example.com/item"},
+	}
+	for _, p := range pairs {
+		if canonicalizeURL(p[0]) == canonicalizeURL(p[1]) {
+			t.Errorf("%s and %s share the cache key %s", p[0], p[1], canonicalizeURL(p[0]))
+		}
+	}
+}
+
+func TestDedupeKey(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://example.com/news/story/amp", "https://example.com/news/story"},
+		{"https://example.com/news/story.amp.html", "https://example.com/news/story.html"},
+		{"https://example.com/a?amp=1&utm_medium=x", "https://example.com/a"},
+		{"https://example.com/a?outputType=amp", "https://example.com/a"},
+		{"https://example.com/a?spm=a1.b2", "https://example.com/a"},
+		{"https://amp.dev/documentation/", "https://amp.dev/documentation"},
+		{"https://amp.example.com/a", "https://amp.example.com/a"},
+	}
+	for _, tt := range tests {
+		if got := dedupeKey(tt.raw); got != tt.want {
+			t.Errorf("dedupeKey(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestDedupeResults(t *testing.T) {
+	results := []SearXNGResult{
+		{URL: "https://example.com/story", Title: "Story", Content: "short", Engines: []string{"google"}},
+		{URL: "http://www.example.com/story/amp?utm_source=x", Title: "Story (AMP)", Content: "a longer snippet", Engines: []string{"bing"}},
+		{URL: "https://amp.dev/documentation/", Title: "AMP docs"},
+		{URL: "https://example.com/other", Title: "Other"},
+	}
+	deduped, merged := dedupeResults(results)
+	if merged != 1 || len(deduped) != 3 {
+		t.Fatalf("dedupeResults merged %d into %d results, want 1 into 3", merged, len(deduped))
+	}
+	kept := deduped[0]
+	if kept.URL != "https://example.com/story" || kept.Content != "a longer snippet" || len(kept.Engines) != 2 {
+		t.Errorf("merged result = %+v", kept)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+// commands are subcommands that run the server's tools directly, without
+// an MCP client, for scripting and for reproducing agent issues by hand.
+// Each returns the process exit code.
+var commands = map[string]func(args []string) int{
+	"search": runSearchCommand,
+	"read":   runReadCommand,
+	"health": runHealthCommand,
+	"config": runConfigCommand,
+	"cache":  runCacheCommand,
+	"token":  runTokenCommand,
+}
+
+const commandUsage = `Usage:
+  mcp-searxng-go [flags]                    run the MCP server
+  mcp-searxng-go search [flags] <query>     search, like the web_search tool
+  mcp-searxng-go read [flags] <url>         read a page, like the url_read tool
+  mcp-searxng-go health [flags]             probe SearXNG
+  mcp-searxng-go config print [flags]       print the effective configuration
+  mcp-searxng-go cache stats [flags]        print a running server's cache stats
+  mcp-searxng-go token [flags] <subject>    mint an HMAC-signed client token
+
+Run a command with -h for its flags. Flags go before the query or URL.
+`
+
+// newCommandFlags creates the flag set for a subcommand, including the
+// configuration flags and -json.
+func newCommandFlags(name string) (*flag.FlagSet, *configFlags, *bool) {
+	fs := flag.NewFlagSet("mcp-searxng-go "+name, flag.ContinueOnError)
+	flags := addConfigFlags(fs)
+	asJSON := fs.Bool("json", false, "print the structured output as JSON instead of Markdown")
+	return fs, flags, asJSON
+}
+
+// parseCommand parses args and loads the configuration. If either fails
+// it prints why and returns false with the exit code.
+func parseCommand(fs *flag.FlagSet, flags *configFlags, args []string) (*Config, int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, 0, false
+		}
+		return nil, 2, false
+	}
+	config, err := flags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error:\n%v\n", err)
+		return nil, 2, false
+	}
+	return config, 0, true
+}
+
+// printResult prints a tool result: its text, or with asJSON its
+// structured output. Returns 1 if the tool failed.
+func printResult(result *mcp.CallToolResult, out any, asJSON bool) int {
+	var text string
+	for _, content := range result.Content {
+		if tc, ok := content.(*mcp.TextContent); ok {
+			text += tc.Text
+		}
+	}
+
+	switch {
+	case asJSON && out != nil:
+		printJSON(out)
+	case asJSON:
+		printJSON(map[string]string{"text": text})
+	case result.IsError:
+		fmt.Fprintln(os.Stderr, text)
+	default:
+		fmt.Println(text)
+	}
+
+	if result.IsError {
+		return 1
+	}
+	return 0
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// splitList splits a comma-separated flag value.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func runSearchCommand(args []string) int {
+	fs, flags, asJSON := newCommandFlags("search")
+	var search WebSearchArgs
+	var categories, engines string
+	fs.IntVar(&search.PageNo, "page", 1, "page number")
+	fs.StringVar(&search.TimeRange, "time-range", "", "day, week, month or year")
+	fs.StringVar(&search.Language, "language", "", "language code, e.g. en")
+	fs.StringVar(&search.SafeSearch, "safesearch", "", "0, 1 or 2")
+	fs.IntVar(&search.MaxResults, "max-results", 0, "fetch several pages to return up to this many results")
+	fs.IntVar(&search.MaxTokens, "max-tokens", 0, "approximate token budget")
+	fs.IntVar(&search.Offset, "offset", 0, "skip this many results, to continue output trimmed by -max-tokens")
+	fs.StringVar(&categories, "categories", "", "comma-separated SearXNG categories")
+	fs.StringVar(&engines, "engines", "", "comma-separated SearXNG engines")
+	config, code, ok := parseCommand(fs, flags, args)
+	if !ok {
+		return code
+	}
+	search.Query = strings.Join(fs.Args(), " ")
+	search.Categories = splitList(categories)
+	search.Engines = splitList(engines)
+
+	cache := NewCache(config.Cache.TTL, config.Cache.MaxEntries)
+	defer cache.Destroy()
+	client, err := NewSearXNGClient(config.SearXNG, cache, NewProxyConfig(config.Proxy))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error:\n%v\n", err)
+		return 2
+	}
+
+	result, out, _ := handleWebSearch(context.Background(), nil, client, NewRankingConfig(config.Ranking), nil, search)
+	return printResult(result, out, *asJSON)
+}
+
+func runReadCommand(args []string) int {
+	fs, flags, asJSON := newCommandFlags("read")
+	var read URLReadArgs
+	fs.IntVar(&read.StartChar, "start", 0, "starting character position")
+	fs.IntVar(&read.MaxLength, "max-length", 0, "maximum characters to return")
+	fs.StringVar(&read.Section, "section", "", "section ID, heading path or heading title")
+	fs.StringVar(&read.ParagraphRange, "paragraphs", "", "paragraph range, e.g. 1-5")
+	fs.BoolVar(&read.ReadHeadings, "headings", false, "print the table of contents instead of the content")
+	fs.IntVar(&read.MaxTokens, "max-tokens", 0, "approximate token budget")
+	fs.StringVar(&read.Find, "find", "", "return only passages matching this phrase")
+	fs.BoolVar(&read.FindRegex, "regex", false, "treat -find as a regular expression")
+	fs.StringVar(&read.Query, "query", "", "return the passages most relevant to this question")
+	fs.IntVar(&read.TopK, "top-k", 0, "number of passages with -query")
+	fs.StringVar(&read.Cursor, "cursor", "", "continuation cursor from a previous read")
+	config, code, ok := parseCommand(fs, flags, args)
+	if !ok {
+		return code
+	}
+	read.URL = fs.Arg(0)
+
+	cache := NewCache(config.Cache.TTL, config.Cache.MaxEntries)
+	defer cache.Destroy()
+	reader := NewURLReader(config.URLReader, cache, NewProxyConfig(config.Proxy))
+
+	result, out, _ := handleURLRead(context.Background(), nil, reader, read)
+	return printResult(result, out, *asJSON)
+}
+
+func runHealthCommand(args []string) int {
+	fs, flags, asJSON := newCommandFlags("health")
+	config, code, ok := parseCommand(fs, flags, args)
+	if !ok {
+		return code
+	}
+
+	client, err := NewSearXNGClient(config.SearXNG, nil, NewProxyConfig(config.Proxy))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error:\n%v\n", err)
+		return 2
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	report := client.Probe(ctx)
+
+	if *asJSON {
+		printJSON(report)
+	} else {
+		status := "healthy"
+		if !report.Healthy {
+			status = "unhealthy"
+		}
+		output := fmt.Sprintf("# SearXNG at %s is %s (%dms)\n\n", config.SearXNG.URL, status, report.LatencyMS)
+		for _, check := range report.Checks {
+			mark := "ok"
+			if !check.OK {
+				mark = "FAILED"
+			}
+			output += fmt.Sprintf("- **%s**: %s — %s\n", check.Name, mark, check.Detail)
+		}
+		fmt.Print(output)
+	}
+
+	if !report.Healthy {
+		return 1
+	}
+	return 0
+}
+
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprint(os.Stderr, "Usage: mcp-searxng-go config print [flags]\n")
+		return 2
+	}
+	fs, flags, asJSON := newCommandFlags("config print")
+	config, code, ok := parseCommand(fs, flags, args[1:])
+	if !ok {
+		return code
+	}
+
+	redacted := config.redacted("(redacted)")
+	data, _ := yaml.Marshal(redacted)
+	if *asJSON {
+		var sections map[string]any
+		yaml.Unmarshal(data, &sections)
+		printJSON(sections)
+		return 0
+	}
+	if config.File != "" {
+		fmt.Printf("# loaded from %s\n", config.File)
+	}
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	enc.Encode(redacted)
+	return 0
+}
+
+func runCacheCommand(args []string) int {
+	if len(args) == 0 || args[0] != "stats" {
+		fmt.Fprint(os.Stderr, "Usage: mcp-searxng-go cache stats [-server URL] [flags]\n")
+		return 2
+	}
+	fs, flags, asJSON := newCommandFlags("cache stats")
+	server := fs.String("server", "", "base URL of a server running in HTTP mode (default: from server.http_addr)")
+	token := fs.String("token", os.Getenv("MCP_AUTH_TOKEN"), "bearer token, if the server requires one")
+	config, code, ok := parseCommand(fs, flags, args[1:])
+	if !ok {
+		return code
+	}
+
+	// The cache lives in the server process, so ask a running server
+	base := *server
+	if base == "" {
+		host := config.Server.HTTPAddr
+		if strings.HasPrefix(host, ":") {
+			host = "localhost" + host
+		}
+		base = "http://" + host
+	}
+	req, err := http.NewRequest("GET", strings.TrimSuffix(base, "/")+"/cachez", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid server URL %q: %v\n", base, err)
+		return 2
+	}
+	if *token != "" {
+		req.Header.Set("Authorization", "Bearer "+*token)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot reach the server at %s (is it running with MCP_TRANSPORT=http?): %v\n", base, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "%s/cachez returned status %d: %s\n", base, resp.StatusCode, truncateForLog(string(body), 200))
+		return 1
+	}
+
+	var stats struct {
+		Size       int     `json:"size"`
+		TTL        float64 `json:"ttl"`
+		MaxEntries int     `json:"maxEntries"`
+		Hits       int64   `json:"hits"`
+		Misses     int64   `json:"misses"`
+	}
+	if err := json.Unmarshal(body, &stats); err != nil {
+		fmt.Fprintf(os.Stderr, "unexpected response from %s/cachez: %v\n", base, err)
+		return 1
+	}
+	if *asJSON {
+		printJSON(stats)
+		return 0
+	}
+
+	output := fmt.Sprintf("# Cache stats (%s)\n\n", base)
+	output += fmt.Sprintf("- **Entries**: %d of %d\n", stats.Size, stats.MaxEntries)
+	output += fmt.Sprintf("- **TTL**: %gs\n", stats.TTL)
+	output += fmt.Sprintf("- **Hits**: %d\n", stats.Hits)
+	output += fmt.Sprintf("- **Misses**: %d\n", stats.Misses)
+	if total := stats.Hits + stats.Misses; total > 0 {
+		output += fmt.Sprintf("- **Hit rate**: %.1f%%\n", float64(stats.Hits)*100/float64(total))
+	}
+	fmt.Print(output)
+	return 0
+}
+
+func runTokenCommand(args []string) int {
+	fs, flags, _ := newCommandFlags("token")
+	scopes := fs.String("scopes", allToolsScope+","+allResourcesScope, "comma-separated scopes, e.g. tools:web_search,tools:url_read,resources:usage")
+	ttl := fs.Duration("ttl", 30*24*time.Hour, "how long the token is valid")
+	config, code, ok := parseCommand(fs, flags, args)
+	if !ok {
+		return code
+	}
+	if config.Auth.HMACSecret == "" {
+		fmt.Fprintln(os.Stderr, "no HMAC secret configured; set auth.hmac_secret or MCP_AUTH_HMAC_SECRET")
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: mcp-searxng-go token [-scopes list] [-ttl duration] <subject>")
+		return 2
+	}
+
+	fmt.Println(signHMACToken(config.Auth, fs.Arg(0), splitList(*scopes), *ttl))
+	return 0
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runCommand runs a subcommand and returns its exit code and what it
+// printed to stdout and stderr.
+func runCommand(t *testing.T, name string, args ...string) (int, string, string) {
+	t.Helper()
+	capture := func(f **os.File) func() string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := *f
+		*f = w
+		done := make(chan string)
+		go func() {
+			data, _ := io.ReadAll(r)
+			done <- string(data)
+		}()
+		return func() string {
+			w.Close()
+			*f = saved
+			return <-done
+		}
+	}
+	stdout, stderr := capture(&os.Stdout), capture(&os.Stderr)
+	code := commands[name](args)
+	return code, stdout(), stderr()
+}
+
+// fakeSearXNG answers searches with results and the health probe's empty
+// query with SearXNG's "No query" error.
+func fakeSearXNG(t *testing.T, results []SearXNGResult) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") == "" {
+			http.Error(w, `{"error": "No query"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(SearXNGResponse{Results: results})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSearchCommand(t *testing.T) {
+	srv := fakeSearXNG(t, makeResults("r", 3))
+
+	code, stdout, _ := runCommand(t, "search", "-searxng-url", srv.URL, "go", "generics")
+	if code != 0 || !strings.Contains(stdout, `"go generics"`) || !strings.Contains(stdout, "r-3") {
+		t.Errorf("search exited %d:\n%s", code, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, "search", "-searxng-url", srv.URL, "-json", "-max-results", "2", "q")
+	var out WebSearchOutput
+	if err := json.Unmarshal([]byte(stdout), &out); code != 0 || err != nil || len(out.Results) != 2 {
+		t.Errorf("search -json exited %d with %d results (%v):\n%s", code, len(out.Results), err, stdout)
+	}
+
+	// A failed search goes to stderr and exits 1; bad flags and config exit 2
+	if code, stdout, stderr := runCommand(t, "search", "-searxng-url", srv.URL); code != 1 || stdout != "" || !strings.Contains(stderr, "query parameter is required") {
+		t.Errorf("search without a query exited %d: %q %q", code, stdout, stderr)
+	}
+	if code, _, _ := runCommand(t, "search", "-no-such-flag", "q"); code != 2 {
+		t.Errorf("unknown flag exited %d, want 2", code)
+	}
+	if code, _, stderr := runCommand(t, "search", "-searxng-url", "ftp://nope", "q"); code != 2 || !strings.Contains(stderr, "Configuration error") {
+		t.Errorf("bad config exited %d: %s", code, stderr)
+	}
+	if code, _, _ := runCommand(t, "search", "-h"); code != 0 {
+		t.Errorf("-h exited %d, want 0", code)
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "http://localhost:8080")
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<html><body><h1>Intro</h1><p>Hello from the page.</p><h2>Usage</h2><p>Run it.</p></body></html>")
+	}))
+	t.Cleanup(page.Close)
+
+	code, stdout, _ := runCommand(t, "read", page.URL)
+	if code != 0 || !strings.Contains(stdout, "Hello from the page.") {
+		t.Errorf("read exited %d:\n%s", code, stdout)
+	}
+	code, stdout, _ = runCommand(t, "read", "-section", "Usage", page.URL)
+	if code != 0 || !strings.Contains(stdout, "Run it.") || strings.Contains(stdout, "Hello from the page.") {
+		t.Errorf("read -section exited %d:\n%s", code, stdout)
+	}
+	if code, _, stderr := runCommand(t, "read", "ftp://example.com/"); code != 1 || !strings.Contains(stderr, "invalid_argument") {
+		t.Errorf("read of a bad URL exited %d: %s", code, stderr)
+	}
+}
+
+func TestHealthCommand(t *testing.T) {
+	srv := fakeSearXNG(t, nil)
+	code, stdout, _ := runCommand(t, "health", "-searxng-url", srv.URL)
+	if code != 0 || !strings.Contains(stdout, "is healthy") || !strings.Contains(stdout, "**json_format**: ok") {
+		t.Errorf("health exited %d:\n%s", code, stdout)
+	}
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	t.Cleanup(down.Close)
+	code, stdout, _ = runCommand(t, "health", "-searxng-url", down.URL, "-json")
+	var report HealthReport
+	if err := json.Unmarshal([]byte(stdout), &report); code != 1 || err != nil || report.Healthy {
+		t.Errorf("unhealthy health -json exited %d (%v):\n%s", code, err, stdout)
+	}
+}
+
+func TestConfigPrintCommand(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "http://localhost:8080")
+	t.Setenv("AUTH_USERNAME", "admin")
+	t.Setenv("AUTH_PASSWORD", "hunter2")
+	t.Setenv("MCP_AUTH_HMAC_SECRET", testHMACSecret)
+
+	code, stdout, _ := runCommand(t, "config", "print", "-searxng-url", "http://searxng.internal:8080", "-cache-ttl", "90")
+	if code != 0 || !strings.Contains(stdout, "http://searxng.internal:8080") || !strings.Contains(stdout, "ttl: 90") {
+		t.Errorf("config print exited %d:\n%s", code, stdout)
+	}
+	if strings.Contains(stdout, "hunter2") || strings.Contains(stdout, testHMACSecret) || !strings.Contains(stdout, "(redacted)") {
+		t.Errorf("config print shows secrets:\n%s", stdout)
+	}
+
+	code, stdout, _ = runCommand(t, "config", "print", "-json")
+	var sections map[string]any
+	if err := json.Unmarshal([]byte(stdout), &sections); code != 0 || err != nil || sections["searxng"] == nil {
+		t.Errorf("config print -json exited %d (%v):\n%s", code, err, stdout)
+	}
+
+	if code, _, _ := runCommand(t, "config"); code != 2 {
+		t.Errorf("config without print exited %d, want 2", code)
+	}
+}
+
+func TestCacheStatsCommand(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "http://localhost:8080")
+	cache := NewCache(60, 100)
+	t.Cleanup(cache.Destroy)
+	cache.Set("a", "1")
+	cache.Get("a")
+	cache.Get("b")
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if r.URL.Path != "/cachez" {
+			http.NotFound(w, r)
+			return
+		}
+		cacheStatsHandler(cache).ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	code, stdout, _ := runCommand(t, "cache", "stats", "-server", srv.URL+"/", "-token", "secret-token")
+	if code != 0 || !strings.Contains(stdout, "**Entries**: 1 of 100") || !strings.Contains(stdout, "**Hit rate**: 50.0%") {
+		t.Errorf("cache stats exited %d:\n%s", code, stdout)
+	}
+	if authorization != "Bearer secret-token" {
+		t.Errorf("Authorization = %q", authorization)
+	}
+
+	if code, _, stderr := runCommand(t, "cache", "stats", "-server", srv.URL+"/nowhere"); code != 1 || !strings.Contains(stderr, "status 404") {
+		t.Errorf("cache stats against a bad URL exited %d: %s", code, stderr)
+	}
+	if code, _, _ := runCommand(t, "cache"); code != 2 {
+		t.Errorf("cache without stats exited %d, want 2", code)
+	}
+}
+
+func TestTokenCommand(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "http://localhost:8080")
+	t.Setenv("MCP_AUTH_HMAC_SECRET", "")
+	if code, _, stderr := runCommand(t, "token", "alice"); code != 2 || !strings.Contains(stderr, "no HMAC secret") {
+		t.Errorf("token without a secret exited %d: %s", code, stderr)
+	}
+
+	t.Setenv("MCP_AUTH_HMAC_SECRET", testHMACSecret)
+	t.Setenv("MCP_AUTH_ISSUER", "https://auth.example.com/")
+	t.Setenv("MCP_AUTH_AUDIENCE", "mcp-searxng")
+	code, stdout, _ := runCommand(t, "token", "-scopes", "tools:web_search", "-ttl", "1h", "alice")
+	if code != 0 {
+		t.Fatalf("token exited %d", code)
+	}
+	a := newTestAuthenticator(t, AuthSettings{HMACSecret: testHMACSecret})
+	info, err := a.current().verifyJWT(strings.TrimSpace(stdout))
+	if err != nil {
+		t.Fatalf("minted token doesn't verify: %v", err)
+	}
+	if info.Extra["subject"] != "alice" || len(info.Scopes) != 1 || info.Scopes[0] != "tools:web_search" || time.Until(info.Expiration) > time.Hour {
+		t.Errorf("token info = %+v", info)
+	}
+
+	if code, _, _ := runCommand(t, "token"); code != 2 {
+		t.Errorf("token without a subject exited %d, want 2", code)
+	}
+}
+
+func TestConfigFileFlag(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("searxng:\n  url: http://from-file:8080\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, _ := runCommand(t, "config", "print", "-config", path)
+	if code != 0 || !strings.Contains(stdout, "# loaded from "+path) || !strings.Contains(stdout, "http://from-file:8080") {
+		t.Errorf("config print -config exited %d:\n%s", code, stdout)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultCodeResults = 10
+	maxCodeResults     = 30
+)
+
+// codeSources maps code_search's source parameter to SearXNG categories.
+// Engines declare these categories themselves (github is "repos",
+// stackoverflow "q&a", pypi and pkg.go.dev "packages"), so enabling an
+// engine in settings.yml is enough for code_search to pick it up.
+var codeSources = map[string]string{
+	"all":      "it",
+	"repos":    "repos",
+	"qa":       "q&a",
+	"packages": "packages",
+}
+
+// repoHosts are engines whose results are source repositories, so their
+// popularity is a star count.
+var repoHosts = []string{"github", "gitlab", "codeberg", "bitbucket", "sourcehut", "gitea"}
+
+// CodeSearchArgs defines the parameters for code search
+type CodeSearchArgs struct {
+	Query      string   `json:"query" jsonschema:"the search query, e.g. an error message or library name"`
+	Source     string   `json:"source,omitempty" jsonschema:"what to search: all (default), repos, qa (StackOverflow and other Q&A sites), or packages"`
+	PageNo     int      `json:"pageno,omitempty" jsonschema:"search page number (starts at 1)"`
+	TimeRange  string   `json:"time_range,omitempty" jsonschema:"time range of search (day, week, month, or year)"`
+	Engines    []string `json:"engines,omitempty" jsonschema:"restrict to these engines, e.g. github, stackoverflow, pkg.go.dev"`
+	MaxResults int      `json:"max_results,omitempty" jsonschema:"maximum number of results to return (default: 10, max: 30)"`
+}
+
+// CodeResult is a repository, Q&A thread, package or documentation page.
+type CodeResult struct {
+	Rank       int      `json:"rank"`
+	Kind       string   `json:"kind" jsonschema:"repository, question, package, or page"`
+	Title      string   `json:"title"`
+	URL        string   `json:"url"`
+	Content    string   `json:"content,omitempty"`
+	Stars      int      `json:"stars,omitempty" jsonschema:"repository star count"`
+	Language   string   `json:"language,omitempty" jsonschema:"repository's main programming language"`
+	License    string   `json:"license,omitempty"`
+	Package    string   `json:"package,omitempty"`
+	Version    string   `json:"version,omitempty"`
+	Maintainer string   `json:"maintainer,omitempty"`
+	Homepage   string   `json:"homepage,omitempty"`
+	SourceURL  string   `json:"sourceUrl,omitempty"`
+	Answered   *bool    `json:"answered,omitempty" jsonschema:"whether the question has an accepted or upvoted answer"`
+	Votes      *int     `json:"votes,omitempty" jsonschema:"question score"`
+	Tags       []string `json:"tags,omitempty"`
+	Updated    string   `json:"updated,omitempty" jsonschema:"last update or publication date"`
+	Engines    []string `json:"engines,omitempty"`
+}
+
+// CodeSearchOutput is code_search's structured output.
+type CodeSearchOutput struct {
+	Query   string       `json:"query"`
+	Source  string       `json:"source"`
+	Results []CodeResult `json:"results,omitempty"`
+	Error   *ToolError   `json:"error,omitempty"`
+}
+
+func hasEngine(r SearXNGResult, names ...string) bool {
+	for _, name := range names {
+		if containsFold(r.Engines, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStackExchangeContent recovers the metadata SearXNG's stackexchange
+// engine flattens into the snippet, which looks like
+// "[go, http] alice // is answered // score: 12".
+func parseStackExchangeContent(content string, cr *CodeResult) {
+	parts := strings.Split(content, " // ")
+	head := strings.TrimSpace(parts[0])
+	if strings.HasPrefix(head, "[") {
+		if end := strings.Index(head, "]"); end > 0 {
+			for _, tag := range strings.Split(head[1:end], ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					cr.Tags = append(cr.Tags, tag)
+				}
+			}
+			head = strings.TrimSpace(head[end+1:])
+		}
+	}
+	if head != "" {
+		cr.Maintainer = head
+	}
+
+	answered := false
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "is answered":
+			answered = true
+		case strings.HasPrefix(part, "score:"):
+			if n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(part, "score:"))); err == nil {
+				cr.Votes = &n
+			}
+		}
+	}
+	cr.Answered = &answered
+	// The snippet is only metadata, so don't repeat it as content.
+	cr.Content = ""
+}
+
+func toCodeResult(rank int, r SearXNGResult) CodeResult {
+	cr := CodeResult{
+		Rank:       rank,
+		Kind:       "page",
+		Title:      r.Title,
+		URL:        r.URL,
+		Content:    r.Content,
+		License:    r.LicenseName,
+		Package:    r.PackageName,
+		Version:    string(r.Version),
+		Maintainer: r.Maintainer,
+		Homepage:   r.Homepage,
+		SourceURL:  r.SourceCodeURL,
+		Tags:       r.Tags,
+		Engines:    r.Engines,
+	}
+	if published := parsePublishedDate(r.PublishedDate); !published.IsZero() {
+		cr.Updated = published.Format("2006-01-02")
+	}
+
+	switch {
+	case hasEngine(r, repoHosts...):
+		cr.Kind = "repository"
+		cr.Stars, _ = strconv.Atoi(string(r.Popularity))
+		// The github engine reports the language as the snippet's first
+		// " / "-separated part, e.g. "Go / A fast HTTP router".
+		if lang, description, ok := strings.Cut(r.Content, " / "); ok && len(lang) <= 30 && !strings.Contains(lang, " ") {
+			cr.Language = lang
+			cr.Content = description
+		}
+	case hasEngine(r, "stackoverflow", "askubuntu", "superuser", "serverfault") || strings.Contains(r.Content, " // score: "):
+		cr.Kind = "question"
+		parseStackExchangeContent(r.Content, &cr)
+	case r.PackageName != "":
+		cr.Kind = "package"
+	}
+
+	return cr
+}
+
+func handleCodeSearch(ctx context.Context, req *mcp.CallToolRequest, client *SearXNGClient, args CodeSearchArgs) (*mcp.CallToolResult, *CodeSearchOutput, error) {
+	// Validate parameters
+	if args.Query == "" {
+		terr := invalidArgument("query parameter is required")
+		return toolErrorResult("", terr), &CodeSearchOutput{Query: args.Query, Source: args.Source, Error: terr}, nil
+	}
+	if args.Source == "" {
+		args.Source = "all"
+	}
+	category, ok := codeSources[args.Source]
+	if !ok {
+		terr := invalidArgument("invalid source %q: use all, repos, qa, or packages", args.Source)
+		return toolErrorResult("", terr), &CodeSearchOutput{Query: args.Query, Source: args.Source, Error: terr}, nil
+	}
+
+	// Set defaults
+	if args.PageNo == 0 {
+		args.PageNo = 1
+	}
+	maxResults := args.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultCodeResults
+	}
+	maxResults = min(maxResults, maxCodeResults)
+
+	// Perform search
+	startTime := time.Now()
+	resp, err := client.Search(ctx, SearchRequest{
+		Query:      args.Query,
+		PageNo:     args.PageNo,
+		TimeRange:  args.TimeRange,
+		Categories: []string{category},
+		Engines:    args.Engines,
+	})
+	if err != nil {
+		terr := classifyError(err)
+		return toolErrorResult("Code search failed", terr), &CodeSearchOutput{Query: args.Query, Source: args.Source, Error: terr}, nil
+	}
+	duration := time.Since(startTime)
+
+	raw, _ := dedupeResults(resp.Results)
+	if len(raw) > maxResults {
+		raw = raw[:maxResults]
+	}
+
+	// Format output
+	if len(raw) == 0 {
+		output := fmt.Sprintf("# No Results Found\n\nNo code results found for query: \"%s\"", args.Query)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: output},
+			},
+		}, &CodeSearchOutput{Query: args.Query, Source: args.Source}, nil
+	}
+
+	output := fmt.Sprintf("# Code Results for \"%s\"\n\n", args.Query)
+	output += fmt.Sprintf("Found %d results (page %d, source: %s) in %dms\n\n", len(raw), args.PageNo, args.Source, duration.Milliseconds())
+
+	results := make([]CodeResult, len(raw))
+	for i, r := range raw {
+		cr := toCodeResult(i+1, r)
+		results[i] = cr
+
+		output += fmt.Sprintf("## %d. %s\n\n", cr.Rank, cr.Title)
+		output += fmt.Sprintf("**URL:** %s\n\n", cr.URL)
+
+		var details []string
+		add := func(label, value string) {
+			if value != "" {
+				details = append(details, fmt.Sprintf("**%s:** %s", label, value))
+			}
+		}
+		switch cr.Kind {
+		case "repository":
+			add("Owner", cr.Maintainer)
+			if cr.Stars > 0 {
+				add("Stars", strconv.Itoa(cr.Stars))
+			}
+			add("Language", cr.Language)
+			add("License", cr.License)
+			add("Updated", cr.Updated)
+		case "question":
+			if *cr.Answered {
+				add("Status", "answered")
+			} else {
+				add("Status", "unanswered")
+			}
+			if cr.Votes != nil {
+				add("Score", strconv.Itoa(*cr.Votes))
+			}
+			add("Asked by", cr.Maintainer)
+		case "package":
+			add("Package", strings.TrimSpace(cr.Package+" "+cr.Version))
+			add("Maintainer", cr.Maintainer)
+			add("License", cr.License)
+			add("Updated", cr.Updated)
+		}
+		if len(cr.Tags) > 0 {
+			add("Tags", strings.Join(cr.Tags, ", "))
+		}
+		add("Engines", strings.Join(cr.Engines, ", "))
+		output += strings.Join(details, " · ") + "\n\n"
+
+		if cr.Homepage != "" && cr.Homepage != cr.URL {
+			output += fmt.Sprintf("**Homepage:** %s\n\n", cr.Homepage)
+		}
+		if cr.Content != "" {
+			output += cr.Content + "\n\n"
+		}
+		output += "---\n\n"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: output},
+		},
+	}, &CodeSearchOutput{Query: args.Query, Source: args.Source, Results: results}, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseStackExchangeContent(t *testing.T) {
+	tests := []struct {
+		content  string
+		tags     []string
+		asker    string
+		answered bool
+		votes    int // -1: no score
+	}{
+		{"[go, http] alice // is answered // score: 12", []string{"go", "http"}, "alice", true, 12},
+		{"[rust] bob // score: -3", []string{"rust"}, "bob", false, -3},
+		{"carol // is answered", nil, "carol", true, -1},
+		{"[ python , , numpy ] // score: x", []string{"python", "numpy"}, "", false, -1},
+		{"[unclosed tag dave", nil, "[unclosed tag dave", false, -1},
+		{"", nil, "", false, -1},
+	}
+	for _, tt := range tests {
+		cr := CodeResult{Content: tt.content}
+		parseStackExchangeContent(tt.content, &cr)
+		if !slices.Equal(cr.Tags, tt.tags) || cr.Maintainer != tt.asker || cr.Content != "" {
+			t.Errorf("%q: tags %q, asker %q, content %q", tt.content, cr.Tags, cr.Maintainer, cr.Content)
+		}
+		if cr.Answered == nil || *cr.Answered != tt.answered {
+			t.Errorf("%q: answered = %v, want %v", tt.content, cr.Answered, tt.answered)
+		}
+		if tt.votes == -1 && cr.Votes != nil || tt.votes != -1 && (cr.Votes == nil || *cr.Votes != tt.votes) {
+			t.Errorf("%q: votes = %v, want %d", tt.content, cr.Votes, tt.votes)
+		}
+	}
+}
+
+func TestToCodeResultKinds(t *testing.T) {
+	repo := toCodeResult(1, SearXNGResult{
+		Title: "julienschmidt/httprouter", URL: "https://github.com/julienschmidt/httprouter",
+		Content: "Go / A high performance HTTP request router", Popularity: "16000", Engines: []string{"github"},
+	})
+	if repo.Kind != "repository" || repo.Stars != 16000 || repo.Language != "Go" || repo.Content != "A high performance HTTP request router" {
+		t.Errorf("repository = %+v", repo)
+	}
+
+	// A snippet whose first part isn't a language is left alone
+	repo = toCodeResult(1, SearXNGResult{Content: "A router / with a slash", Engines: []string{"gitlab"}})
+	if repo.Language != "" || repo.Content != "A router / with a slash" {
+		t.Errorf("repository without a language = %+v", repo)
+	}
+
+	question := toCodeResult(2, SearXNGResult{Content: "[go] alice // is answered // score: 5", Engines: []string{"stackoverflow"}})
+	if question.Kind != "question" || !*question.Answered || *question.Votes != 5 {
+		t.Errorf("question = %+v", question)
+	}
+	// Other StackExchange sites are recognized by the snippet's shape
+	question = toCodeResult(3, SearXNGResult{Content: "[latex] bob // score: 2", Engines: []string{"tex.stackexchange"}})
+	if question.Kind != "question" || question.Maintainer != "bob" {
+		t.Errorf("snippet-detected question = %+v", question)
+	}
+
+	pkg := toCodeResult(4, SearXNGResult{PackageName: "requests", Version: "2.32.3", Engines: []string{"pypi"}, PublishedDate: "2024-05-29T15:37:00Z"})
+	if pkg.Kind != "package" || pkg.Version != "2.32.3" || pkg.Updated != "2024-05-29" {
+		t.Errorf("package = %+v", pkg)
+	}
+
+	if page := toCodeResult(5, SearXNGResult{Content: "Package http provides ...", Engines: []string{"pkg.go.dev"}}); page.Kind != "page" {
+		t.Errorf("page kind = %s", page.Kind)
+	}
+}
@@ -0,0 +1,640 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the server configuration. It's built in layers, each
+// overriding the one before: defaults, the YAML config file, environment
+// variables and command-line flags.
+type Config struct {
+	SearXNG   SearXNGSettings   `yaml:"searxng"`
+	Proxy     ProxySettings     `yaml:"proxy"`
+	Cache     CacheSettings     `yaml:"cache"`
+	URLReader URLReaderSettings `yaml:"url_reader"`
+	Ranking   RankingSettings   `yaml:"ranking"`
+	Server    ServerSettings    `yaml:"server"`
+	Health    HealthSettings    `yaml:"health"`
+	Auth      AuthSettings      `yaml:"auth"`
+	Quotas    QuotaSettings     `yaml:"quotas"`
+	Audit     AuditSettings     `yaml:"audit"`
+
+	// File is the config file that was loaded, if any.
+	File string `yaml:"-"`
+	// sources records where each overridden setting came from, e.g.
+	// "cache.ttl" -> "env CACHE_TTL", for error messages.
+	sources map[string]string
+}
+
+type SearXNGSettings struct {
+	URL         string            `yaml:"url"`
+	Username    string            `yaml:"username"`
+	Password    string            `yaml:"password"`
+	BearerToken string            `yaml:"bearer_token"`
+	Headers     map[string]string `yaml:"headers"` // sent with every request, e.g. an API gateway key
+	// The *_file settings name files holding a secret instead, such as
+	// Docker secrets. They're read when the configuration is loaded.
+	PasswordFile    string            `yaml:"password_file"`
+	BearerTokenFile string            `yaml:"bearer_token_file"`
+	HeaderFiles     map[string]string `yaml:"header_files"`
+	// TLSCertFile and TLSKeyFile are a client certificate for mutual
+	// TLS; CAFile is a PEM bundle of CAs to trust instead of the system's.
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	CAFile      string `yaml:"ca_file"`
+	// ConfigRefresh is how often /config is re-fetched, in seconds; 0
+	// fetches it only at startup.
+	ConfigRefresh int `yaml:"config_refresh"`
+}
+
+type ProxySettings struct {
+	HTTP  string `yaml:"http"`
+	HTTPS string `yaml:"https"`
+}
+
+type CacheSettings struct {
+	TTL        int `yaml:"ttl"` // seconds
+	MaxEntries int `yaml:"max_entries"`
+}
+
+type URLReaderSettings struct {
+	Timeout  int `yaml:"timeout"` // seconds
+	MaxBytes int `yaml:"max_bytes"`
+}
+
+type RankingSettings struct {
+	DomainWeights       map[string]float64 `yaml:"domain_weights"`
+	RecencyWeight       float64            `yaml:"recency_weight"`
+	RecencyHalfLifeDays float64            `yaml:"recency_half_life_days"`
+}
+
+type ServerSettings struct {
+	Transport string `yaml:"transport"` // stdio or http
+	HTTPAddr  string `yaml:"http_addr"`
+}
+
+type HealthSettings struct {
+	// Interval is how often SearXNG is probed, in seconds; 0 probes it
+	// only at startup.
+	Interval int `yaml:"interval"`
+}
+
+// DefaultConfig returns the configuration used when nothing overrides it.
+func DefaultConfig() *Config {
+	return &Config{
+		SearXNG:   SearXNGSettings{ConfigRefresh: 600},
+		Cache:     CacheSettings{TTL: 60, MaxEntries: 500},
+		URLReader: URLReaderSettings{Timeout: 30, MaxBytes: 10 * 1024 * 1024},
+		Ranking:   RankingSettings{DomainWeights: map[string]float64{}, RecencyHalfLifeDays: 30},
+		Server:    ServerSettings{Transport: "stdio", HTTPAddr: ":8000"},
+		Health:    HealthSettings{Interval: 60},
+		Audit:     AuditSettings{MaxSizeMB: 100},
+		sources:   make(map[string]string),
+	}
+}
+
+// LoadConfig builds the configuration from the config file, the
+// environment and args (the command-line flags, without the program
+// name). The file is the -config flag, else MCP_SEARXNG_CONFIG; without
+// either, only the environment and flags are used.
+func LoadConfig(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("mcp-searxng-go", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), commandUsage+"\nServer flags:\n")
+		fs.PrintDefaults()
+	}
+	flags := addConfigFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return flags.load()
+}
+
+// configFlags are the command-line flags that override the
+// configuration, shared by the server and the subcommands.
+type configFlags struct {
+	fs         *flag.FlagSet
+	file       *string
+	searxngURL *string
+	transport  *string
+	addr       *string
+	cacheTTL   *int
+	cacheMax   *int
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	return &configFlags{
+		fs:         fs,
+		file:       fs.String("config", os.Getenv("MCP_SEARXNG_CONFIG"), "path to a YAML config file"),
+		searxngURL: fs.String("searxng-url", "", "SearXNG base URL"),
+		transport:  fs.String("transport", "", "MCP transport: stdio or http"),
+		addr:       fs.String("http-addr", "", "listen address in HTTP mode"),
+		cacheTTL:   fs.Int("cache-ttl", 0, "cache TTL in seconds"),
+		cacheMax:   fs.Int("cache-max-entries", 0, "maximum cache entries"),
+	}
+}
+
+// load builds and validates the configuration once the flags are parsed.
+func (f *configFlags) load() (*Config, error) {
+	config := DefaultConfig()
+
+	if *f.file != "" {
+		if err := config.loadFile(*f.file); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	config.applyEnv(&errs)
+
+	// Only flags given on the command line override the other layers
+	f.fs.Visit(func(fl *flag.Flag) {
+		var key string
+		switch fl.Name {
+		case "searxng-url":
+			key, config.SearXNG.URL = "searxng.url", *f.searxngURL
+		case "transport":
+			key, config.Server.Transport = "server.transport", *f.transport
+		case "http-addr":
+			key, config.Server.HTTPAddr = "server.http_addr", *f.addr
+		case "cache-ttl":
+			key, config.Cache.TTL = "cache.ttl", *f.cacheTTL
+		case "cache-max-entries":
+			key, config.Cache.MaxEntries = "cache.max_entries", *f.cacheMax
+		default:
+			return
+		}
+		config.sources[key] = "flag -" + fl.Name
+	})
+	config.readSecretFiles(&errs)
+
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, warning := range config.warnings() {
+			log.Printf("warning: %s", warning)
+		}
+	}
+	return config, errors.Join(errs...)
+}
+
+// loadFile decodes a YAML config file over the current settings.
+// Unknown keys are errors, so a typo doesn't silently do nothing.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	if c.Ranking.DomainWeights == nil {
+		c.Ranking.DomainWeights = map[string]float64{}
+	}
+	c.File = path
+
+	// Record the line of each setting the file sets
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err == nil && len(doc.Content) > 0 {
+		c.recordSources(doc.Content[0], "", path)
+	}
+	return nil
+}
+
+func (c *Config) recordSources(node *yaml.Node, prefix, path string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		c.sources[prefix+key.Value] = fmt.Sprintf("%s line %d", path, key.Line)
+		if prefix == "" {
+			c.recordSources(value, key.Value+".", path)
+		}
+	}
+}
+
+// applyEnv overrides settings from the environment. Values that don't
+// parse are appended to errs.
+func (c *Config) applyEnv(errs *[]error) {
+	strs := []struct {
+		env, key string
+		field    *string
+	}{
+		{"SEARXNG_URL", "searxng.url", &c.SearXNG.URL},
+		{"AUTH_USERNAME", "searxng.username", &c.SearXNG.Username},
+		{"AUTH_PASSWORD", "searxng.password", &c.SearXNG.Password},
+		{"AUTH_PASSWORD_FILE", "searxng.password_file", &c.SearXNG.PasswordFile},
+		{"SEARXNG_BEARER_TOKEN", "searxng.bearer_token", &c.SearXNG.BearerToken},
+		{"SEARXNG_BEARER_TOKEN_FILE", "searxng.bearer_token_file", &c.SearXNG.BearerTokenFile},
+		{"SEARXNG_TLS_CERT_FILE", "searxng.tls_cert_file", &c.SearXNG.TLSCertFile},
+		{"SEARXNG_TLS_KEY_FILE", "searxng.tls_key_file", &c.SearXNG.TLSKeyFile},
+		{"SEARXNG_CA_FILE", "searxng.ca_file", &c.SearXNG.CAFile},
+		{"HTTP_PROXY", "proxy.http", &c.Proxy.HTTP},
+		{"HTTPS_PROXY", "proxy.https", &c.Proxy.HTTPS},
+		{"MCP_TRANSPORT", "server.transport", &c.Server.Transport},
+		{"MCP_HTTP_ADDR", "server.http_addr", &c.Server.HTTPAddr},
+		{"MCP_AUTH_TOKENS_FILE", "auth.tokens_file", &c.Auth.TokensFile},
+		{"MCP_AUTH_HMAC_SECRET", "auth.hmac_secret", &c.Auth.HMACSecret},
+		{"MCP_AUTH_JWKS_FILE", "auth.jwks_file", &c.Auth.JWKSFile},
+		{"MCP_AUTH_ISSUER", "auth.issuer", &c.Auth.Issuer},
+		{"MCP_AUTH_AUDIENCE", "auth.audience", &c.Auth.Audience},
+		{"AUDIT_LOG_FILE", "audit.file", &c.Audit.File},
+	}
+	for _, s := range strs {
+		if v := os.Getenv(s.env); v != "" {
+			*s.field = v
+			c.sources[s.key] = "env " + s.env
+		}
+	}
+
+	ints := []struct {
+		env, key string
+		field    *int
+	}{
+		{"SEARXNG_CONFIG_REFRESH", "searxng.config_refresh", &c.SearXNG.ConfigRefresh},
+		{"CACHE_TTL", "cache.ttl", &c.Cache.TTL},
+		{"CACHE_MAX_ENTRIES", "cache.max_entries", &c.Cache.MaxEntries},
+		{"URL_READ_TIMEOUT", "url_reader.timeout", &c.URLReader.Timeout},
+		{"URL_READ_MAX_BYTES", "url_reader.max_bytes", &c.URLReader.MaxBytes},
+		{"HEALTH_CHECK_INTERVAL", "health.interval", &c.Health.Interval},
+		{"QUOTA_CALLS_PER_MINUTE", "quotas.calls_per_minute", &c.Quotas.CallsPerMinute},
+		{"QUOTA_CALLS_PER_DAY", "quotas.calls_per_day", &c.Quotas.CallsPerDay},
+		{"QUOTA_UPSTREAM_PER_MINUTE", "quotas.upstream_per_minute", &c.Quotas.UpstreamPerMinute},
+		{"QUOTA_UPSTREAM_PER_DAY", "quotas.upstream_per_day", &c.Quotas.UpstreamPerDay},
+		{"QUOTA_BYTES_PER_DAY", "quotas.bytes_per_day", &c.Quotas.BytesPerDay},
+		{"AUDIT_LOG_MAX_SIZE_MB", "audit.max_size_mb", &c.Audit.MaxSizeMB},
+		{"AUDIT_LOG_MAX_BACKUPS", "audit.max_backups", &c.Audit.MaxBackups},
+	}
+	for _, s := range ints {
+		if v := os.Getenv(s.env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("%s=%q: not an integer", s.env, v))
+				continue
+			}
+			*s.field = n
+			c.sources[s.key] = "env " + s.env
+		}
+	}
+
+	floats := []struct {
+		env, key string
+		field    *float64
+	}{
+		{"RANK_RECENCY_WEIGHT", "ranking.recency_weight", &c.Ranking.RecencyWeight},
+		{"RANK_RECENCY_HALF_LIFE_DAYS", "ranking.recency_half_life_days", &c.Ranking.RecencyHalfLifeDays},
+	}
+	for _, s := range floats {
+		if v := os.Getenv(s.env); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("%s=%q: not a number", s.env, v))
+				continue
+			}
+			*s.field = f
+			c.sources[s.key] = "env " + s.env
+		}
+	}
+
+	// RANK_DOMAIN_WEIGHTS="go.dev=2,pkg.go.dev=2,pinterest.com=0.1"
+	// replaces the file's weights rather than merging with them
+	if v := os.Getenv("RANK_DOMAIN_WEIGHTS"); v != "" {
+		weights := make(map[string]float64)
+		for _, pair := range strings.Split(v, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			domain, weight, ok := strings.Cut(pair, "=")
+			w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+			if !ok || err != nil {
+				*errs = append(*errs, fmt.Errorf("RANK_DOMAIN_WEIGHTS entry %q: expected domain=weight", pair))
+				continue
+			}
+			weights[strings.TrimSpace(domain)] = w
+		}
+		c.Ranking.DomainWeights = weights
+		c.sources["ranking.domain_weights"] = "env RANK_DOMAIN_WEIGHTS"
+	}
+
+	// SEARXNG_HEADERS="X-Api-Key=abc,X-Tenant=search" and
+	// SEARXNG_HEADER_FILES="X-Api-Key=/run/secrets/gateway_key" replace
+	// the file's headers
+	headers := []struct {
+		env, key string
+		field    *map[string]string
+	}{
+		{"SEARXNG_HEADERS", "searxng.headers", &c.SearXNG.Headers},
+		{"SEARXNG_HEADER_FILES", "searxng.header_files", &c.SearXNG.HeaderFiles},
+	}
+	for _, h := range headers {
+		v := os.Getenv(h.env)
+		if v == "" {
+			continue
+		}
+		values := make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				*errs = append(*errs, fmt.Errorf("%s entry %q: expected Name=value", h.env, pair))
+				continue
+			}
+			values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+		*h.field = values
+		c.sources[h.key] = "env " + h.env
+	}
+}
+
+// readSecretFiles reads the settings kept in files into the settings
+// they hold. Setting both a secret and its file is an error, since it's
+// unclear which was meant.
+func (c *Config) readSecretFiles(errs *[]error) {
+	read := func(fileKey, path, key string, field *string) {
+		if path == "" {
+			return
+		}
+		if *field != "" {
+			*errs = append(*errs, fmt.Errorf("%s: set %s or %s, not both%s", fileKey, key, fileKey, c.source(fileKey)))
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %v%s", fileKey, err, c.source(fileKey)))
+			return
+		}
+		*field = strings.TrimSpace(string(data))
+	}
+	read("searxng.password_file", c.SearXNG.PasswordFile, "searxng.password", &c.SearXNG.Password)
+	read("searxng.bearer_token_file", c.SearXNG.BearerTokenFile, "searxng.bearer_token", &c.SearXNG.BearerToken)
+
+	names := make([]string, 0, len(c.SearXNG.HeaderFiles))
+	for name := range c.SearXNG.HeaderFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if c.SearXNG.Headers == nil {
+			c.SearXNG.Headers = make(map[string]string)
+		}
+		value := c.SearXNG.Headers[name]
+		read("searxng.header_files", c.SearXNG.HeaderFiles[name], "searxng.headers "+name, &value)
+		c.SearXNG.Headers[name] = value
+	}
+}
+
+// source says where the setting key came from, for error messages.
+func (c *Config) source(key string) string {
+	if s, ok := c.sources[key]; ok {
+		return " (from " + s + ")"
+	}
+	return ""
+}
+
+// warnings lists settings that are accepted, for compatibility, but
+// probably don't do what was meant.
+func (c *Config) warnings() []string {
+	var warnings []string
+	// Before validation existed a lone username or password was ignored,
+	// so it stays a warning rather than an error
+	if c.SearXNG.Username != "" && c.SearXNG.Password == "" {
+		warnings = append(warnings, "searxng.username is set without searxng.password, so basic auth is disabled"+c.source("searxng.username"))
+	}
+	if c.SearXNG.Password != "" && c.SearXNG.Username == "" {
+		warnings = append(warnings, "searxng.password is set without searxng.username, so basic auth is disabled"+c.source("searxng.password"))
+	}
+	return warnings
+}
+
+// Validate checks every setting and reports all problems at once, each
+// naming the setting and where its value came from.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s%s", key, fmt.Sprintf(format, args...), c.source(key)))
+	}
+
+	if c.SearXNG.URL == "" {
+		fail("searxng.url", "is required; set SEARXNG_URL, -searxng-url or searxng.url in the config file")
+	} else if u, err := url.Parse(c.SearXNG.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("searxng.url", "%q must be an http:// or https:// URL", c.SearXNG.URL)
+	}
+	credentials := 0
+	if c.SearXNG.Username != "" && c.SearXNG.Password != "" {
+		credentials++
+	}
+	if c.SearXNG.BearerToken != "" {
+		credentials++
+	}
+	headers := make([]string, 0, len(c.SearXNG.Headers))
+	for name := range c.SearXNG.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		if strings.EqualFold(name, "Authorization") {
+			credentials++
+		}
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") || strings.ContainsAny(c.SearXNG.Headers[name], "\r\n") {
+			fail("searxng.headers", "header %q: names can't contain spaces or colons, nor values line breaks", name)
+		}
+	}
+	if credentials > 1 {
+		fail("searxng.bearer_token", "set only one of username/password, bearer_token or an Authorization header")
+	}
+	if (c.SearXNG.TLSCertFile == "") != (c.SearXNG.TLSKeyFile == "") {
+		fail("searxng.tls_cert_file", "tls_cert_file and tls_key_file must be set together")
+	} else if c.SearXNG.TLSCertFile != "" {
+		if _, err := tls.LoadX509KeyPair(c.SearXNG.TLSCertFile, c.SearXNG.TLSKeyFile); err != nil {
+			fail("searxng.tls_cert_file", "%v", err)
+		}
+	}
+	if c.SearXNG.CAFile != "" {
+		if _, err := loadCABundle(c.SearXNG.CAFile); err != nil {
+			fail("searxng.ca_file", "%v", err)
+		}
+	}
+	if (c.SearXNG.TLSCertFile != "" || c.SearXNG.CAFile != "") && strings.HasPrefix(c.SearXNG.URL, "http://") {
+		fail("searxng.url", "%q must be https:// for tls_cert_file and ca_file to apply", c.SearXNG.URL)
+	}
+	if c.SearXNG.ConfigRefresh < 0 {
+		fail("searxng.config_refresh", "must be 0 (disabled) or a number of seconds, got %d", c.SearXNG.ConfigRefresh)
+	}
+
+	for key, proxy := range map[string]string{"proxy.http": c.Proxy.HTTP, "proxy.https": c.Proxy.HTTPS} {
+		if proxy == "" {
+			continue
+		}
+		if u, err := url.Parse(proxy); err != nil || u.Scheme == "" || u.Host == "" {
+			fail(key, "%q is not a proxy URL such as http://proxy:8080", proxy)
+		}
+	}
+
+	if c.Cache.TTL <= 0 {
+		fail("cache.ttl", "must be a positive number of seconds, got %d", c.Cache.TTL)
+	}
+	if c.Cache.MaxEntries <= 0 {
+		fail("cache.max_entries", "must be positive, got %d", c.Cache.MaxEntries)
+	}
+
+	if c.URLReader.Timeout <= 0 {
+		fail("url_reader.timeout", "must be a positive number of seconds, got %d", c.URLReader.Timeout)
+	}
+	if c.URLReader.MaxBytes <= 0 {
+		fail("url_reader.max_bytes", "must be positive, got %d", c.URLReader.MaxBytes)
+	}
+
+	domains := make([]string, 0, len(c.Ranking.DomainWeights))
+	for domain := range c.Ranking.DomainWeights {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		if w := c.Ranking.DomainWeights[domain]; w < 0 || normalizeDomain(domain) == "" {
+			fail("ranking.domain_weights", "entry %s=%g: weights must be >= 0 for a non-empty domain", domain, w)
+		}
+	}
+	if c.Ranking.RecencyWeight < 0 {
+		fail("ranking.recency_weight", "must be >= 0, got %g", c.Ranking.RecencyWeight)
+	}
+	if c.Ranking.RecencyHalfLifeDays <= 0 {
+		fail("ranking.recency_half_life_days", "must be positive, got %g", c.Ranking.RecencyHalfLifeDays)
+	}
+
+	if c.Server.Transport != "stdio" && c.Server.Transport != "http" {
+		fail("server.transport", "unknown transport %q (use stdio or http)", c.Server.Transport)
+	}
+	if c.Server.Transport == "http" && c.Server.HTTPAddr == "" {
+		fail("server.http_addr", "is required in HTTP mode")
+	}
+
+	if c.Health.Interval < 0 {
+		fail("health.interval", "must be 0 (disabled) or a number of seconds, got %d", c.Health.Interval)
+	}
+
+	// Load the token files now, so a bad one fails at startup (or
+	// rejects a reload) rather than on the first request
+	if c.Auth.TokensFile != "" {
+		if _, err := loadStaticTokens(c.Auth.TokensFile); err != nil {
+			fail("auth.tokens_file", "%v", err)
+		}
+	}
+	if c.Auth.HMACSecret != "" && len(c.Auth.HMACSecret) < minHMACSecret {
+		fail("auth.hmac_secret", "must be at least %d characters", minHMACSecret)
+	}
+	if c.Auth.JWKSFile != "" {
+		if _, err := loadJWKS(c.Auth.JWKSFile); err != nil {
+			fail("auth.jwks_file", "%v", err)
+		}
+	}
+	if (c.Auth.Issuer != "" || c.Auth.Audience != "") && c.Auth.HMACSecret == "" && c.Auth.JWKSFile == "" {
+		fail("auth.issuer", "issuer and audience only apply to JWTs; set auth.jwks_file or auth.hmac_secret")
+	}
+
+	checkQuotas := func(client string, limits QuotaLimits) {
+		fields := []struct {
+			name string
+			n    int
+		}{
+			{"calls_per_minute", limits.CallsPerMinute},
+			{"calls_per_day", limits.CallsPerDay},
+			{"upstream_per_minute", limits.UpstreamPerMinute},
+			{"upstream_per_day", limits.UpstreamPerDay},
+			{"bytes_per_day", limits.BytesPerDay},
+		}
+		for _, f := range fields {
+			switch {
+			case f.n >= 0:
+			case client == "":
+				fail("quotas."+f.name, "must be 0 (unlimited) or positive, got %d", f.n)
+			default:
+				fail("quotas.clients", "%s: %s must be 0 (unlimited) or positive, got %d", client, f.name, f.n)
+			}
+		}
+	}
+	checkQuotas("", c.Quotas.QuotaLimits)
+	clients := make([]string, 0, len(c.Quotas.Clients))
+	for client := range c.Quotas.Clients {
+		clients = append(clients, client)
+	}
+	sort.Strings(clients)
+	for _, client := range clients {
+		checkQuotas(client, c.Quotas.Clients[client])
+	}
+
+	if c.Audit.File != "" {
+		if info, err := os.Stat(filepath.Dir(c.Audit.File)); err != nil || !info.IsDir() {
+			fail("audit.file", "directory %s doesn't exist", filepath.Dir(c.Audit.File))
+		}
+	}
+	if c.Audit.MaxSizeMB < 0 {
+		fail("audit.max_size_mb", "must be 0 (never rotate) or a number of megabytes, got %d", c.Audit.MaxSizeMB)
+	}
+	if c.Audit.MaxBackups < 0 {
+		fail("audit.max_backups", "must be 0 (keep all) or positive, got %d", c.Audit.MaxBackups)
+	}
+	if _, err := compileRedactions(c.Audit.Redact); err != nil {
+		fail("audit.redact", "%v", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// redacted returns a copy of the config with secrets that are set
+// replaced by mask.
+func (c *Config) redacted(mask string) *Config {
+	redacted := *c
+	if redacted.SearXNG.Password != "" {
+		redacted.SearXNG.Password = mask
+	}
+	if redacted.SearXNG.BearerToken != "" {
+		redacted.SearXNG.BearerToken = mask
+	}
+	if len(redacted.SearXNG.Headers) > 0 {
+		headers := make(map[string]string, len(redacted.SearXNG.Headers))
+		for name := range redacted.SearXNG.Headers {
+			headers[name] = mask
+		}
+		redacted.SearXNG.Headers = headers
+	}
+	if redacted.Auth.HMACSecret != "" {
+		redacted.Auth.HMACSecret = mask
+	}
+	return &redacted
+}
+
+// hasCredentials reports whether requests to SearXNG carry credentials:
+// basic auth, a bearer token or configured headers such as an API key.
+func (s SearXNGSettings) hasCredentials() bool {
+	return s.Username != "" && s.Password != "" || s.BearerToken != "" || len(s.Headers) > 0
+}
+
+func (s SearXNGSettings) refreshInterval() time.Duration {
+	return time.Duration(s.ConfigRefresh) * time.Second
+}
+
+func (s HealthSettings) interval() time.Duration {
+	return time.Duration(s.Interval) * time.Second
+}
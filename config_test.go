@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoneBasicAuthCredentialIsAWarning(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "http://localhost:8080")
+	t.Setenv("AUTH_USERNAME", "admin")
+	t.Setenv("AUTH_PASSWORD", "")
+
+	config, err := LoadConfig(nil)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	warnings := config.warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "searxng.username is set without searxng.password") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	t.Setenv("AUTH_PASSWORD", "secret")
+	if config, err = LoadConfig(nil); err != nil || len(config.warnings()) != 0 {
+		t.Errorf("username and password: err = %v, warnings = %q", err, config.warnings())
+	}
+}
+
+func TestValidateReportsEverySetting(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "ftp://example.com")
+	t.Setenv("CACHE_TTL", "-1")
+
+	_, err := LoadConfig([]string{"-transport", "grpc"})
+	if err == nil {
+		t.Fatal("LoadConfig accepted an invalid configuration")
+	}
+	for _, want := range []string{"searxng.url", "cache.ttl", "server.transport: unknown transport \"grpc\" (use stdio or http) (from flag -transport)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	t.Setenv("SEARXNG_URL", "http://localhost:8080")
+	t.Setenv("SEARXNG_BEARER_TOKEN_FILE", write("token", "  tok\n"))
+	t.Setenv("SEARXNG_HEADERS", "X-Tenant=search")
+	t.Setenv("SEARXNG_HEADER_FILES", "X-Api-Key="+write("api_key", "k3y\n"))
+
+	config, err := LoadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := config.SearXNG
+	if s.BearerToken != "tok" {
+		t.Errorf("bearer token = %q", s.BearerToken)
+	}
+	if len(s.Headers) != 2 || s.Headers["X-Api-Key"] != "k3y" || s.Headers["X-Tenant"] != "search" {
+		t.Errorf("headers = %v", s.Headers)
+	}
+	printed, _ := yaml.Marshal(config.redacted("(redacted)"))
+	if strings.Contains(string(printed), ": tok\n") || strings.Contains(string(printed), "k3y") {
+		t.Errorf("redacted config shows secrets:\n%s", printed)
+	}
+
+	t.Setenv("SEARXNG_BEARER_TOKEN_FILE", "")
+	t.Setenv("AUTH_USERNAME", "admin")
+	t.Setenv("AUTH_PASSWORD_FILE", write("password", "hunter2\n"))
+	if config, err = LoadConfig(nil); err != nil || config.SearXNG.Password != "hunter2" {
+		t.Errorf("password from file: %v", err)
+	}
+
+	// A secret and its file together, or an unreadable file, are errors
+	t.Setenv("AUTH_PASSWORD", "other")
+	t.Setenv("SEARXNG_HEADERS", "X-Api-Key=inline")
+	t.Setenv("SEARXNG_BEARER_TOKEN_FILE", filepath.Join(dir, "missing"))
+	_, err = LoadConfig(nil)
+	for _, want := range []string{
+		"searxng.password_file: set searxng.password or searxng.password_file, not both",
+		"searxng.header_files: set searxng.headers X-Api-Key or searxng.header_files, not both",
+		"searxng.bearer_token_file: open " + filepath.Join(dir, "missing"),
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v doesn't mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// cursorAnchorLen is how much text following a cursor's offset is kept
+// in the cursor, so the position can be found again if the cached page
+// was refreshed and its content shifted.
+const cursorAnchorLen = 64
+
+// readCursor is the state carried by a url_read continuation cursor. It
+// is handed to the caller as opaque base64 and passed back verbatim.
+type readCursor struct {
+	URL            string `json:"u"`
+	Section        string `json:"s,omitempty"`
+	ParagraphRange string `json:"p,omitempty"`
+	MaxLength      int    `json:"l,omitempty"`
+	MaxTokens      int    `json:"t,omitempty"`
+	Offset         int    `json:"o"`
+	Chunk          int    `json:"c"`
+	DocHash        string `json:"h"`
+	Anchor         string `json:"a,omitempty"`
+}
+
+func encodeReadCursor(c *readCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeReadCursor(s string) (*readCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	var c readCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if c.URL == "" || c.Offset < 0 {
+		return nil, fmt.Errorf("invalid cursor: missing url or offset")
+	}
+
+	return &c, nil
+}
+
+// applyTo fills in the url_read arguments the cursor was issued for.
+// Explicit maxLength/maxTokens from the caller win, so a caller can
+// change chunk size mid-document.
+func (c *readCursor) applyTo(args URLReadArgs) (URLReadArgs, error) {
+	if args.URL != "" && args.URL != c.URL {
+		return args, fmt.Errorf("cursor was issued for %s, not %s", c.URL, args.URL)
+	}
+
+	args.URL = c.URL
+	args.Section = c.Section
+	args.ParagraphRange = c.ParagraphRange
+	args.StartChar = c.Offset
+	if args.MaxLength == 0 {
+		args.MaxLength = c.MaxLength
+	}
+	if args.MaxTokens == 0 {
+		args.MaxTokens = c.MaxTokens
+	}
+
+	return args, nil
+}
+
+// locate returns the character offset in content at which the cursor
+// should resume. If content is the same document the cursor was issued
+// against, that's just the stored offset. Otherwise the cache was
+// refreshed in between, so the anchor text is searched for and the
+// occurrence nearest the old offset wins. The second return value
+// reports whether such a re-sync happened.
+func (c *readCursor) locate(content string) (int, bool) {
+	total := utf8.RuneCountInString(content)
+	if documentHash(content) == c.DocHash {
+		return min(c.Offset, total), false
+	}
+
+	best := -1
+	if c.Anchor != "" {
+		runes := 0
+		prev := 0
+		for from := 0; from < len(content); {
+			idx := strings.Index(content[from:], c.Anchor)
+			if idx < 0 {
+				break
+			}
+			pos := from + idx
+			runes += utf8.RuneCountInString(content[prev:pos])
+			prev = pos
+			if best < 0 || absInt(runes-c.Offset) < absInt(best-c.Offset) {
+				best = runes
+			}
+			_, size := utf8.DecodeRuneInString(content[pos:])
+			from = pos + size
+		}
+	}
+	if best < 0 {
+		best = min(c.Offset, total)
+	}
+
+	return best, true
+}
+
+// nextReadCursor builds the cursor for the chunk following one that
+// ended at character offset end of content.
+func nextReadCursor(args URLReadArgs, content string, end, chunk int) *readCursor {
+	endByte := byteOffset(content, end)
+	anchor := content[endByte:min(endByte+cursorAnchorLen, len(content))]
+	for !utf8.ValidString(anchor) && anchor != "" {
+		anchor = anchor[:len(anchor)-1]
+	}
+
+	return &readCursor{
+		URL:            args.URL,
+		Section:        args.Section,
+		ParagraphRange: args.ParagraphRange,
+		MaxLength:      args.MaxLength,
+		MaxTokens:      args.MaxTokens,
+		Offset:         end,
+		Chunk:          chunk,
+		DocHash:        documentHash(content),
+		Anchor:         anchor,
+	}
+}
+
+func documentHash(content string) string {
+	h := fnv.New64a()
+	h.Write([]byte(content))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
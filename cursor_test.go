@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var cursorRegex = regexp.MustCompile(`cursor="([^"]+)"`)
+
+// nextCursor decodes the continuation cursor in a chunk's footer, or
+// returns nil if there is none.
+func nextCursor(t *testing.T, chunk string) *readCursor {
+	t.Helper()
+	m := cursorRegex.FindStringSubmatch(chunk)
+	if m == nil {
+		return nil
+	}
+	cursor, err := decodeReadCursor(m[1])
+	if err != nil {
+		t.Fatalf("footer cursor: %v", err)
+	}
+	return cursor
+}
+
+func TestDecodeReadCursor(t *testing.T) {
+	c := &readCursor{URL: "https://example.com/a", Section: "s-1", MaxTokens: 50, Offset: 120, Chunk: 3, DocHash: "abc", Anchor: "résumé 😀"}
+	got, err := decodeReadCursor(" " + encodeReadCursor(c) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *c {
+		t.Errorf("round trip = %+v, want %+v", got, c)
+	}
+
+	for name, s := range map[string]string{
+		"not base64":      "!!!",
+		"not JSON":        base64.RawURLEncoding.EncodeToString([]byte("nope")),
+		"no url":          base64.RawURLEncoding.EncodeToString([]byte(`{"o":3}`)),
+		"negative offset": base64.RawURLEncoding.EncodeToString([]byte(`{"u":"https://example.com","o":-1}`)),
+	} {
+		if _, err := decodeReadCursor(s); err == nil {
+			t.Errorf("%s: decodeReadCursor(%q) succeeded", name, s)
+		}
+	}
+}
+
+func TestReadCursorApplyTo(t *testing.T) {
+	c := &readCursor{URL: "https://example.com/a", Section: "s-2", MaxLength: 500, MaxTokens: 100, Offset: 42}
+
+	args, err := c.applyTo(URLReadArgs{MaxTokens: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.URL != c.URL || args.Section != "s-2" || args.StartChar != 42 || args.MaxLength != 500 {
+		t.Errorf("applyTo = %+v", args)
+	}
+	if args.MaxTokens != 20 {
+		t.Errorf("maxTokens = %d, want the caller's 20", args.MaxTokens)
+	}
+
+	if _, err := c.applyTo(URLReadArgs{URL: "https://example.com/b"}); err == nil {
+		t.Error("a cursor for another URL was accepted")
+	}
+}
+
+func TestCursorResumesWhereTheChunkEnded(t *testing.T) {
+	for name, doc := range paginationDocs {
+		t.Run(name, func(t *testing.T) {
+			args := URLReadArgs{URL: "https://example.com/doc", MaxLength: 300}
+			var text string
+			var cursor *readCursor
+			for chunk := 1; ; chunk++ {
+				if chunk > len(doc) {
+					t.Fatal("runaway pagination")
+				}
+				if cursor != nil {
+					var err error
+					if args, err = cursor.applyTo(URLReadArgs{}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				out := readChunk(doc, args, cursor)
+				body, footer, _ := strings.Cut(out, "\n\n---\n*Chunk ")
+				if !strings.HasPrefix(footer, strconv.Itoa(chunk)+" ") {
+					t.Fatalf("chunk %d is numbered %q", chunk, footer)
+				}
+				text += body
+				if cursor = nextCursor(t, out); cursor == nil {
+					break
+				}
+				if cursor.Offset != utf8.RuneCountInString(text) {
+					t.Fatalf("cursor resumes at %d after %d characters", cursor.Offset, utf8.RuneCountInString(text))
+				}
+			}
+			if text != doc {
+				t.Error("following the cursors didn't read the document exactly once")
+			}
+		})
+	}
+}
+
+func TestCursorResyncsAfterThePageChanged(t *testing.T) {
+	doc := strings.Repeat("Filler paragraph. ", 40) + "The quick brown fox jumps over the lazy dog. " + strings.Repeat("Tail text. ", 40)
+	cursor := nextReadCursor(URLReadArgs{URL: "https://example.com"}, doc, strings.Index(doc, "The quick"), 1)
+
+	// Unchanged: the stored offset is used as is
+	if pos, resynced := cursor.locate(doc); pos != cursor.Offset || resynced {
+		t.Errorf("locate on the same page = %d, %v", pos, resynced)
+	}
+
+	// Text was inserted before the position: the anchor is found again
+	changed := "A new banner. " + doc
+	pos, resynced := cursor.locate(changed)
+	if !resynced || !strings.HasPrefix(changed[byteOffset(changed, pos):], "The quick brown fox") {
+		t.Errorf("locate on the changed page = %d, %v", pos, resynced)
+	}
+	out := readChunk(changed, URLReadArgs{URL: "https://example.com", MaxLength: 50}, cursor)
+	if !strings.HasPrefix(out, "The quick brown fox") || !strings.Contains(out, "resumed at the matching text") {
+		t.Errorf("resynced chunk = %q", out)
+	}
+
+	// The anchor is gone: fall back to the stored offset, within bounds
+	if pos, _ := cursor.locate("short"); pos != len("short") {
+		t.Errorf("locate without the anchor = %d, want the end of the page", pos)
+	}
+}
+
+func TestCursorKeepsTheSection(t *testing.T) {
+	doc := "# Intro\n\n" + strings.Repeat("Intro text. ", 30) + "\n\n# Usage\n\n" + strings.Repeat("Usage text. ", 60) + "\n\n# Other\n\nOther text.\n"
+	args := URLReadArgs{URL: "https://example.com", Section: "Usage", MaxLength: 200}
+
+	first, err := applyPaginationOptions(doc, args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := nextCursor(t, first)
+	if cursor == nil || cursor.Section != "Usage" {
+		t.Fatalf("cursor = %+v, want one for the Usage section", cursor)
+	}
+	resumed, err := cursor.applyTo(URLReadArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := applyPaginationOptions(doc, resumed, cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(second, "Intro text") || strings.Contains(second, "Other text") || !strings.Contains(second, "Usage text") {
+		t.Errorf("second chunk left the section: %q", second)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrorCode is a machine-readable failure category.
+type ErrorCode string
+
+const (
+	ErrInvalidArgument  ErrorCode = "invalid_argument"
+	ErrTimeout          ErrorCode = "timeout"
+	ErrCancelled        ErrorCode = "cancelled"
+	ErrDNS              ErrorCode = "dns_failure"
+	ErrConnection       ErrorCode = "connection_failed"
+	ErrTLS              ErrorCode = "tls_error"
+	ErrBlocked          ErrorCode = "blocked_by_policy"
+	ErrPermissionDenied ErrorCode = "permission_denied"
+	ErrRateLimited      ErrorCode = "rate_limited"
+	ErrQuotaExceeded    ErrorCode = "quota_exceeded"
+	ErrUpstream4xx      ErrorCode = "upstream_4xx"
+	ErrUpstream5xx      ErrorCode = "upstream_5xx"
+	ErrTooLarge         ErrorCode = "content_too_large"
+	ErrUnsupportedType  ErrorCode = "unsupported_type"
+	ErrInvalidResponse  ErrorCode = "invalid_response"
+	ErrNetwork          ErrorCode = "network_error"
+	ErrInternal         ErrorCode = "internal"
+)
+
+// errorHints tell an agent what to do about each kind of failure, and
+// whether retrying the same call can help.
+var errorHints = map[ErrorCode]struct {
+	retryable bool
+	hint      string
+}{
+	ErrInvalidArgument:  {false, "fix the arguments and call again"},
+	ErrTimeout:          {true, "retry, possibly later; the server may be slow or overloaded"},
+	ErrCancelled:        {false, "the request was cancelled by the client"},
+	ErrDNS:              {false, "the host name doesn't resolve; check the URL for typos"},
+	ErrConnection:       {true, "the server refused or dropped the connection; retry later"},
+	ErrTLS:              {false, "the server's certificate or TLS setup is invalid; try another source"},
+	ErrBlocked:          {false, "access was refused (authentication, bot protection or legal block); try another source"},
+	ErrPermissionDenied: {false, "this client's token isn't scoped for the tool or resource; it needs the tools:<name> or tools:* scope, or resources:<scheme> or resources:*"},
+	ErrRateLimited:      {true, "wait before retrying"},
+	ErrQuotaExceeded:    {true, "this client has used its quota on this server; wait until it resets (retryAfterSeconds) and make fewer calls"},
+	ErrUpstream4xx:      {false, "the request was rejected; the page may not exist, so try another source"},
+	ErrUpstream5xx:      {true, "the server had an error; retry later"},
+	ErrTooLarge:         {false, "the content exceeds the size limit; try another source"},
+	ErrUnsupportedType:  {false, "only text and HTML content can be read; try another source"},
+	ErrInvalidResponse:  {false, "the server returned something unexpected; check that it is the right service"},
+	ErrNetwork:          {true, "a network error occurred; retry"},
+	ErrInternal:         {false, "an internal error occurred"},
+}
+
+// ToolError is a classified failure, reported to agents in both the text
+// and the structured output of a tool call.
+type ToolError struct {
+	Code              ErrorCode `json:"code" jsonschema:"machine-readable error category, e.g. timeout, rate_limited, upstream_5xx"`
+	Message           string    `json:"message"`
+	Retryable         bool      `json:"retryable" jsonschema:"whether retrying the same call may succeed"`
+	Hint              string    `json:"hint,omitempty"`
+	Status            int       `json:"status,omitempty" jsonschema:"upstream HTTP status, if the failure came from one"`
+	RetryAfterSeconds int       `json:"retryAfterSeconds,omitempty" jsonschema:"how long the upstream asked us to wait, if it said"`
+
+	cause error
+}
+
+func (e *ToolError) Error() string {
+	return e.Message
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.cause
+}
+
+func newToolError(code ErrorCode, cause error, format string, args ...any) *ToolError {
+	info := errorHints[code]
+	return &ToolError{
+		Code:      code,
+		Message:   fmt.Sprintf(format, args...),
+		Retryable: info.retryable,
+		Hint:      info.hint,
+		cause:     cause,
+	}
+}
+
+// invalidArgument reports a bad tool argument.
+func invalidArgument(format string, args ...any) *ToolError {
+	return newToolError(ErrInvalidArgument, nil, format, args...)
+}
+
+// httpStatusError classifies a non-200 response from what (e.g. "SearXNG").
+func httpStatusError(resp *http.Response, what string, body string) *ToolError {
+	message := fmt.Sprintf("%s returned status %d", what, resp.StatusCode)
+	if body = truncateForLog(body, 200); body != "" {
+		message += ": " + body
+	}
+
+	var code ErrorCode
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		code = ErrRateLimited
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusGatewayTimeout:
+		code = ErrTimeout
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden ||
+		resp.StatusCode == http.StatusProxyAuthRequired || resp.StatusCode == http.StatusUnavailableForLegalReasons:
+		code = ErrBlocked
+	case resp.StatusCode == http.StatusRequestEntityTooLarge:
+		code = ErrTooLarge
+	case resp.StatusCode == http.StatusUnsupportedMediaType || resp.StatusCode == http.StatusNotAcceptable:
+		code = ErrUnsupportedType
+	case resp.StatusCode >= 500:
+		code = ErrUpstream5xx
+	default:
+		code = ErrUpstream4xx
+	}
+
+	e := newToolError(code, nil, "%s", message)
+	e.Status = resp.StatusCode
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		e.RetryAfterSeconds = seconds
+		e.Retryable = true
+	}
+	if resp.StatusCode == http.StatusNotImplemented {
+		e.Retryable = false
+	}
+	return e
+}
+
+// classifyError turns any error into a ToolError, recognizing timeouts,
+// DNS, connection and TLS failures through wrapped errors.
+func classifyError(err error) *ToolError {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return toolErr
+	}
+
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var rootsErr x509.SystemRootsError
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return newToolError(ErrCancelled, err, "%v", err)
+	case errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err):
+		return newToolError(ErrTimeout, err, "%v", err)
+	case errors.As(err, &dnsErr):
+		e := newToolError(ErrDNS, err, "cannot resolve host %q", dnsErr.Name)
+		// A DNS timeout or a flaky resolver is worth retrying; a name
+		// that doesn't exist isn't.
+		e.Retryable = dnsErr.IsTemporary || dnsErr.IsTimeout
+		return e
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidCert), errors.As(err, &recordErr), errors.As(err, &alertErr), errors.As(err, &rootsErr),
+		// crypto/tls reports alerts after the handshake as these ops
+		errors.As(err, &opErr) && (opErr.Op == "remote error" || opErr.Op == "local error"):
+		return newToolError(ErrTLS, err, "%v", err)
+	case errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH) || (errors.As(err, &opErr) && opErr.Op == "dial"):
+		return newToolError(ErrConnection, err, "%v", err)
+	case errors.As(err, &opErr):
+		return newToolError(ErrNetwork, err, "%v", err)
+	}
+
+	return newToolError(ErrInternal, err, "%v", err)
+}
+
+// toolErrorResult renders a ToolError as a tool result. prefix says what
+// failed, e.g. "Search failed"; it's omitted for argument errors, whose
+// message already says what's wrong.
+func toolErrorResult(prefix string, e *ToolError) *mcp.CallToolResult {
+	text := e.Message
+	if prefix != "" && e.Code != ErrInvalidArgument {
+		text = prefix + ": " + text
+	}
+
+	retry := "not retryable"
+	if e.Retryable {
+		retry = "retryable"
+		if e.RetryAfterSeconds > 0 {
+			retry += fmt.Sprintf(" after %ds", e.RetryAfterSeconds)
+		}
+	}
+	text += fmt.Sprintf("\n\n[error code: %s, %s]", e.Code, retry)
+	if e.Hint != "" {
+		text += "\nHint: " + e.Hint
+	}
+
+	return &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: text},
+		},
+	}
+}
+
+// ErrorOutput is the structured output of a failed call for tools that
+// have no structured output of their own.
+type ErrorOutput struct {
+	Error *ToolError `json:"error"`
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+)
+
+// tlsFailure makes a real TLS request that fails the way configure
+// sets up, and returns the transport's error.
+func tlsFailure(t *testing.T, configure func(srv *httptest.Server, client *http.Client) string) error {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{}}}
+	url := configure(srv, client)
+	resp, err := client.Get(url)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request succeeded")
+	}
+	return err
+}
+
+func TestClassifyError(t *testing.T) {
+	terr := newToolError(ErrRateLimited, nil, "slow down")
+	tests := []struct {
+		name      string
+		err       error
+		code      ErrorCode
+		retryable bool
+	}{
+		{"tool error", fmt.Errorf("wrapped: %w", terr), ErrRateLimited, true},
+		{"cancelled", fmt.Errorf("get: %w", context.Canceled), ErrCancelled, false},
+		{"deadline", fmt.Errorf("get: %w", context.DeadlineExceeded), ErrTimeout, true},
+		{"no such host", &net.DNSError{Err: "no such host", Name: "nope.invalid", IsNotFound: true}, ErrDNS, false},
+		{"dns timeout", &net.DNSError{Err: "i/o timeout", Name: "slow.example", IsTimeout: true}, ErrTimeout, true},
+		{"flaky resolver", &net.DNSError{Err: "server misbehaving", Name: "x.example", IsTemporary: true}, ErrDNS, true},
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, ErrConnection, true},
+		{"reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, ErrConnection, true},
+		{"read failure", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("broken")}, ErrNetwork, true},
+		{"tls alert", fmt.Errorf("handshake: %w", tls.AlertError(40)), ErrTLS, false},
+		{"remote tls alert", &net.OpError{Op: "remote error", Err: errors.New("tls: bad certificate")}, ErrTLS, false},
+		{"record header", tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, ErrTLS, false},
+		// Only TLS error types count, not any message that mentions TLS
+		{"tls in a message", errors.New("tls: sounds like TLS"), ErrInternal, false},
+		{"plain", errors.New("something broke"), ErrInternal, false},
+	}
+	for _, tt := range tests {
+		got := classifyError(tt.err)
+		if got.Code != tt.code || got.Retryable != tt.retryable {
+			t.Errorf("%s: classified as %s (retryable %v), want %s (retryable %v)", tt.name, got.Code, got.Retryable, tt.code, tt.retryable)
+		}
+	}
+	if classifyError(terr) != terr {
+		t.Error("a *ToolError isn't passed through unchanged")
+	}
+}
+
+func TestClassifyTLSErrors(t *testing.T) {
+	tests := map[string]func(srv *httptest.Server, client *http.Client) string{
+		"unknown authority": func(srv *httptest.Server, client *http.Client) string {
+			return srv.URL
+		},
+		"wrong host name": func(srv *httptest.Server, client *http.Client) string {
+			client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs, ServerName: "other.example"}}
+			return srv.URL
+		},
+		"protocol version": func(srv *httptest.Server, client *http.Client) string {
+			srv.TLS.MinVersion = tls.VersionTLS13
+			client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}}
+			return srv.URL
+		},
+	}
+	for name, configure := range tests {
+		err := tlsFailure(t, configure)
+		if got := classifyError(err); got.Code != ErrTLS {
+			t.Errorf("%s: %v classified as %s, want %s", name, err, got.Code, ErrTLS)
+		}
+	}
+}
+
+func TestFetchImageErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>not an image</body></html>"))
+		case "/big.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(make([]byte, 2048))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	reader := NewURLReader(URLReaderSettings{Timeout: 5}, nil, nil)
+
+	tests := []struct {
+		url  string
+		code ErrorCode
+	}{
+		{"ftp://example.com/a.png", ErrInvalidArgument},
+		{"http://[::1", ErrInvalidArgument},
+		{srv.URL + "/page", ErrUnsupportedType},
+		{srv.URL + "/big.png", ErrTooLarge},
+		{srv.URL + "/missing.png", ErrUpstream4xx},
+	}
+	for _, tt := range tests {
+		_, _, err := reader.FetchImage(context.Background(), tt.url, 1024)
+		var terr *ToolError
+		if !errors.As(err, &terr) || terr.Code != tt.code {
+			t.Errorf("FetchImage(%s) = %v, want a %s *ToolError", tt.url, err, tt.code)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultFindContext = 200
+	maxFindContext     = 2000
+	maxFindMatches     = 20
+)
+
+// findPassage is a window of a document around one or more matches.
+// Offsets are in characters (runes), like startChar.
+type findPassage struct {
+	Offset  int // of the first match in the passage
+	Matches int
+	Path    []string // heading path the first match sits under
+	Text    string
+
+	start, end int // byte range of Text
+	clipped    [2]bool
+}
+
+// compileFindPattern builds the matcher for url_read's find option. A
+// plain phrase is matched literally and case-insensitively; with regex
+// set it is used as a Go regular expression as given.
+func compileFindPattern(find string, regex bool) (*regexp.Regexp, error) {
+	if !regex {
+		return regexp.MustCompile(`(?i)` + regexp.QuoteMeta(find)), nil
+	}
+
+	re, err := regexp.Compile(find)
+	if err != nil {
+		return nil, fmt.Errorf("invalid find pattern: %w", err)
+	}
+	return re, nil
+}
+
+// findPassages returns the passages of content matching re, each with
+// contextChars characters either side of the match. Overlapping
+// passages are merged, up to maxFindMatches passages. The second return
+// value is the total number of matches, which may exceed what the
+// returned passages cover.
+func findPassages(content string, re *regexp.Regexp, contextChars int) ([]findPassage, int) {
+	matches := re.FindAllStringIndex(content, -1)
+	toc := buildTOC(content)
+
+	var passages []findPassage
+	total := 0
+	runes := 0
+	prev := 0
+
+	for _, m := range matches {
+		if m[0] == m[1] {
+			continue // empty matches (e.g. "a*") aren't useful passages
+		}
+		total++
+
+		start := m[0]
+		for n := 0; n < contextChars && start > 0; n++ {
+			_, size := utf8.DecodeLastRuneInString(content[:start])
+			start -= size
+		}
+		end := m[1]
+		for n := 0; n < contextChars && end < len(content); n++ {
+			_, size := utf8.DecodeRuneInString(content[end:])
+			end += size
+		}
+
+		if last := len(passages) - 1; last >= 0 && start <= passages[last].end {
+			// Once the cap is reached the last passage stops growing, so
+			// a run of matches can't stretch it over the rest of the page;
+			// it only counts the matches it already shows
+			if len(passages) < maxFindMatches {
+				passages[last].end = max(passages[last].end, end)
+			} else if m[1] > passages[last].end {
+				continue
+			}
+			passages[last].Matches++
+			continue
+		}
+		if len(passages) == maxFindMatches {
+			continue
+		}
+
+		runes += utf8.RuneCountInString(content[prev:m[0]])
+		prev = m[0]
+		passages = append(passages, findPassage{
+			Offset:  runes,
+			Matches: 1,
+			Path:    headingPathAt(toc, m[0]),
+			start:   start,
+			end:     end,
+		})
+	}
+
+	for i := range passages {
+		p := &passages[i]
+		p.Text = content[p.start:p.end]
+		p.clipped = [2]bool{p.start > 0, p.end < len(content)}
+	}
+
+	return passages, total
+}
+
+// headingPathAt returns the heading path of the section containing the
+// byte offset pos, or nil if pos precedes the first heading.
+func headingPathAt(toc []tocEntry, pos int) []string {
+	var path []string
+	for _, e := range toc {
+		if e.start > pos {
+			break
+		}
+		path = e.Path
+	}
+	return path
+}
+
+// renderFindResults formats find passages as Markdown.
+func renderFindResults(pattern string, passages []findPassage, total int) string {
+	if total == 0 {
+		return fmt.Sprintf("# No Matches\n\nNo matches for \"%s\" in this page.", pattern)
+	}
+
+	output := fmt.Sprintf("# Matches for \"%s\"\n\n", pattern)
+	output += fmt.Sprintf("Found %d matches in %d passages", total, len(passages))
+	if len(passages) == maxFindMatches {
+		output += fmt.Sprintf(" (showing the first %d passages; narrow the pattern or use section to see more)", maxFindMatches)
+	}
+	output += "\n\n"
+
+	for i, p := range passages {
+		output += fmt.Sprintf("## %d. Offset %d", i+1, p.Offset)
+		if len(p.Path) > 0 {
+			output += " · " + strings.Join(p.Path, " > ")
+		}
+		if p.Matches > 1 {
+			output += fmt.Sprintf(" · %d matches", p.Matches)
+		}
+		output += "\n\n"
+
+		text := strings.TrimSpace(p.Text)
+		if p.clipped[0] {
+			text = "…" + text
+		}
+		if p.clipped[1] {
+			text += "…"
+		}
+		output += text + "\n\n---\n\n"
+	}
+
+	return output
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFindPassagesMerge(t *testing.T) {
+	content := "# Title\n\n" + "alpha beta alpha " + strings.Repeat("filler ", 20) + "\n\n## Part\n\nalpha at the end 😀 alpha"
+	re, _ := compileFindPattern("ALPHA", false)
+
+	passages, total := findPassages(content, re, 10)
+	if total != 4 {
+		t.Fatalf("total = %d, want 4", total)
+	}
+	if len(passages) != 2 {
+		t.Fatalf("got %d passages, want the two close pairs merged: %+v", len(passages), passages)
+	}
+	if passages[0].Matches != 2 || passages[1].Matches != 2 {
+		t.Errorf("matches per passage = %d, %d, want 2, 2", passages[0].Matches, passages[1].Matches)
+	}
+	if !strings.Contains(passages[0].Text, "alpha beta alpha") {
+		t.Errorf("first passage = %q", passages[0].Text)
+	}
+	if got := strings.Join(passages[1].Path, " > "); got != "Title > Part" {
+		t.Errorf("second passage path = %q", got)
+	}
+	if want := strings.Index(content, "alpha at"); passages[1].Offset != want {
+		t.Errorf("second passage offset = %d, want %d", passages[1].Offset, want)
+	}
+}
+
+func TestFindPassagesOffsetsAreCharacters(t *testing.T) {
+	content := "日本語 needle"
+	re, _ := compileFindPattern("needle", false)
+	passages, _ := findPassages(content, re, 2)
+	if len(passages) != 1 || passages[0].Offset != 4 {
+		t.Fatalf("passages = %+v, want one at character 4", passages)
+	}
+	if passages[0].Text != "語 needle" {
+		t.Errorf("text = %q, want 2 characters of context", passages[0].Text)
+	}
+}
+
+func TestFindPassagesCap(t *testing.T) {
+	// Matches far apart: one passage each, up to the cap
+	unit := "needle " + strings.Repeat("x", 100) + " "
+	far := strings.Repeat(unit, maxFindMatches+10)
+	re, _ := compileFindPattern("needle", false)
+	passages, total := findPassages(far, re, 20)
+	if len(passages) != maxFindMatches || total != maxFindMatches+10 {
+		t.Fatalf("got %d passages and %d matches, want %d and %d", len(passages), total, maxFindMatches, maxFindMatches+10)
+	}
+
+	// Past the cap, a dense run of matches doesn't stretch the last
+	// passage over the rest of the page
+	dense := strings.Repeat(unit, maxFindMatches-1) + strings.Repeat("needle ", 5000)
+	passages, total = findPassages(dense, re, 20)
+	if len(passages) != maxFindMatches {
+		t.Fatalf("got %d passages, want %d", len(passages), maxFindMatches)
+	}
+	last := passages[len(passages)-1]
+	if n := len(last.Text); n > 2*20+len("needle") {
+		t.Errorf("last passage grew to %d bytes past the cap", n)
+	}
+	if total <= 5000 {
+		t.Errorf("total = %d, want every match counted", total)
+	}
+	if !strings.Contains(renderFindResults("needle", passages, total), "showing the first") {
+		t.Error("output doesn't say passages were capped")
+	}
+}
+
+func TestFindPassagesSkipsEmptyMatches(t *testing.T) {
+	passages, total := findPassages("bbb", regexp.MustCompile(`a*`), 5)
+	if len(passages) != 0 || total != 0 {
+		t.Errorf("empty matches gave %d passages, %d matches", len(passages), total)
+	}
+	if !strings.Contains(renderFindResults("a*", passages, total), "No Matches") {
+		t.Error("no matches isn't reported as such")
+	}
+}
+
+func TestCompileFindPattern(t *testing.T) {
+	re, err := compileFindPattern("a.b (c)", false)
+	if err != nil || !re.MatchString("x A.B (C) y") || re.MatchString("axb (c)") {
+		t.Errorf("literal pattern = %v, %v", re, err)
+	}
+	if _, err := compileFindPattern("a(", true); err == nil {
+		t.Error("invalid regex was accepted")
+	}
+	if re, err := compileFindPattern(`v\d+`, true); err != nil || !re.MatchString("v12") {
+		t.Errorf("regex pattern = %v, %v", re, err)
+	}
+}
@@ -3,11 +3,10 @@ module github.com/Tomlord1122/mcp-searxng-claude-go
 go 1.25.0
 
 require (
+	github.com/google/jsonschema-go v0.3.0
 	github.com/joho/godotenv v1.5.1
 	github.com/modelcontextprotocol/go-sdk v1.0.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/google/jsonschema-go v0.3.0 // indirect
-	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-)
+require github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// HealthCheck is the outcome of one probe step.
+type HealthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// HealthReport is the outcome of probing the SearXNG instance.
+type HealthReport struct {
+	Healthy   bool          `json:"healthy"`
+	CheckedAt time.Time     `json:"checked_at"`
+	LatencyMS int64         `json:"latency_ms"`
+	Checks    []HealthCheck `json:"checks"`
+}
+
+// failures returns the details of the failed checks.
+func (r HealthReport) failures() []string {
+	var failed []string
+	for _, c := range r.Checks {
+		if !c.OK {
+			failed = append(failed, c.Name+": "+c.Detail)
+		}
+	}
+	return failed
+}
+
+// Probe checks that SearXNG is reachable, accepts our credentials and
+// has the JSON output format enabled. It requests /search?format=json
+// without a query, which SearXNG rejects before running any engine:
+// 400 "No query" when JSON is enabled, 403 when it isn't. With
+// credentials configured a 403 is reported as an auth failure, since
+// gateways send it for bad credentials too.
+func (c *SearXNGClient) Probe(ctx context.Context) HealthReport {
+	report := HealthReport{CheckedAt: time.Now()}
+	check := func(name string, ok bool, detail string) {
+		report.Checks = append(report.Checks, HealthCheck{Name: name, OK: ok, Detail: detail})
+	}
+
+	conn := c.current()
+	probeURL, err := url.Parse(conn.baseURL + "/search?format=json")
+	if err != nil || probeURL.Host == "" {
+		check("reachable", false, fmt.Sprintf("SEARXNG_URL %q is not a valid URL", conn.baseURL))
+		return report
+	}
+	req, err := conn.newRequest(ctx, probeURL)
+	if err != nil {
+		check("reachable", false, err.Error())
+		return report
+	}
+
+	start := time.Now()
+	resp, err := conn.httpClient.Do(req)
+	report.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		check("reachable", false, describeConnError(conn.baseURL, err))
+		return report
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	check("reachable", true, fmt.Sprintf("%s answered in %dms", conn.baseURL, report.LatencyMS))
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusProxyAuthRequired:
+		detail := "SearXNG rejected the request as unauthenticated; set AUTH_USERNAME and AUTH_PASSWORD, SEARXNG_BEARER_TOKEN or SEARXNG_HEADERS"
+		if conn.settings.hasCredentials() {
+			detail = "SearXNG (or a gateway in front of it) rejected the configured credentials"
+		}
+		check("auth", false, detail)
+		return report
+	case resp.StatusCode == http.StatusForbidden && conn.settings.hasCredentials():
+		// A gateway that checks credentials usually answers 403 to bad
+		// ones, so with credentials configured it can't be told apart
+		// from SearXNG refusing format=json
+		check("auth", false, "SearXNG (or a gateway in front of it) refused the request with the configured credentials; check them, and that json is in search.formats in settings.yml")
+		return report
+	case resp.StatusCode == http.StatusForbidden:
+		check("auth", true, "credentials accepted")
+		check("json_format", false, "SearXNG refused format=json; add json to search.formats in settings.yml")
+		return report
+	case resp.StatusCode == http.StatusTooManyRequests:
+		check("auth", true, "credentials accepted")
+		check("json_format", false, "SearXNG's rate limiter blocked the probe; disable the limiter (SEARXNG_LIMITER=false) or allow this client")
+		return report
+	}
+	check("auth", true, "credentials accepted")
+
+	if resp.StatusCode >= 500 {
+		check("json_format", false, fmt.Sprintf("SearXNG returned status %d", resp.StatusCode))
+		return report
+	}
+	if !json.Valid(body) {
+		check("json_format", false, fmt.Sprintf("expected a JSON response, got status %d with %q; is SEARXNG_URL pointing at SearXNG?", resp.StatusCode, truncateForLog(string(body), 80)))
+		return report
+	}
+	check("json_format", true, "JSON output enabled")
+
+	report.Healthy = true
+	return report
+}
+
+// describeConnError turns a transport error into an actionable message.
+func describeConnError(baseURL string, err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("cannot resolve host %q; check SEARXNG_URL", dnsErr.Name)
+	case errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err):
+		return fmt.Sprintf("timed out connecting to %s; check SEARXNG_URL and any proxy settings", baseURL)
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return fmt.Sprintf("connection to %s refused or unreachable; is SearXNG running?", baseURL)
+	}
+	return fmt.Sprintf("request to %s failed: %v", baseURL, err)
+}
+
+// truncateForLog collapses whitespace in s and cuts it to at most n
+// bytes, on a rune boundary, so logs and error messages stay valid UTF-8.
+func truncateForLog(s string, n int) string {
+	s = strings.ToValidUTF8(strings.Join(strings.Fields(s), " "), "\uFFFD")
+	if len(s) > n {
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		return s[:n] + "..."
+	}
+	return s
+}
+
+// HealthMonitor keeps the latest health report and refreshes it
+// periodically, logging when health changes.
+type HealthMonitor struct {
+	client  *SearXNGClient
+	started time.Time
+
+	mu     sync.RWMutex
+	report *HealthReport
+}
+
+func NewHealthMonitor(client *SearXNGClient) *HealthMonitor {
+	return &HealthMonitor{client: client, started: time.Now()}
+}
+
+// Check probes SearXNG now and records the result.
+func (m *HealthMonitor) Check(ctx context.Context) HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	report := m.client.Probe(ctx)
+
+	m.mu.Lock()
+	previous := m.report
+	m.report = &report
+	m.mu.Unlock()
+
+	switch {
+	case !report.Healthy && (previous == nil || previous.Healthy):
+		log.Printf("warning: SearXNG is unhealthy: %s", strings.Join(report.failures(), "; "))
+	case report.Healthy && previous != nil && !previous.Healthy:
+		log.Printf("SearXNG is healthy again")
+	}
+	return report
+}
+
+// Last returns the latest report, or nil before the first check.
+func (m *HealthMonitor) Last() *HealthReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.report
+}
+
+// Ready reports whether the last check passed.
+func (m *HealthMonitor) Ready() bool {
+	last := m.Last()
+	return last != nil && last.Healthy
+}
+
+// Run re-checks health every interval until ctx is cancelled.
+func (m *HealthMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+func createHealthResource(monitor *HealthMonitor) string {
+	status := map[string]interface{}{
+		"version":        VERSION,
+		"uptime_seconds": int(time.Since(monitor.started).Seconds()),
+		"ready":          monitor.Ready(),
+	}
+	if last := monitor.Last(); last != nil {
+		status["searxng"] = last
+	}
+
+	data, _ := json.MarshalIndent(status, "", "  ")
+	return string(data)
+}
+
+func createHealthResourceHandler(monitor *HealthMonitor) mcp.ResourceHandler {
+	return func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		content := createHealthResource(monitor)
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{
+				{
+					URI:      "health://mcp-searxng",
+					MIMEType: "application/json",
+					Text:     content,
+				},
+			},
+		}, nil
+	}
+}
+
+// registerHealthEndpoints adds /healthz (liveness: the process is up)
+// and /readyz (readiness: the last SearXNG check passed) to mux. They're
+// unauthenticated for probes, so /readyz only gives the status; the
+// details are in the health resource, behind auth.
+func registerHealthEndpoints(mux *http.ServeMux, monitor *HealthMonitor) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !monitor.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "not ready")
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+}
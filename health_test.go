@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateForLog(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"  spaced \n\t out  ", 20, "spaced out"},
+		{"hello world", 5, "hello..."},
+		{"日本語のエラー", 4, "日..."},
+		{"日本語のエラー", 6, "日本..."},
+		{"ok 😀😀", 5, "ok ..."},
+		{"bad \xff\xfe bytes", 100, "bad � bytes"},
+	}
+	for _, tt := range tests {
+		got := truncateForLog(tt.s, tt.n)
+		if got != tt.want {
+			t.Errorf("truncateForLog(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncateForLog(%q, %d) = %q is not valid UTF-8", tt.s, tt.n, got)
+		}
+	}
+}
+
+func TestReadyzGivesOnlyTheStatus(t *testing.T) {
+	var healthy atomic.Bool
+	client := newTestSearXNG(t, func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			http.Error(w, "internal detail: db password rejected", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, `{"error": "No query"}`, http.StatusBadRequest)
+	})
+	monitor := NewHealthMonitor(client)
+	mux := http.NewServeMux()
+	registerHealthEndpoints(mux, monitor)
+
+	readyz := func() (int, string) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+		body, _ := io.ReadAll(rec.Body)
+		return rec.Code, string(body)
+	}
+
+	// Before the first check, and after a failed one
+	if code, body := readyz(); code != http.StatusServiceUnavailable || body != "not ready\n" {
+		t.Errorf("before any check: %d %q", code, body)
+	}
+	monitor.Check(context.Background())
+	code, body := readyz()
+	if code != http.StatusServiceUnavailable || body != "not ready\n" {
+		t.Errorf("unhealthy: %d %q", code, body)
+	}
+	if detail := createHealthResource(monitor); !strings.Contains(detail, "status 500") {
+		t.Errorf("health resource lacks the failure details: %s", detail)
+	}
+
+	healthy.Store(true)
+	monitor.Check(context.Background())
+	if code, body := readyz(); code != http.StatusOK || body != "ready\n" {
+		t.Errorf("healthy: %d %q", code, body)
+	}
+}
+
+func TestProbeStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		settings SearXNGSettings
+		failed   string // the failed check, or "" if healthy
+		detail   string
+	}{
+		{"json enabled", http.StatusBadRequest, SearXNGSettings{}, "", ""},
+		{"json disabled", http.StatusForbidden, SearXNGSettings{}, "json_format", "search.formats"},
+		{"credentials refused", http.StatusForbidden, SearXNGSettings{BearerToken: "tok"}, "auth", "configured credentials"},
+		{"api key refused", http.StatusForbidden, SearXNGSettings{Headers: map[string]string{"X-Api-Key": "k"}}, "auth", "configured credentials"},
+		{"no credentials", http.StatusUnauthorized, SearXNGSettings{}, "auth", "set AUTH_USERNAME"},
+		{"lone username", http.StatusUnauthorized, SearXNGSettings{Username: "admin"}, "auth", "set AUTH_USERNAME"},
+		{"wrong password", http.StatusUnauthorized, SearXNGSettings{Username: "admin", Password: "x"}, "auth", "configured credentials"},
+		{"rate limited", http.StatusTooManyRequests, SearXNGSettings{}, "json_format", "rate limiter"},
+		{"server error", http.StatusBadGateway, SearXNGSettings{}, "json_format", "status 502"},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error": "No query"}`, tt.status)
+		}))
+		tt.settings.URL = srv.URL
+		client, err := NewSearXNGClient(tt.settings, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := client.Probe(context.Background())
+		srv.Close()
+
+		if tt.failed == "" {
+			if !report.Healthy {
+				t.Errorf("%s: unhealthy: %v", tt.name, report.failures())
+			}
+			continue
+		}
+		failures := strings.Join(report.failures(), "; ")
+		if report.Healthy || !strings.HasPrefix(failures, tt.failed+": ") || !strings.Contains(failures, tt.detail) {
+			t.Errorf("%s: failures = %q, want %s failing with %q", tt.name, failures, tt.failed, tt.detail)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// InstanceEngine is an engine as listed in SearXNG's /config.
+type InstanceEngine struct {
+	Name             string   `json:"name"`
+	Categories       []string `json:"categories"`
+	Shortcut         string   `json:"shortcut"`
+	Enabled          bool     `json:"enabled"`
+	Paging           bool     `json:"paging"`
+	LanguageSupport  bool     `json:"language_support"`
+	SafeSearch       bool     `json:"safesearch"`
+	TimeRangeSupport bool     `json:"time_range_support"`
+}
+
+// InstancePlugin is a plugin as listed in SearXNG's /config.
+type InstancePlugin struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// InstanceConfig is what SearXNG's /config reports about the instance:
+// which categories, engines, locales and plugins it supports.
+type InstanceConfig struct {
+	InstanceName string            `json:"instance_name"`
+	Version      string            `json:"version"`
+	Categories   []string          `json:"categories"`
+	Engines      []InstanceEngine  `json:"engines"`
+	Plugins      []InstancePlugin  `json:"plugins"`
+	Locales      map[string]string `json:"locales"`
+	Autocomplete string            `json:"autocomplete"`
+	SafeSearch   int               `json:"safe_search"`
+}
+
+// FetchConfig fetches the instance's /config.
+func (c *SearXNGClient) FetchConfig(ctx context.Context) (*InstanceConfig, error) {
+	conn := c.current()
+	configURL, err := url.Parse(conn.baseURL + "/config")
+	if err != nil {
+		return nil, fmt.Errorf("invalid SearXNG URL: %w", err)
+	}
+
+	req, err := conn.newRequest(ctx, configURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := conn.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("config request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpStatusError(resp, "SearXNG /config", "")
+	}
+
+	var config InstanceConfig
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return nil, newToolError(ErrInvalidResponse, err, "failed to parse /config: %v", err)
+	}
+
+	return &config, nil
+}
+
+// engine looks an engine up by name, case-insensitively.
+func (ic *InstanceConfig) engine(name string) (InstanceEngine, bool) {
+	for _, e := range ic.Engines {
+		if strings.EqualFold(e.Name, name) {
+			return e, true
+		}
+	}
+	return InstanceEngine{}, false
+}
+
+// engineNames returns the sorted names of the enabled or disabled engines.
+func (ic *InstanceConfig) engineNames(enabled bool) []string {
+	var names []string
+	for _, e := range ic.Engines {
+		if e.Enabled == enabled {
+			names = append(names, e.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// languages returns the language values web_search accepts: "all",
+// "auto", every locale and every locale's base language ("en" for "en-US").
+func (ic *InstanceConfig) languages() []string {
+	languages := []string{"all", "auto"}
+	for code := range ic.Locales {
+		base, _, _ := strings.Cut(code, "-")
+		for _, l := range []string{code, base} {
+			if !slices.Contains(languages, l) {
+				languages = append(languages, l)
+			}
+		}
+	}
+	sort.Strings(languages[2:])
+	return languages
+}
+
+// validateSearch checks search arguments against what the instance
+// supports, so a typo or a disabled engine is reported instead of
+// silently returning nothing. A nil config accepts everything.
+func (ic *InstanceConfig) validateSearch(categories, engines []string, language string) error {
+	if ic == nil {
+		return nil
+	}
+
+	for _, category := range categories {
+		if !containsFold(ic.Categories, category) {
+			return fmt.Errorf("unknown category %q: this instance supports %s", category, strings.Join(ic.Categories, ", "))
+		}
+	}
+
+	for _, name := range engines {
+		e, ok := ic.engine(name)
+		if !ok {
+			return fmt.Errorf("unknown engine %q: see the searxng://instance resource for the engines this instance supports", name)
+		}
+		if !e.Enabled {
+			return fmt.Errorf("engine %q is disabled on this SearXNG instance", e.Name)
+		}
+	}
+
+	if language != "" && len(ic.Locales) > 0 && !containsFold(ic.languages(), language) {
+		return fmt.Errorf("unsupported language %q: use a locale code such as \"en\" or \"en-US\", or \"all\"", language)
+	}
+
+	return nil
+}
+
+// signature summarizes the parts of the config that affect tool schemas,
+// to tell whether a refresh changed anything.
+func (ic *InstanceConfig) signature() string {
+	if ic == nil {
+		return ""
+	}
+	return strings.Join(ic.Categories, ",") + "|" + strings.Join(ic.engineNames(true), ",") + "|" + strings.Join(ic.languages(), ",")
+}
+
+// Capabilities holds the latest /config of the SearXNG instance and
+// refreshes it periodically.
+type Capabilities struct {
+	client *SearXNGClient
+
+	mu        sync.RWMutex
+	config    *InstanceConfig
+	fetchedAt time.Time
+	lastErr   error
+	onChange  []func(*InstanceConfig)
+}
+
+func NewCapabilities(client *SearXNGClient) *Capabilities {
+	return &Capabilities{client: client}
+}
+
+// Get returns the latest config, or nil if it was never fetched.
+func (c *Capabilities) Get() *InstanceConfig {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// OnChange registers fn to run after a refresh that changed the config.
+func (c *Capabilities) OnChange(fn func(*InstanceConfig)) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = append(c.onChange, fn)
+}
+
+// Refresh fetches /config. On failure the previous config is kept.
+func (c *Capabilities) Refresh(ctx context.Context) error {
+	config, err := c.client.FetchConfig(ctx)
+
+	c.mu.Lock()
+	c.lastErr = err
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	changed := config.signature() != c.config.signature()
+	c.config = config
+	c.fetchedAt = time.Now()
+	callbacks := slices.Clone(c.onChange)
+	c.mu.Unlock()
+
+	if changed {
+		for _, fn := range callbacks {
+			fn(config)
+		}
+	}
+	return nil
+}
+
+// Run refreshes the config every interval until ctx is cancelled.
+func (c *Capabilities) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			if err := c.Refresh(refreshCtx); err != nil {
+				log.Printf("warning: failed to refresh SearXNG /config: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// webSearchInputSchema infers web_search's input schema and, when the
+// instance config is known, describes the categories, engines and
+// languages the instance supports. They aren't enums: validateSearch
+// checks them case-insensitively and explains what's wrong, which a
+// schema rejection wouldn't.
+func webSearchInputSchema(config *InstanceConfig) *jsonschema.Schema {
+	schema, err := jsonschema.For[WebSearchArgs](nil)
+	if err != nil || config == nil {
+		return schema
+	}
+
+	if p := schema.Properties["categories"]; p != nil && len(config.Categories) > 0 {
+		p.Description += fmt.Sprintf(" (this instance supports %s)", strings.Join(config.Categories, ", "))
+	}
+	if p := schema.Properties["engines"]; p != nil && len(config.Engines) > 0 {
+		p.Description += " (only enabled engines are accepted; the searxng://instance resource lists them, and the disabled ones)"
+	}
+	if p := schema.Properties["language"]; p != nil && len(config.Locales) > 0 {
+		p.Description += " (any locale this instance supports, or all; the searxng://instance resource lists them)"
+	}
+
+	return schema
+}
+
+func createInstanceResource(capabilities *Capabilities) string {
+	capabilities.mu.RLock()
+	config, fetchedAt, lastErr := capabilities.config, capabilities.fetchedAt, capabilities.lastErr
+	capabilities.mu.RUnlock()
+
+	info := map[string]interface{}{
+		"searxng_url": capabilities.client.current().baseURL,
+		"available":   config != nil,
+	}
+	if lastErr != nil {
+		info["last_error"] = lastErr.Error()
+	}
+	if config != nil {
+		var plugins []string
+		for _, p := range config.Plugins {
+			if p.Enabled {
+				plugins = append(plugins, p.Name)
+			}
+		}
+		byCategory := make(map[string][]string)
+		for _, e := range config.Engines {
+			if e.Enabled {
+				for _, category := range e.Categories {
+					byCategory[category] = append(byCategory[category], e.Name)
+				}
+			}
+		}
+		info["fetched_at"] = fetchedAt.Format(time.RFC3339)
+		info["instance_name"] = config.InstanceName
+		info["version"] = config.Version
+		info["categories"] = config.Categories
+		info["engines_by_category"] = byCategory
+		info["disabled_engines"] = config.engineNames(false)
+		info["languages"] = config.languages()
+		info["enabled_plugins"] = plugins
+		info["autocomplete"] = config.Autocomplete
+		info["default_safe_search"] = config.SafeSearch
+	}
+
+	data, _ := json.MarshalIndent(info, "", "  ")
+	return string(data)
+}
+
+func createInstanceResourceHandler(capabilities *Capabilities) mcp.ResourceHandler {
+	return func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		content := createInstanceResource(capabilities)
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{
+				{
+					URI:      "searxng://instance",
+					MIMEType: "application/json",
+					Text:     content,
+				},
+			},
+		}, nil
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func testInstanceConfig() *InstanceConfig {
+	return &InstanceConfig{
+		Categories: []string{"general", "it", "science"},
+		Engines: []InstanceEngine{
+			{Name: "wikipedia", Enabled: true},
+			{Name: "github", Enabled: true},
+			{Name: "bing", Enabled: false},
+		},
+		Locales: map[string]string{"en-US": "English (United States)", "fr": "Français"},
+	}
+}
+
+func TestValidateSearch(t *testing.T) {
+	config := testInstanceConfig()
+	tests := []struct {
+		categories, engines []string
+		language            string
+		wantErr             string
+	}{
+		{nil, nil, "", ""},
+		{[]string{"IT", "general"}, []string{"GitHub"}, "EN", ""},
+		{nil, nil, "en-us", ""},
+		{nil, nil, "all", ""},
+		{[]string{"videos"}, nil, "", `unknown category "videos"`},
+		{nil, []string{"duckduckgo"}, "", `unknown engine "duckduckgo"`},
+		{nil, []string{"Bing"}, "", `engine "bing" is disabled`},
+		{nil, nil, "de", `unsupported language "de"`},
+	}
+	for _, tt := range tests {
+		err := config.validateSearch(tt.categories, tt.engines, tt.language)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("validateSearch(%q, %q, %q) = %v, want %q", tt.categories, tt.engines, tt.language, err, tt.wantErr)
+		}
+	}
+
+	// Without a config, everything is accepted
+	if err := (*InstanceConfig)(nil).validateSearch([]string{"x"}, []string{"y"}, "z"); err != nil {
+		t.Errorf("nil config rejected arguments: %v", err)
+	}
+}
+
+func TestWebSearchInputSchema(t *testing.T) {
+	schema := webSearchInputSchema(testInstanceConfig())
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The schema must leave checking the values to validateSearch, so
+	// mixed case and unknown values get through to it
+	args := map[string]any{
+		"query":      "q",
+		"categories": []any{"IT", "videos"},
+		"engines":    []any{"GitHub", "bing"},
+		"language":   "EN",
+	}
+	if err := resolved.Validate(args); err != nil {
+		t.Errorf("schema rejected arguments validateSearch should judge: %v", err)
+	}
+
+	if d := schema.Properties["categories"].Description; !strings.Contains(d, "general, it, science") {
+		t.Errorf("categories description = %q, want the supported categories", d)
+	}
+	for _, name := range []string{"engines", "language"} {
+		if d := schema.Properties[name].Description; !strings.Contains(d, "searxng://instance") {
+			t.Errorf("%s description = %q, want a pointer to the instance resource", name, d)
+		}
+	}
+
+	// Without a config the inferred schema is used as is
+	if d := webSearchInputSchema(nil).Properties["categories"].Description; strings.Contains(d, "supports") {
+		t.Errorf("schema without a config describes %q", d)
+	}
+}
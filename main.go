@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
-	"strconv"
+	"reflect"
+	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -17,9 +22,20 @@ func main() {
 	// Load environment variables from .env file if it exists
 	_ = godotenv.Load()
 
-	// Validate environment
-	if err := validateEnvironment(); err != nil {
-		log.Fatalf("Configuration error: %v", err)
+	// Subcommands run a tool directly instead of serving MCP
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
+	// Load and validate configuration
+	config, err := LoadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Configuration error:\n%v", err)
 	}
 
 	// Create MCP server
@@ -29,74 +45,204 @@ func main() {
 	}, nil)
 
 	// Initialize services
-	cache := NewCache(cacheTTLSeconds(), cacheMaxEntries())
+	cache := NewCache(config.Cache.TTL, config.Cache.MaxEntries)
 	defer cache.Destroy()
 
-	proxyConfig := LoadProxyConfig()
-	searxngClient := NewSearXNGClient(os.Getenv("SEARXNG_URL"), proxyConfig)
-	urlReader := NewURLReader(cache, proxyConfig)
-
-	// Register tools
-	registerTools(server, searxngClient, urlReader)
+	proxyConfig := NewProxyConfig(config.Proxy)
+	searxngClient, err := NewSearXNGClient(config.SearXNG, cache, proxyConfig)
+	if err != nil {
+		log.Fatalf("Configuration error:\n%v", err)
+	}
+	urlReader := NewURLReader(config.URLReader, cache, proxyConfig)
+	var ranking atomic.Pointer[RankingConfig]
+	ranking.Store(NewRankingConfig(config.Ranking))
 
-	// Register resources
-	registerResources(server)
+	// Probe SearXNG so a wrong URL, missing credentials or disabled JSON
+	// output show up now rather than on the first search
+	monitor := NewHealthMonitor(searxngClient)
+	if report := monitor.Check(context.Background()); report.Healthy {
+		log.Printf("SearXNG at %s is healthy (%dms)", config.SearXNG.URL, report.LatencyMS)
+	}
+	if interval := config.Health.interval(); interval > 0 {
+		go monitor.Run(context.Background(), interval)
+	}
 
-	// Start server with stdio transport
-	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
-		log.Fatalf("Server error: %v", err)
+	// Discover what the SearXNG instance supports. Failure isn't fatal:
+	// tools then skip validation until a later refresh succeeds.
+	capabilities := NewCapabilities(searxngClient)
+	startupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := capabilities.Refresh(startupCtx); err != nil {
+		log.Printf("warning: failed to fetch SearXNG /config, argument validation disabled until it succeeds: %v", err)
+	}
+	cancel()
+	if interval := config.SearXNG.refreshInterval(); interval > 0 {
+		go capabilities.Run(context.Background(), interval)
 	}
-}
 
-func validateEnvironment() error {
-	searxngURL := os.Getenv("SEARXNG_URL")
-	if searxngURL == "" {
-		return fmt.Errorf("SEARXNG_URL environment variable is required")
+	// Authenticate HTTP clients and limit them to the tools and resources
+	// their token is scoped for, then to their quotas
+	authenticator, err := NewAuthenticator(config.Auth)
+	if err != nil {
+		log.Fatalf("Configuration error:\n%v", err)
 	}
-	return nil
-}
+	usage := NewUsage(config.Quotas)
 
-// cacheTTLSeconds reads CACHE_TTL from the environment (seconds).
-// Falls back to 60s if unset or invalid. This was previously a
-// hardcoded value even though README documented it as configurable.
-func cacheTTLSeconds() int {
-	const defaultTTL = 60
-	v := os.Getenv("CACHE_TTL")
-	if v == "" {
-		return defaultTTL
+	// Record every tool call, allowed or not
+	audit, err := NewAuditLog(config.Audit)
+	if err != nil {
+		log.Fatalf("Configuration error:\n%v", err)
 	}
-	n, err := strconv.Atoi(v)
-	if err != nil || n <= 0 {
-		log.Printf("warning: invalid CACHE_TTL=%q, falling back to %ds", v, defaultTTL)
-		return defaultTTL
+	server.AddReceivingMiddleware(audit.Middleware, requireScopes, usage.Middleware)
+
+	// Apply configuration changes without a restart. Everything that can
+	// fail is prepared first, so a reload applies completely or not at
+	// all; each service then swaps its settings atomically, so requests
+	// in flight aren't disturbed.
+	reloader := NewReloader(config, os.Args[1:])
+	reloader.OnReload(func(old, new *Config) (commit, abort func(), err error) {
+		proxyConfig := NewProxyConfig(new.Proxy)
+		commitSearXNG, err := searxngClient.PrepareReconfigure(new.SearXNG, proxyConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("searxng: %w", err)
+		}
+		commitAuth, err := authenticator.PrepareReconfigure(new.Auth)
+		if err != nil {
+			return nil, nil, fmt.Errorf("auth: %w", err)
+		}
+		commitAudit, abortAudit, err := audit.PrepareReconfigure(new.Audit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("audit: %w", err)
+		}
+
+		commit = func() {
+			cache.Reconfigure(new.Cache.TTL, new.Cache.MaxEntries)
+			commitSearXNG()
+			urlReader.Reconfigure(new.URLReader, proxyConfig)
+			ranking.Store(NewRankingConfig(new.Ranking))
+			commitAuth()
+			usage.Reconfigure(new.Quotas)
+			commitAudit()
+
+			// A different instance may support different engines
+			if !reflect.DeepEqual(new.SearXNG, old.SearXNG) || new.Proxy != old.Proxy {
+				go func() {
+					monitor.Check(context.Background())
+					ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
+					if err := capabilities.Refresh(ctx); err != nil {
+						log.Printf("warning: failed to fetch SearXNG /config after reload: %v", err)
+					}
+				}()
+			}
+		}
+		return commit, abortAudit, nil
+	})
+	go reloader.Run(context.Background())
+
+	// Register tools
+	registerTools(server, searxngClient, urlReader, &ranking, capabilities)
+
+	// Register resources
+	registerResources(server, reloader, capabilities, monitor, usage)
+
+	// Start server
+	switch config.Server.Transport {
+	case "stdio":
+		if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+	case "http":
+		if !config.Auth.enabled() {
+			log.Printf("warning: serving HTTP without authentication; anyone who can reach %s can use SearXNG through this server", config.Server.HTTPAddr)
+			if config.Quotas.set() {
+				log.Printf("warning: without authentication, quotas are per client name, which clients choose themselves; they catch accidents but don't stop abuse")
+			}
+		}
+
+		// Health endpoints stay open for probes
+		mux := http.NewServeMux()
+		mux.Handle("/mcp", authenticator.Middleware(mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+			return server
+		}, nil)))
+		mux.Handle("/cachez", authenticator.Middleware(requireHTTPScope(cacheStatsHandler(cache), cacheStatsScope, allResourcesScope)))
+		registerHealthEndpoints(mux, monitor)
+
+		log.Printf("Serving MCP over HTTP at %s/mcp", config.Server.HTTPAddr)
+		if err := http.ListenAndServe(config.Server.HTTPAddr, mux); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
 	}
-	return n
 }
 
-// cacheMaxEntries reads CACHE_MAX_ENTRIES from the environment.
-// Bounds the in-memory url-read cache so it cannot grow unboundedly
-// between TTL cleanup cycles. Falls back to 500 if unset or invalid.
-func cacheMaxEntries() int {
-	const defaultMax = 500
-	v := os.Getenv("CACHE_MAX_ENTRIES")
-	if v == "" {
-		return defaultMax
+func registerTools(server *mcp.Server, client *SearXNGClient, reader *URLReader, ranking *atomic.Pointer[RankingConfig], capabilities *Capabilities) {
+	// Web search tool. Its schema lists the instance's categories, engines
+	// and languages, so it's registered again whenever those change.
+	addWebSearch := func(config *InstanceConfig) {
+		mcp.AddTool(server, &mcp.Tool{
+			Name:        "web_search",
+			Description: "Performs a web search using the SearXNG API, ideal for general queries, news, articles, and online content.",
+			InputSchema: webSearchInputSchema(config),
+		}, func(ctx context.Context, req *mcp.CallToolRequest, args WebSearchArgs) (*mcp.CallToolResult, *WebSearchOutput, error) {
+			return handleWebSearch(ctx, req, client, ranking.Load(), capabilities, args)
+		})
 	}
-	n, err := strconv.Atoi(v)
-	if err != nil || n <= 0 {
-		log.Printf("warning: invalid CACHE_MAX_ENTRIES=%q, falling back to %d", v, defaultMax)
-		return defaultMax
-	}
-	return n
-}
+	addWebSearch(capabilities.Get())
+	capabilities.OnChange(addWebSearch)
+
+	// Multi-query search tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "multi_search",
+		Description: "Runs several phrasings of a question concurrently and fuses their rankings (reciprocal rank fusion), deduplicating by canonical URL and reporting which queries found each result. Use this for research questions where one phrasing may miss good sources.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args MultiSearchArgs) (*mcp.CallToolResult, *MultiSearchOutput, error) {
+		return handleMultiSearch(ctx, req, client, args)
+	})
+
+	// News search tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "news_search",
+		Description: "Searches news articles (SearXNG news category), newest first, with source and publication time for each article. Supports day/week/month/year ranges and explicit from/to dates.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args NewsSearchArgs) (*mcp.CallToolResult, *NewsSearchOutput, error) {
+		return handleNewsSearch(ctx, req, client, args)
+	})
+
+	// Scholar search tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "scholar_search",
+		Description: "Searches academic papers (SearXNG science category: arXiv, PubMed, Semantic Scholar, Crossref, ...). Returns DOI, authors, journal, publication date and PDF links, and can emit BibTeX or CSL-JSON citations for the results.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args ScholarSearchArgs) (*mcp.CallToolResult, *ScholarSearchOutput, error) {
+		return handleScholarSearch(ctx, req, client, args)
+	})
+
+	// Code search tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "code_search",
+		Description: "Searches programming sources only (SearXNG it category: GitHub, StackOverflow, package registries, MDN, ...), avoiding the SEO spam of general web search. Shows repository stars, language and license, Q&A status and score, and package versions when available.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args CodeSearchArgs) (*mcp.CallToolResult, *CodeSearchOutput, error) {
+		return handleCodeSearch(ctx, req, client, args)
+	})
+
+	// Image search tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "image_search",
+		Description: "Searches images (SearXNG images category). Returns image, thumbnail and source page URLs with resolution and format, and optionally inline thumbnails for multimodal models.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args MediaSearchArgs) (*mcp.CallToolResult, *MediaSearchOutput, error) {
+		return handleMediaSearch(ctx, req, client, reader, "images", args)
+	})
+
+	// Video search tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "video_search",
+		Description: "Searches videos (SearXNG videos category). Returns video page, embed and thumbnail URLs with duration and author, and optionally inline thumbnails for multimodal models.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args MediaSearchArgs) (*mcp.CallToolResult, *MediaSearchOutput, error) {
+		return handleMediaSearch(ctx, req, client, reader, "videos", args)
+	})
 
-func registerTools(server *mcp.Server, client *SearXNGClient, reader *URLReader) {
-	// Web search tool
+	// Suggest tool
 	mcp.AddTool(server, &mcp.Tool{
-		Name:        "web_search",
-		Description: "Performs a web search using the SearXNG API, ideal for general queries, news, articles, and online content.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, args WebSearchArgs) (*mcp.CallToolResult, any, error) {
-		return handleWebSearch(ctx, req, client, args)
+		Name:        "suggest",
+		Description: "Returns search query completions for a partial or ambiguous query from SearXNG's autocompleter. Useful for discovering how a topic is usually phrased before searching.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args SuggestArgs) (*mcp.CallToolResult, *SuggestOutput, error) {
+		return handleSuggest(ctx, req, client, args)
 	})
 
 	// URL read tool
@@ -108,14 +254,14 @@ func registerTools(server *mcp.Server, client *SearXNGClient, reader *URLReader)
 	})
 }
 
-func registerResources(server *mcp.Server) {
+func registerResources(server *mcp.Server, reloader *Reloader, capabilities *Capabilities, monitor *HealthMonitor, usage *Usage) {
 	// Config resource
 	server.AddResource(&mcp.Resource{
 		Name:        "Server Configuration",
 		URI:         "config://mcp-searxng",
 		Description: "Current server configuration",
 		MIMEType:    "application/json",
-	}, createConfigResourceHandler)
+	}, createConfigResourceHandler(reloader))
 
 	// Help resource
 	server.AddResource(&mcp.Resource{
@@ -124,4 +270,28 @@ func registerResources(server *mcp.Server) {
 		Description: "MCP SearXNG usage guide",
 		MIMEType:    "text/markdown",
 	}, createHelpResourceHandler)
+
+	// SearXNG instance resource
+	server.AddResource(&mcp.Resource{
+		Name:        "SearXNG Instance",
+		URI:         "searxng://instance",
+		Description: "Categories, engines (enabled and disabled), languages and plugins of the SearXNG instance, from its /config",
+		MIMEType:    "application/json",
+	}, createInstanceResourceHandler(capabilities))
+
+	// Health resource
+	server.AddResource(&mcp.Resource{
+		Name:        "Health",
+		URI:         "health://mcp-searxng",
+		Description: "Latest SearXNG health check: reachability, auth and JSON format",
+		MIMEType:    "application/json",
+	}, createHealthResourceHandler(monitor))
+
+	// Usage resource
+	server.AddResource(&mcp.Resource{
+		Name:        "Usage",
+		URI:         "usage://mcp-searxng",
+		Description: "The calling client's tool calls, upstream requests and bytes fetched today and this minute, with its quotas (every client's, for tokens with the usage:admin scope)",
+		MIMEType:    "application/json",
+	}, createUsageResourceHandler(usage))
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// inlineSpanRegex matches inline Markdown constructs that must not be cut
+// in half: links, images and code spans.
+var inlineSpanRegex = regexp.MustCompile("!?\\[[^\\]\\n]*\\]\\([^)\\n]*\\)|`[^`\\n]+`")
+
+// byteOffset converts a rune offset into s to a byte offset, clamped to
+// len(s).
+func byteOffset(s string, runes int) int {
+	if runes <= 0 {
+		return 0
+	}
+	n := 0
+	for i := range s {
+		if n == runes {
+			return i
+		}
+		n++
+	}
+	return len(s)
+}
+
+// blockSpan is the byte range [start, end) of a Markdown block that
+// should be kept whole where possible: a fenced code block or a table.
+type blockSpan struct {
+	start, end int
+}
+
+// markdownBlocks finds fenced code blocks and tables in content. An
+// unterminated fence runs to the end of the document.
+func markdownBlocks(content string) []blockSpan {
+	var blocks []blockSpan
+	fenceStart := -1
+	fenceMarker := ""
+	tableStart := -1
+
+	for lineStart := 0; lineStart < len(content); {
+		lineEnd := strings.IndexByte(content[lineStart:], '\n')
+		next := len(content)
+		if lineEnd >= 0 {
+			next = lineStart + lineEnd + 1
+		}
+		line := strings.TrimSpace(content[lineStart:next])
+
+		switch {
+		case fenceStart >= 0:
+			if strings.HasPrefix(line, fenceMarker) {
+				blocks = append(blocks, blockSpan{fenceStart, next})
+				fenceStart = -1
+			}
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			if tableStart >= 0 {
+				blocks = append(blocks, blockSpan{tableStart, lineStart})
+				tableStart = -1
+			}
+			fenceStart = lineStart
+			fenceMarker = line[:3]
+		case strings.HasPrefix(line, "|"):
+			if tableStart < 0 {
+				tableStart = lineStart
+			}
+		default:
+			if tableStart >= 0 {
+				blocks = append(blocks, blockSpan{tableStart, lineStart})
+				tableStart = -1
+			}
+		}
+
+		lineStart = next
+	}
+
+	if fenceStart >= 0 {
+		blocks = append(blocks, blockSpan{fenceStart, len(content)})
+	}
+	if tableStart >= 0 {
+		blocks = append(blocks, blockSpan{tableStart, len(content)})
+	}
+
+	return blocks
+}
+
+// inlineSpanLookahead bounds how far past a cut snapChunkEnd looks for
+// the end of a link or code span, so a page that is one enormous line
+// isn't rescanned for every chunk.
+const inlineSpanLookahead = 2048
+
+// snapChunkEnd moves a chunk end (byte offset into content, after start)
+// back to the nearest position that doesn't split Markdown structure.
+// In order of preference it cuts at a paragraph break or block edge, a
+// line break outside code blocks and tables, any line break, and finally
+// whitespace outside links and code spans. The chunk is never shrunk to
+// less than half its length; if nothing qualifies, end is kept as is.
+// blocks are content's markdownBlocks, which callers cutting many chunks
+// compute once. Only the part of content near the cut is examined.
+func snapChunkEnd(content string, blocks []blockSpan, start, end int) int {
+	if end >= len(content) || end <= start {
+		return end
+	}
+
+	floor := start + (end-start)/2
+
+	// Blocks are in document order and don't overlap, so the ones that
+	// matter are a contiguous run around [floor, end].
+	first := sort.Search(len(blocks), func(i int) bool { return blocks[i].end >= floor })
+	last := first
+	for last < len(blocks) && blocks[last].start <= end {
+		last++
+	}
+	blocks = blocks[first:last]
+
+	insideBlock := func(pos int) bool {
+		for _, b := range blocks {
+			if b.start < pos && pos < b.end {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Paragraph breaks and block edges
+	for pos := end; pos > floor; pos-- {
+		if insideBlock(pos) {
+			continue
+		}
+		if strings.HasSuffix(content[:pos], "\n\n") {
+			return pos
+		}
+		for _, b := range blocks {
+			if pos == b.start || pos == b.end {
+				return pos
+			}
+		}
+	}
+
+	// Line breaks, first outside blocks, then anywhere
+	for _, allowInBlock := range []bool{false, true} {
+		for pos := end; pos > floor; pos-- {
+			if content[pos-1] == '\n' && (allowInBlock || !insideBlock(pos)) {
+				return pos
+			}
+		}
+	}
+
+	// Whitespace outside inline spans on the current line (or from the
+	// chunk start, if the line began earlier)
+	lineStart := start + strings.LastIndexByte(content[start:end], '\n') + 1
+	lineEnd := min(len(content), end+inlineSpanLookahead)
+	if idx := strings.IndexByte(content[end:lineEnd], '\n'); idx >= 0 {
+		lineEnd = end + idx
+	}
+	spans := inlineSpanRegex.FindAllStringIndex(content[lineStart:lineEnd], -1)
+	insideSpan := func(pos int) bool {
+		for _, s := range spans {
+			if lineStart+s[0] < pos && pos < lineStart+s[1] {
+				return true
+			}
+		}
+		return false
+	}
+	for pos := end; pos > max(floor, lineStart); pos-- {
+		if content[pos-1] == ' ' && !insideSpan(pos) {
+			return pos
+		}
+	}
+
+	// Nothing better: keep the raw cut, but never mid-rune.
+	for end > start && !utf8.RuneStart(content[end]) {
+		end--
+	}
+	return end
+}
+
+// finishWord moves a cut that falls inside a word forward to the end of
+// that word, without passing limit. Scripts written without spaces (see
+// unspacedScript) can be cut between any two characters.
+func finishWord(content string, pos, limit int) int {
+	if pos <= 0 || pos >= limit {
+		return pos
+	}
+	if prev, _ := utf8.DecodeLastRuneInString(content[:pos]); !isWordRune(prev) {
+		return pos
+	}
+	for pos < limit {
+		r, size := utf8.DecodeRuneInString(content[pos:])
+		if !isWordRune(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+func isWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') && !unspacedScript(r)
+}
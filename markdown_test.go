@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestByteOffset(t *testing.T) {
+	tests := []struct {
+		s     string
+		runes int
+		want  int
+	}{
+		{"hello", 0, 0},
+		{"hello", 3, 3},
+		{"hello", 5, 5},
+		{"hello", 99, 5},
+		{"hello", -1, 0},
+		{"日本語", 1, 3},
+		{"日本語", 2, 6},
+		{"a😀b", 2, 5},
+		{"a😀b", 3, 6},
+	}
+	for _, tt := range tests {
+		if got := byteOffset(tt.s, tt.runes); got != tt.want {
+			t.Errorf("byteOffset(%q, %d) = %d, want %d", tt.s, tt.runes, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownBlocks(t *testing.T) {
+	content := "intro\n\n```go\nx := 1\n```\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\nouter\n\n~~~\nopen"
+	var got []string
+	for _, b := range markdownBlocks(content) {
+		got = append(got, content[b.start:b.end])
+	}
+	want := []string{
+		"```go\nx := 1\n```\n",
+		"| a | b |\n|---|---|\n| 1 | 2 |\n",
+		"~~~\nopen",
+	}
+	if strings.Join(got, "|||") != strings.Join(want, "|||") {
+		t.Errorf("markdownBlocks = %q, want %q", got, want)
+	}
+}
+
+func TestSnapChunkEnd(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		end     int // rune offset of the raw cut
+		want    string
+	}{
+		{
+			name:    "paragraph break",
+			content: "First paragraph here.\n\nSecond paragraph follows on.",
+			end:     30,
+			want:    "First paragraph here.\n\n",
+		},
+		{
+			name:    "before a code fence",
+			content: "Some longer text to start with.\n```\ncode\n```\nafter",
+			end:     40,
+			want:    "Some longer text to start with.\n",
+		},
+		{
+			name:    "before a table",
+			content: "Lead in text here.\n| a | b |\n|---|---|\n| 1 | 2 |\nafter",
+			end:     35,
+			want:    "Lead in text here.\n",
+		},
+		{
+			name:    "whitespace outside links",
+			content: "some words here x [one two three](u) tail",
+			end:     30,
+			want:    "some words here x ",
+		},
+		{
+			name:    "whitespace in CJK text",
+			content: "日本語の 文章です これは テスト",
+			end:     12,
+			want:    "日本語の 文章です ",
+		},
+		{
+			name:    "emoji without whitespace",
+			content: "😀😀😀😀😀😀😀😀",
+			end:     5,
+			want:    "😀😀😀😀😀",
+		},
+		{
+			name:    "end of content",
+			content: "short",
+			end:     5,
+			want:    "short",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end := snapChunkEnd(tt.content, markdownBlocks(tt.content), 0, byteOffset(tt.content, tt.end))
+			got := tt.content[:end]
+			if !utf8.ValidString(got) {
+				t.Fatalf("chunk %q is not valid UTF-8", got)
+			}
+			if got != tt.want {
+				t.Errorf("chunk = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinishWord(t *testing.T) {
+	tests := []struct {
+		content string
+		pos     int
+		limit   int
+		want    int
+	}{
+		{"hello world", 3, 11, 5},
+		{"hello world", 5, 11, 5},
+		{"hello world", 6, 11, 6},
+		{"hello world", 3, 4, 4},
+		{"日本語", 3, 9, 3},
+		{"naïve text", 2, 11, 6},
+	}
+	for _, tt := range tests {
+		if got := finishWord(tt.content, tt.pos, tt.limit); got != tt.want {
+			t.Errorf("finishWord(%q, %d, %d) = %d, want %d", tt.content, tt.pos, tt.limit, got, tt.want)
+		}
+	}
+}
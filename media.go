@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultMediaResults = 10
+	maxMediaResults     = 30
+	maxThumbnails       = 6
+	maxThumbnailBytes   = 512 * 1024
+)
+
+// MediaSearchArgs defines the parameters for image and video search
+type MediaSearchArgs struct {
+	Query             string `json:"query" jsonschema:"the search query"`
+	PageNo            int    `json:"pageno,omitempty" jsonschema:"search page number (starts at 1)"`
+	TimeRange         string `json:"time_range,omitempty" jsonschema:"time range of search (day, week, month, or year)"`
+	Language          string `json:"language,omitempty" jsonschema:"language code for search results (e.g., 'en', 'fr', 'de')"`
+	SafeSearch        string `json:"safesearch,omitempty" jsonschema:"safe search filter level (0: None, 1: Moderate, 2: Strict)"`
+	MaxResults        int    `json:"max_results,omitempty" jsonschema:"maximum number of results to return (default: 10, max: 30)"`
+	IncludeThumbnails bool   `json:"include_thumbnails,omitempty" jsonschema:"also return up to 6 thumbnails as inline images so a multimodal model can look at them"`
+}
+
+// MediaResult is an image or video search result.
+type MediaResult struct {
+	Rank         int      `json:"rank"`
+	Title        string   `json:"title"`
+	URL          string   `json:"url" jsonschema:"page the media was found on"`
+	Content      string   `json:"content,omitempty"`
+	MediaURL     string   `json:"mediaUrl,omitempty" jsonschema:"full-size image URL (images only)"`
+	ThumbnailURL string   `json:"thumbnailUrl,omitempty"`
+	EmbedURL     string   `json:"embedUrl,omitempty" jsonschema:"embeddable player URL (videos only)"`
+	Resolution   string   `json:"resolution,omitempty"`
+	Format       string   `json:"format,omitempty"`
+	Duration     string   `json:"duration,omitempty" jsonschema:"video length, e.g. '1:02:03'"`
+	Author       string   `json:"author,omitempty"`
+	Source       string   `json:"source,omitempty"`
+	Engines      []string `json:"engines,omitempty"`
+}
+
+// MediaSearchOutput is image_search's and video_search's structured output.
+type MediaSearchOutput struct {
+	Query    string        `json:"query"`
+	Category string        `json:"category"`
+	Results  []MediaResult `json:"results,omitempty"`
+	Error    *ToolError    `json:"error,omitempty"`
+}
+
+// toMediaResult picks the media fields out of a SearXNG result. Engines
+// disagree on field names, so thumbnails fall back from thumbnail_src to
+// thumbnail, and durations from length to duration.
+func toMediaResult(rank int, r SearXNGResult) MediaResult {
+	thumbnail := r.ThumbnailSrc
+	if thumbnail == "" {
+		thumbnail = r.Thumbnail
+	}
+	duration := string(r.Length)
+	if duration == "" {
+		duration = string(r.Duration)
+	}
+	source := r.Source
+	if source == "" {
+		source = sourceName(r.URL)
+	}
+
+	return MediaResult{
+		Rank:         rank,
+		Title:        r.Title,
+		URL:          r.URL,
+		Content:      r.Content,
+		MediaURL:     r.ImgSrc,
+		ThumbnailURL: thumbnail,
+		EmbedURL:     r.IframeSrc,
+		Resolution:   r.Resolution,
+		Format:       r.ImgFormat,
+		Duration:     formatMediaDuration(duration),
+		Author:       r.Author,
+		Source:       source,
+		Engines:      r.Engines,
+	}
+}
+
+// formatMediaDuration normalizes a video length to h:mm:ss / m:ss. Engines
+// report either seconds as a number or an already formatted string
+// (sometimes with a zero hour, like "0:03:45").
+func formatMediaDuration(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		d := time.Duration(secs) * time.Second
+		h, m, sec := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+		if h > 0 {
+			return fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+		}
+		return fmt.Sprintf("%d:%02d", m, sec)
+	}
+	fields := strings.Split(s, ":")
+	if len(fields) == 3 && strings.Trim(fields[0], "0") == "" {
+		fields = fields[1:]
+	}
+	if len(fields) == 2 {
+		// m:ss, so "03:45" (or "0:03:45") becomes "3:45"
+		if m := strings.TrimLeft(fields[0], "0"); m != "" {
+			fields[0] = m
+		} else {
+			fields[0] = "0"
+		}
+	}
+	return strings.Join(fields, ":")
+}
+
+// fetchThumbnails downloads thumbnails for the first results concurrently.
+// Failures are skipped; a missing thumbnail shouldn't fail the search.
+func fetchThumbnails(ctx context.Context, reader *URLReader, results []MediaResult) []mcp.Content {
+	images := make([]*mcp.ImageContent, min(len(results), maxThumbnails))
+	var wg sync.WaitGroup
+	for i := range images {
+		src := results[i].ThumbnailURL
+		if src == "" {
+			src = results[i].MediaURL
+		}
+		if src == "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, mimeType, err := reader.FetchImage(ctx, src, maxThumbnailBytes)
+			if err == nil {
+				images[i] = &mcp.ImageContent{Data: data, MIMEType: mimeType}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var content []mcp.Content
+	for i, img := range images {
+		if img != nil {
+			content = append(content,
+				&mcp.TextContent{Text: fmt.Sprintf("Thumbnail for result %d:", results[i].Rank)},
+				img)
+		}
+	}
+	return content
+}
+
+func handleMediaSearch(ctx context.Context, req *mcp.CallToolRequest, client *SearXNGClient, reader *URLReader, category string, args MediaSearchArgs) (*mcp.CallToolResult, *MediaSearchOutput, error) {
+	// Validate required parameter
+	if args.Query == "" {
+		terr := invalidArgument("query parameter is required")
+		return toolErrorResult("", terr), &MediaSearchOutput{Query: args.Query, Category: category, Error: terr}, nil
+	}
+
+	// Set defaults
+	if args.PageNo == 0 {
+		args.PageNo = 1
+	}
+	if args.Language == "" {
+		args.Language = "all"
+	}
+	if args.SafeSearch == "" {
+		args.SafeSearch = "0"
+	}
+	maxResults := args.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultMediaResults
+	}
+	maxResults = min(maxResults, maxMediaResults)
+
+	// Perform search
+	startTime := time.Now()
+	resp, err := client.Search(ctx, SearchRequest{
+		Query:      args.Query,
+		PageNo:     args.PageNo,
+		TimeRange:  args.TimeRange,
+		Language:   args.Language,
+		SafeSearch: args.SafeSearch,
+		Categories: []string{category},
+	})
+	if err != nil {
+		terr := classifyError(err)
+		return toolErrorResult("Search failed", terr), &MediaSearchOutput{Query: args.Query, Category: category, Error: terr}, nil
+	}
+	duration := time.Since(startTime)
+
+	raw, _ := dedupeResults(resp.Results)
+	var results []MediaResult
+	for _, r := range raw {
+		// Image results without an image are page links that slipped in
+		// from general engines; they're useless to an agent picking media.
+		if category == "images" && r.ImgSrc == "" {
+			continue
+		}
+		results = append(results, toMediaResult(len(results)+1, r))
+		if len(results) == maxResults {
+			break
+		}
+	}
+
+	// Format output
+	if len(results) == 0 {
+		output := fmt.Sprintf("# No Results Found\n\nNo %s found for query: \"%s\"", category, args.Query)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: output},
+			},
+		}, &MediaSearchOutput{Query: args.Query, Category: category}, nil
+	}
+
+	output := fmt.Sprintf("# %s Results for \"%s\"\n\n", strings.ToUpper(category[:1])+category[1:], args.Query)
+	output += fmt.Sprintf("Found %d results (page %d) in %dms\n\n", len(results), args.PageNo, duration.Milliseconds())
+
+	for _, r := range results {
+		output += fmt.Sprintf("## %d. %s\n\n", r.Rank, r.Title)
+		if r.MediaURL != "" {
+			output += fmt.Sprintf("**Image:** %s\n\n", r.MediaURL)
+		}
+		output += fmt.Sprintf("**Page:** %s\n\n", r.URL)
+		if r.EmbedURL != "" {
+			output += fmt.Sprintf("**Embed:** %s\n\n", r.EmbedURL)
+		}
+		if r.ThumbnailURL != "" {
+			output += fmt.Sprintf("**Thumbnail:** %s\n\n", r.ThumbnailURL)
+		}
+
+		var details []string
+		for _, d := range []struct{ label, value string }{
+			{"Resolution", r.Resolution},
+			{"Format", r.Format},
+			{"Duration", r.Duration},
+			{"Author", r.Author},
+			{"Source", r.Source},
+		} {
+			if d.value != "" {
+				details = append(details, fmt.Sprintf("**%s:** %s", d.label, d.value))
+			}
+		}
+		if len(details) > 0 {
+			output += strings.Join(details, " · ") + "\n\n"
+		}
+		if r.Content != "" {
+			output += r.Content + "\n\n"
+		}
+		output += "---\n\n"
+	}
+
+	content := []mcp.Content{&mcp.TextContent{Text: output}}
+	if args.IncludeThumbnails {
+		content = append(content, fetchThumbnails(ctx, reader, results)...)
+	}
+
+	return &mcp.CallToolResult{
+		Content: content,
+	}, &MediaSearchOutput{Query: args.Query, Category: category, Results: results}, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestFormatMediaDuration(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"  ", ""},
+		{"45", "0:45"},
+		{"225", "3:45"},
+		{"3725.6", "1:02:05"},
+		{"0:45", "0:45"},
+		{"3:45", "3:45"},
+		{"03:45", "3:45"},
+		{"00:45", "0:45"},
+		{"0:03:45", "3:45"},
+		{"00:00:07", "0:07"},
+		{"0:12:00", "12:00"},
+		{"1:02:03", "1:02:03"},
+		{"12:00:00", "12:00:00"},
+		{" 4:20 ", "4:20"},
+		{"live", "live"},
+	}
+	for _, tt := range tests {
+		if got := formatMediaDuration(tt.in); got != tt.want {
+			t.Errorf("formatMediaDuration(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// rrfK is the reciprocal rank fusion constant from Cormack et al.;
+	// 60 damps the influence of top ranks in any single list.
+	rrfK = 60
+
+	maxMultiQueries        = 8
+	defaultMultiMaxResults = 20
+	maxMultiResults        = 50
+)
+
+// MultiSearchArgs defines the parameters for multi-query search
+type MultiSearchArgs struct {
+	Queries    []string `json:"queries" jsonschema:"phrasings of the same question (1-8, ideally several), searched concurrently and fused into one ranking"`
+	TimeRange  string   `json:"time_range,omitempty" jsonschema:"time range of search (day, week, month, or year)"`
+	Language   string   `json:"language,omitempty" jsonschema:"language code for search results (e.g., 'en', 'fr', 'de')"`
+	SafeSearch string   `json:"safesearch,omitempty" jsonschema:"safe search filter level (0: None, 1: Moderate, 2: Strict)"`
+	MaxResults int      `json:"max_results,omitempty" jsonschema:"maximum number of fused results to return (default: 20, max: 50)"`
+	MaxTokens  int      `json:"max_tokens,omitempty" jsonschema:"approximate token budget for the output; lowest-ranked results are dropped first"`
+}
+
+// QueryHit records that a query surfaced a result, and at which rank.
+type QueryHit struct {
+	Query string `json:"query"`
+	Rank  int    `json:"rank"`
+}
+
+// FusedResult is a result in the fused ranking.
+type FusedResult struct {
+	Rank    int        `json:"rank"`
+	Title   string     `json:"title"`
+	URL     string     `json:"url"`
+	Content string     `json:"content"`
+	Score   float64    `json:"score" jsonschema:"reciprocal rank fusion score: sum of 1/(60+rank) over the queries that found it"`
+	FoundBy []QueryHit `json:"foundBy"`
+}
+
+// MultiSearchOutput is multi_search's structured output.
+type MultiSearchOutput struct {
+	Queries       []string      `json:"queries"`
+	FailedQueries []string      `json:"failedQueries,omitempty"`
+	Results       []FusedResult `json:"results,omitempty"`
+	Error         *ToolError    `json:"error,omitempty"`
+}
+
+// fuseRankings merges per-query result lists with reciprocal rank fusion.
+// Each list is deduplicated first, so a query can vote for a page only
+// once; lists are then merged by dedupeKey. Ties are broken by how
+// many queries found the result, then by best single rank.
+func fuseRankings(queries []string, lists [][]SearXNGResult) []FusedResult {
+	index := make(map[string]int)
+	var fused []FusedResult
+
+	for q, list := range lists {
+		list, _ = dedupeResults(list)
+		for i, r := range list {
+			rank := i + 1
+			key := dedupeKey(r.URL)
+			idx, seen := index[key]
+			if !seen {
+				idx = len(fused)
+				index[key] = idx
+				fused = append(fused, FusedResult{Title: r.Title, URL: r.URL, Content: r.Content})
+			}
+
+			f := &fused[idx]
+			f.Score += 1.0 / float64(rrfK+rank)
+			f.FoundBy = append(f.FoundBy, QueryHit{Query: queries[q], Rank: rank})
+			if len(r.Content) > len(f.Content) {
+				f.Content = r.Content
+			}
+		}
+	}
+
+	bestRank := func(f FusedResult) int {
+		best := f.FoundBy[0].Rank
+		for _, hit := range f.FoundBy {
+			best = min(best, hit.Rank)
+		}
+		return best
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		if len(fused[i].FoundBy) != len(fused[j].FoundBy) {
+			return len(fused[i].FoundBy) > len(fused[j].FoundBy)
+		}
+		return bestRank(fused[i]) < bestRank(fused[j])
+	})
+	for i := range fused {
+		fused[i].Rank = i + 1
+	}
+
+	return fused
+}
+
+func handleMultiSearch(ctx context.Context, req *mcp.CallToolRequest, client *SearXNGClient, args MultiSearchArgs) (*mcp.CallToolResult, *MultiSearchOutput, error) {
+	// Validate queries, dropping blanks and repeats
+	queries := []string{}
+	for _, q := range args.Queries {
+		q = strings.TrimSpace(q)
+		if q != "" && !containsFold(queries, q) {
+			queries = append(queries, q)
+		}
+	}
+	if len(queries) == 0 {
+		terr := invalidArgument("queries parameter must contain at least one non-empty query")
+		return toolErrorResult("", terr), &MultiSearchOutput{Queries: queries, Error: terr}, nil
+	}
+	if len(queries) > maxMultiQueries {
+		terr := invalidArgument("at most %d queries are allowed, got %d", maxMultiQueries, len(queries))
+		return toolErrorResult("", terr), &MultiSearchOutput{Queries: queries, Error: terr}, nil
+	}
+
+	// Set defaults
+	if args.Language == "" {
+		args.Language = "all"
+	}
+	if args.SafeSearch == "" {
+		args.SafeSearch = "0"
+	}
+	maxResults := args.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultMultiMaxResults
+	}
+	maxResults = min(maxResults, maxMultiResults)
+
+	// Run all queries concurrently
+	startTime := time.Now()
+	lists := make([][]SearXNGResult, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Search(ctx, SearchRequest{
+				Query:      q,
+				PageNo:     1,
+				TimeRange:  args.TimeRange,
+				Language:   args.Language,
+				SafeSearch: args.SafeSearch,
+			})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			lists[i] = resp.Results
+		}()
+	}
+	wg.Wait()
+	duration := time.Since(startTime)
+
+	var failed []string
+	var failures string
+	var failedErrs []*ToolError
+	for i, err := range errs {
+		if err != nil {
+			terr := classifyError(err)
+			failed = append(failed, queries[i])
+			failedErrs = append(failedErrs, terr)
+			failures += fmt.Sprintf("- \"%s\": %v [%s]\n", queries[i], err, terr.Code)
+		}
+	}
+	if len(failed) == len(queries) {
+		terr := combineQueryErrors(failedErrs)
+		result := toolErrorResult("Search failed for every query", terr)
+		result.Content = append(result.Content, &mcp.TextContent{Text: "Failures:\n" + failures})
+		return result, &MultiSearchOutput{Queries: queries, FailedQueries: failed, Error: terr}, nil
+	}
+
+	fused := fuseRankings(queries, lists)
+	if len(fused) > maxResults {
+		fused = fused[:maxResults]
+	}
+
+	// Format output
+	output := fmt.Sprintf("# Fused Results for %d Queries\n\n", len(queries))
+	for _, q := range queries {
+		output += fmt.Sprintf("- \"%s\"\n", q)
+	}
+	output += fmt.Sprintf("\nFound %d distinct results in %dms (ranked by reciprocal rank fusion)\n\n", len(fused), duration.Milliseconds())
+	if failures != "" {
+		output += "**Some queries failed:**\n" + failures + "\n"
+	}
+
+	shown := 0
+	for _, f := range fused {
+		var hits []string
+		for _, hit := range f.FoundBy {
+			hits = append(hits, fmt.Sprintf("\"%s\" (#%d)", hit.Query, hit.Rank))
+		}
+
+		entry := fmt.Sprintf("## %d. %s\n\n", f.Rank, f.Title)
+		entry += fmt.Sprintf("**URL:** %s\n\n", f.URL)
+		entry += fmt.Sprintf("**Found by:** %s\n\n", strings.Join(hits, ", "))
+		entry += fmt.Sprintf("%s\n\n", f.Content)
+		entry += "---\n\n"
+
+		if args.MaxTokens > 0 && shown > 0 && estimateTokens(output+entry) > args.MaxTokens {
+			break
+		}
+		output += entry
+		shown++
+	}
+	if shown < len(fused) {
+		output += fmt.Sprintf("*Output truncated to fit max_tokens=%d: showing %d of %d results. Raise max_tokens to see the rest.*\n", args.MaxTokens, shown, len(fused))
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: output},
+		},
+	}, &MultiSearchOutput{Queries: queries, FailedQueries: failed, Results: fused[:shown]}, nil
+}
+
+// combineQueryErrors reports the failures of every query as one error.
+// Its code is the one most of them share (the first query's on a tie);
+// it's retryable if any of them is, after the longest wait any asked for.
+func combineQueryErrors(errs []*ToolError) *ToolError {
+	counts := make(map[ErrorCode]int)
+	code := errs[0].Code
+	for _, e := range errs {
+		counts[e.Code]++
+		if counts[e.Code] > counts[code] {
+			code = e.Code
+		}
+	}
+
+	var summary []string
+	for _, e := range errs {
+		if n := counts[e.Code]; n > 0 {
+			summary = append(summary, fmt.Sprintf("%d× %s", n, e.Code))
+			counts[e.Code] = 0
+		}
+	}
+	combined := newToolError(code, nil, "all %d queries failed (%s)", len(errs), strings.Join(summary, ", "))
+	for _, e := range errs {
+		combined.Retryable = combined.Retryable || e.Retryable
+		combined.RetryAfterSeconds = max(combined.RetryAfterSeconds, e.RetryAfterSeconds)
+	}
+	return combined
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func urlResult(url string) SearXNGResult {
+	return SearXNGResult{Title: url, URL: url}
+}
+
+func TestFuseRankings(t *testing.T) {
+	queries := []string{"q1", "q2", "q3"}
+	lists := [][]SearXNGResult{
+		{urlResult("https://a.example/"), urlResult("https://b.example/"), urlResult("https://c.example/")},
+		{urlResult("https://c.example/"), urlResult("https://b.example/"), urlResult("https://d.example/")},
+		// The same page twice, once through a tracking URL: it only
+		// counts once for this query
+		{urlResult("https://b.example/"), urlResult("https://b.example/?utm_source=x"), urlResult("https://e.example/")},
+	}
+	fused := fuseRankings(queries, lists)
+
+	// b: 1/62 + 1/62 + 1/61; c: 1/63 + 1/61; a: 1/61; e: 1/62; d: 1/63
+	want := []struct {
+		url   string
+		score float64
+		hits  int
+	}{
+		{"https://b.example/", 2.0/62 + 1.0/61, 3},
+		{"https://c.example/", 1.0/63 + 1.0/61, 2},
+		{"https://a.example/", 1.0 / 61, 1},
+		{"https://e.example/", 1.0 / 62, 1},
+		{"https://d.example/", 1.0 / 63, 1},
+	}
+	if len(fused) != len(want) {
+		t.Fatalf("got %d fused results, want %d: %+v", len(fused), len(want), fused)
+	}
+	for i, w := range want {
+		f := fused[i]
+		if f.URL != w.url || f.Rank != i+1 || len(f.FoundBy) != w.hits || math.Abs(f.Score-w.score) > 1e-12 {
+			t.Errorf("rank %d = %s (score %g, %d hits), want %s (score %g, %d hits)", i+1, f.URL, f.Score, len(f.FoundBy), w.url, w.score, w.hits)
+		}
+	}
+	if hit := fused[0].FoundBy[2]; hit.Query != "q3" || hit.Rank != 1 {
+		t.Errorf("b's hit for q3 = %+v, want rank 1", hit)
+	}
+}
+
+func TestFuseRankingsTies(t *testing.T) {
+	// Rank 64 for two queries scores 2/124, the same as rank 2 for one
+	// query; the result more queries found wins the tie
+	queries := []string{"q1", "q2", "q3"}
+	lists := [][]SearXNGResult{
+		append(makeResults("p", 1), urlResult("https://once.example/")),
+		append(makeResults("f2", 63), urlResult("https://twice.example/")),
+		append(makeResults("f3", 63), urlResult("https://twice.example/")),
+	}
+	fused := fuseRankings(queries, lists)
+
+	var once, twice int
+	for i, f := range fused {
+		switch f.URL {
+		case "https://once.example/":
+			once = i
+		case "https://twice.example/":
+			twice = i
+		}
+	}
+	if fused[once].Score != fused[twice].Score {
+		t.Fatalf("scores %g and %g aren't tied", fused[once].Score, fused[twice].Score)
+	}
+	if twice != once-1 {
+		t.Errorf("twice-found result at %d, once-found at %d; want it just ahead", twice+1, once+1)
+	}
+}
+
+func TestCombineQueryErrors(t *testing.T) {
+	timeout := newToolError(ErrTimeout, nil, "timed out")
+	limited := newToolError(ErrRateLimited, nil, "slow down")
+	limited.RetryAfterSeconds = 30
+	notFound := newToolError(ErrUpstream4xx, nil, "not found")
+
+	terr := combineQueryErrors([]*ToolError{notFound, timeout, limited, timeout})
+	if terr.Code != ErrTimeout {
+		t.Errorf("code = %s, want the most common one, timeout", terr.Code)
+	}
+	if !terr.Retryable || terr.RetryAfterSeconds != 30 {
+		t.Errorf("retryable = %v after %ds, want true after 30s", terr.Retryable, terr.RetryAfterSeconds)
+	}
+	if want := "all 4 queries failed (1× upstream_4xx, 2× timeout, 1× rate_limited)"; terr.Message != want {
+		t.Errorf("message = %q, want %q", terr.Message, want)
+	}
+
+	terr = combineQueryErrors([]*ToolError{notFound, notFound})
+	if terr.Code != ErrUpstream4xx || terr.Retryable {
+		t.Errorf("same failures combined to %+v", terr)
+	}
+}
+
+func multiSearch(t *testing.T, client *SearXNGClient, args MultiSearchArgs) (string, *MultiSearchOutput) {
+	t.Helper()
+	result, output, err := handleMultiSearch(context.Background(), &mcp.CallToolRequest{}, client, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toolText(result), output
+}
+
+// serveByQuery answers each query with its results, or fails it with
+// the status in fail.
+func serveByQuery(results map[string][]SearXNGResult, fail map[string]int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		if status, ok := fail[q]; ok {
+			http.Error(w, "failed", status)
+			return
+		}
+		json.NewEncoder(w).Encode(SearXNGResponse{Results: results[q]})
+	}
+}
+
+func TestMultiSearchFailures(t *testing.T) {
+	client := newTestSearXNG(t, serveByQuery(
+		map[string][]SearXNGResult{"good": makeResults("g", 2)},
+		map[string]int{"down": http.StatusServiceUnavailable, "gone": http.StatusNotFound, "also down": http.StatusBadGateway},
+	))
+
+	// Some fail: the rest are fused and the failures listed
+	text, output := multiSearch(t, client, MultiSearchArgs{Queries: []string{"good", "gone"}})
+	if output.Error != nil || len(output.Results) != 2 || len(output.FailedQueries) != 1 || !strings.Contains(text, "Some queries failed") {
+		t.Errorf("partial failure: %+v\n%s", output, text)
+	}
+
+	// All fail: one error for all of them, with the common code
+	text, output = multiSearch(t, client, MultiSearchArgs{Queries: []string{"down", "gone", "also down"}})
+	if output.Error == nil || output.Error.Code != ErrUpstream5xx || !output.Error.Retryable {
+		t.Fatalf("all failed: error = %+v", output.Error)
+	}
+	if !strings.Contains(text, "all 3 queries failed") || strings.Count(text, "\n- \"") != 3 {
+		t.Errorf("all failed output = %s", text)
+	}
+}
+
+func TestMultiSearchQueries(t *testing.T) {
+	client := newTestSearXNG(t, serveByQuery(map[string][]SearXNGResult{"a": makeResults("a", 1)}, nil))
+
+	// One query is accepted; blanks and repeats are dropped
+	_, output := multiSearch(t, client, MultiSearchArgs{Queries: []string{" a ", "A", ""}})
+	if output.Error != nil || len(output.Queries) != 1 || len(output.Results) != 1 {
+		t.Errorf("single query: %+v", output)
+	}
+
+	for _, queries := range [][]string{nil, {" ", ""}, {"1", "2", "3", "4", "5", "6", "7", "8", "9"}} {
+		if _, output := multiSearch(t, client, MultiSearchArgs{Queries: queries}); output.Error == nil || output.Error.Code != ErrInvalidArgument {
+			t.Errorf("queries %q: error = %+v, want invalid_argument", queries, output.Error)
+		}
+	}
+}
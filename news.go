@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultNewsPages = 2
+	maxNewsPages     = 5
+	newsDateLayout   = "2006-01-02"
+)
+
+// NewsSearchArgs defines the parameters for news search
+type NewsSearchArgs struct {
+	Query      string `json:"query" jsonschema:"the news search query"`
+	TimeRange  string `json:"time_range,omitempty" jsonschema:"only news from the last day, week, month, or year, as filtered by SearXNG; undated articles are kept and listed last"`
+	From       string `json:"from,omitempty" jsonschema:"only articles published on or after this date (YYYY-MM-DD); undated articles are dropped"`
+	To         string `json:"to,omitempty" jsonschema:"only articles published on or before this date (YYYY-MM-DD); undated articles are dropped"`
+	Language   string `json:"language,omitempty" jsonschema:"language code for search results (e.g., 'en', 'fr', 'de')"`
+	SafeSearch string `json:"safesearch,omitempty" jsonschema:"safe search filter level (0: None, 1: Moderate, 2: Strict)"`
+	Pages      int    `json:"pages,omitempty" jsonschema:"number of result pages to fetch and merge before date filtering (default: 2, max: 5)"`
+	MaxTokens  int    `json:"max_tokens,omitempty" jsonschema:"approximate token budget for the output; oldest articles are dropped first"`
+}
+
+// NewsResult is a news article with its source and publication time.
+type NewsResult struct {
+	Rank          int      `json:"rank"`
+	Title         string   `json:"title"`
+	URL           string   `json:"url"`
+	Content       string   `json:"content"`
+	Source        string   `json:"source"`
+	PublishedDate string   `json:"publishedDate,omitempty" jsonschema:"RFC 3339 publication time, if known"`
+	Engines       []string `json:"engines,omitempty"`
+}
+
+// NewsSearchOutput is news_search's structured output.
+type NewsSearchOutput struct {
+	Query   string       `json:"query"`
+	Results []NewsResult `json:"results,omitempty"`
+	Partial bool         `json:"partial,omitempty" jsonschema:"a page after the first failed, so only the articles of the pages before it are included"`
+	Error   *ToolError   `json:"error,omitempty"`
+}
+
+// newsWindow is an inclusive date filter; zero bounds are open.
+type newsWindow struct {
+	from, to time.Time
+}
+
+func parseNewsWindow(from, to string) (newsWindow, error) {
+	var w newsWindow
+	var err error
+	if from != "" {
+		if w.from, err = time.Parse(newsDateLayout, from); err != nil {
+			return w, fmt.Errorf("invalid from date %q: expected YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		if w.to, err = time.Parse(newsDateLayout, to); err != nil {
+			return w, fmt.Errorf("invalid to date %q: expected YYYY-MM-DD", to)
+		}
+		// Inclusive: cover the whole "to" day.
+		w.to = w.to.Add(24*time.Hour - time.Nanosecond)
+	}
+	if !w.from.IsZero() && !w.to.IsZero() && w.to.Before(w.from) {
+		return w, fmt.Errorf("to date %s is before from date %s", to, from)
+	}
+	return w, nil
+}
+
+func (w newsWindow) isSet() bool {
+	return !w.from.IsZero() || !w.to.IsZero()
+}
+
+func (w newsWindow) contains(t time.Time) bool {
+	if t.IsZero() {
+		return false
+	}
+	return (w.from.IsZero() || !t.Before(w.from)) && (w.to.IsZero() || !t.After(w.to))
+}
+
+// upstreamTimeRange picks the narrowest SearXNG time_range that still
+// covers the window's start, so SearXNG does most of the filtering and
+// the post-filter has enough candidates left.
+func (w newsWindow) upstreamTimeRange(now time.Time) string {
+	if w.from.IsZero() {
+		return ""
+	}
+	age := now.Sub(w.from)
+	switch {
+	case age <= 24*time.Hour:
+		return "day"
+	case age <= 7*24*time.Hour:
+		return "week"
+	case age <= 31*24*time.Hour:
+		return "month"
+	case age <= 366*24*time.Hour:
+		return "year"
+	}
+	return ""
+}
+
+// sourceName returns the publication's host, e.g. "reuters.com".
+func sourceName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return normalizeDomain(u.Hostname())
+}
+
+// relativeAge renders how long ago t was, e.g. "3 hours ago".
+func relativeAge(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < 0:
+		return "scheduled"
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d hours ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d days ago", int(d.Hours()/24))
+	}
+}
+
+func handleNewsSearch(ctx context.Context, req *mcp.CallToolRequest, client *SearXNGClient, args NewsSearchArgs) (*mcp.CallToolResult, *NewsSearchOutput, error) {
+	// Validate parameters
+	if args.Query == "" {
+		terr := invalidArgument("query parameter is required")
+		return toolErrorResult("", terr), &NewsSearchOutput{Query: args.Query, Error: terr}, nil
+	}
+	if args.TimeRange != "" && args.TimeRange != "day" && args.TimeRange != "week" && args.TimeRange != "month" && args.TimeRange != "year" {
+		terr := invalidArgument("invalid time_range %q: use day, week, month, or year", args.TimeRange)
+		return toolErrorResult("", terr), &NewsSearchOutput{Query: args.Query, Error: terr}, nil
+	}
+	window, err := parseNewsWindow(args.From, args.To)
+	if err != nil {
+		terr := invalidArgument("%v", err)
+		return toolErrorResult("", terr), &NewsSearchOutput{Query: args.Query, Error: terr}, nil
+	}
+
+	// Set defaults
+	now := time.Now()
+	if args.TimeRange == "" {
+		args.TimeRange = window.upstreamTimeRange(now)
+	}
+	if args.Language == "" {
+		args.Language = "all"
+	}
+	if args.SafeSearch == "" {
+		args.SafeSearch = "0"
+	}
+	pages := args.Pages
+	if pages <= 0 {
+		pages = defaultNewsPages
+	}
+	pages = min(pages, maxNewsPages)
+
+	// Perform search
+	startTime := time.Now()
+	raw, _, err := client.SearchPages(ctx, SearchRequest{
+		Query:      args.Query,
+		PageNo:     1,
+		TimeRange:  args.TimeRange,
+		Language:   args.Language,
+		SafeSearch: args.SafeSearch,
+		Categories: []string{"news"},
+	}, pages)
+	var pageErr *PageError
+	if errors.As(err, &pageErr) {
+		err = nil
+	}
+	if err != nil {
+		terr := classifyError(err)
+		return toolErrorResult("News search failed", terr), &NewsSearchOutput{Query: args.Query, Error: terr}, nil
+	}
+	duration := time.Since(startTime)
+	raw, _ = dedupeResults(raw)
+
+	// Filter to the requested window and sort newest first. Undated
+	// articles can't be checked against from/to, so they're dropped when
+	// either is given; time_range alone is left to SearXNG, which keeps
+	// them, and they sort last.
+	type dated struct {
+		result    SearXNGResult
+		published time.Time
+	}
+	var articles []dated
+	dropped := 0
+	for _, r := range raw {
+		published := parsePublishedDate(r.PublishedDate)
+		if window.isSet() && !window.contains(published) {
+			dropped++
+			continue
+		}
+		articles = append(articles, dated{r, published})
+	}
+	sort.SliceStable(articles, func(i, j int) bool {
+		return articles[i].published.After(articles[j].published)
+	})
+
+	// Format output
+	if len(articles) == 0 {
+		output := fmt.Sprintf("# No News Found\n\nNo news articles found for query: \"%s\"", args.Query)
+		if dropped > 0 {
+			output += fmt.Sprintf("\n\n%d articles were outside the requested dates or had no publication date.", dropped)
+		}
+		if pageErr != nil {
+			output += "\n\n" + strings.TrimSpace(partialResultsNote(pageErr))
+		}
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: output},
+			},
+		}, &NewsSearchOutput{Query: args.Query, Partial: pageErr != nil}, nil
+	}
+
+	output := fmt.Sprintf("# News for \"%s\"\n\n", args.Query)
+	output += fmt.Sprintf("Found %d articles in %dms, newest first", len(articles), duration.Milliseconds())
+	if window.isSet() {
+		output += fmt.Sprintf(" (%d outside %s to %s or undated, filtered out)", dropped, orOpen(args.From), orOpen(args.To))
+	}
+	output += "\n\n"
+	if pageErr != nil {
+		output += partialResultsNote(pageErr)
+	}
+
+	var results []NewsResult
+	for i, a := range articles {
+		result := NewsResult{
+			Rank:    i + 1,
+			Title:   a.result.Title,
+			URL:     a.result.URL,
+			Content: a.result.Content,
+			Source:  sourceName(a.result.URL),
+			Engines: a.result.Engines,
+		}
+		when := "date unknown"
+		if !a.published.IsZero() {
+			result.PublishedDate = a.published.Format(time.RFC3339)
+			when = a.published.UTC().Format("2006-01-02 15:04 UTC") + " (" + relativeAge(a.published, now) + ")"
+		}
+
+		entry := fmt.Sprintf("## %d. %s\n\n", result.Rank, result.Title)
+		entry += fmt.Sprintf("**Source:** %s · **Published:** %s\n\n", result.Source, when)
+		entry += fmt.Sprintf("**URL:** %s\n\n", result.URL)
+		entry += fmt.Sprintf("%s\n\n", result.Content)
+		entry += "---\n\n"
+
+		if args.MaxTokens > 0 && len(results) > 0 && estimateTokens(output+entry) > args.MaxTokens {
+			break
+		}
+		output += entry
+		results = append(results, result)
+	}
+	if len(results) < len(articles) {
+		output += fmt.Sprintf("*Output truncated to fit max_tokens=%d: showing the %d newest of %d articles.*\n", args.MaxTokens, len(results), len(articles))
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: output},
+		},
+	}, &NewsSearchOutput{Query: args.Query, Results: results, Partial: pageErr != nil}, nil
+}
+
+func orOpen(date string) string {
+	if strings.TrimSpace(date) == "" {
+		return "any"
+	}
+	return date
+}
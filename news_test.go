@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newsSearch(t *testing.T, client *SearXNGClient, args NewsSearchArgs) (string, *NewsSearchOutput) {
+	t.Helper()
+	result, output, err := handleNewsSearch(context.Background(), &mcp.CallToolRequest{}, client, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toolText(result), output
+}
+
+// serveNews answers news searches with results, recording the
+// time_range each request asked for.
+func serveNews(results []SearXNGResult, timeRanges *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("categories") != "news" || q.Get("pageno") != "1" {
+			json.NewEncoder(w).Encode(SearXNGResponse{})
+			return
+		}
+		*timeRanges = append(*timeRanges, q.Get("time_range"))
+		json.NewEncoder(w).Encode(SearXNGResponse{Results: results})
+	}
+}
+
+func newsTitles(output *NewsSearchOutput) []string {
+	var titles []string
+	for _, r := range output.Results {
+		titles = append(titles, r.Title)
+	}
+	return titles
+}
+
+func TestNewsSearchUndatedArticles(t *testing.T) {
+	now := time.Now().UTC()
+	day := func(daysAgo int) string { return now.AddDate(0, 0, -daysAgo).Format(time.RFC3339) }
+	results := []SearXNGResult{
+		{Title: "undated", URL: "https://a.example/undated"},
+		{Title: "old", URL: "https://b.example/old", PublishedDate: day(20)},
+		{Title: "recent", URL: "https://c.example/recent", PublishedDate: day(1)},
+		{Title: "bad date", URL: "https://d.example/bad", PublishedDate: "sometime"},
+	}
+	var timeRanges []string
+	client := newTestSearXNG(t, serveNews(results, &timeRanges))
+
+	// time_range alone is left to SearXNG: nothing is dropped, and the
+	// undated articles sort last
+	_, output := newsSearch(t, client, NewsSearchArgs{Query: "q", TimeRange: "month", Pages: 1})
+	if got := newsTitles(output); len(got) != 4 || got[0] != "recent" || got[1] != "old" {
+		t.Errorf("time_range results = %v, want recent, old, then the undated ones", got)
+	}
+	if output.Results[3].PublishedDate != "" {
+		t.Errorf("undated article has date %q", output.Results[3].PublishedDate)
+	}
+
+	// from/to post-filters on the date, which drops undated articles too
+	from := now.AddDate(0, 0, -6).Format(newsDateLayout)
+	text, output := newsSearch(t, client, NewsSearchArgs{Query: "q2", From: from, Pages: 1})
+	if got := newsTitles(output); len(got) != 1 || got[0] != "recent" {
+		t.Errorf("from results = %v, want only recent", got)
+	}
+	if want := "(3 outside " + from + " to any or undated, filtered out)"; !strings.Contains(text, want) {
+		t.Errorf("output doesn't say %q:\n%s", want, text)
+	}
+	if timeRanges[1] != "week" {
+		t.Errorf("from 6 days ago asked SearXNG for time_range=%q, want week", timeRanges[1])
+	}
+
+	// A window nothing falls in explains what was dropped
+	text, output = newsSearch(t, client, NewsSearchArgs{Query: "q3", From: "2001-01-01", To: "2001-12-31", Pages: 1})
+	if len(output.Results) != 0 || !strings.Contains(text, "4 articles were outside the requested dates or had no publication date") {
+		t.Errorf("empty window output:\n%s", text)
+	}
+}
@@ -3,17 +3,17 @@ package main
 import (
 	"net/http"
 	"net/url"
-	"os"
 )
 
 type ProxyConfig struct {
 	Transport http.RoundTripper
 }
 
-func LoadProxyConfig() *ProxyConfig {
-	// Check for HTTP_PROXY and HTTPS_PROXY environment variables
-	httpProxy := os.Getenv("HTTP_PROXY")
-	httpsProxy := os.Getenv("HTTPS_PROXY")
+// NewProxyConfig builds a proxying transport from the proxy settings, or
+// returns nil if no proxy is configured.
+func NewProxyConfig(settings ProxySettings) *ProxyConfig {
+	httpProxy := settings.HTTP
+	httpsProxy := settings.HTTPS
 
 	if httpProxy == "" && httpsProxy == "" {
 		return nil
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// passageChunkSize is the target chunk length in characters. Large
+	// enough to hold a few paragraphs of context, small enough that the
+	// top few chunks fit comfortably in a model's context.
+	passageChunkSize = 1200
+	defaultTopK      = 5
+	maxTopK          = 20
+
+	// Standard BM25 parameters.
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// stopwords are dropped from queries and passages before ranking so
+// that matches on "the" or "how" don't dominate short queries.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "can": true, "do": true, "does": true, "for": true,
+	"from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"of": true, "on": true, "or": true, "that": true, "the": true, "this": true,
+	"to": true, "was": true, "what": true, "when": true, "where": true,
+	"which": true, "who": true, "why": true, "with": true, "you": true,
+}
+
+// passage is a heading-aware chunk of a document. Offsets are in
+// characters (runes), like startChar.
+type passage struct {
+	Path   []string
+	Offset int
+	Size   int
+	Text   string
+	Score  float64
+
+	terms map[string]int
+	len   int
+}
+
+// chunkPassages splits content into passages that never span a heading:
+// each section body is cut into ~passageChunkSize pieces at Markdown-safe
+// boundaries, and every piece remembers the heading path it sits under.
+// It makes a single pass over content, so it stays linear in page size.
+func chunkPassages(content string) []passage {
+	toc := buildTOC(content)
+
+	// Section boundaries: the document start plus every heading.
+	starts := []int{0}
+	paths := [][]string{headingPathAt(toc, 0)}
+	for _, e := range toc {
+		if e.start > 0 {
+			starts = append(starts, e.start)
+			paths = append(paths, e.Path)
+		}
+	}
+	starts = append(starts, len(content))
+
+	var passages []passage
+	runes := 0
+	for i := 0; i+1 < len(starts); i++ {
+		segment := content[starts[i]:starts[i+1]]
+		blocks := markdownBlocks(segment)
+
+		for offset := 0; offset < len(segment); {
+			end := offset + byteOffset(segment[offset:], passageChunkSize)
+			end = snapChunkEnd(segment, blocks, offset, end)
+			if end <= offset {
+				end = len(segment)
+			}
+
+			size := utf8.RuneCountInString(segment[offset:end])
+			if text := strings.TrimSpace(segment[offset:end]); text != "" {
+				passages = append(passages, passage{
+					Path:   paths[i],
+					Offset: runes,
+					Size:   size,
+					Text:   text,
+				})
+			}
+			runes += size
+			offset = end
+		}
+	}
+
+	return passages
+}
+
+// tokenize lowercases s and splits it into words, dropping stopwords.
+// Han, Hiragana, Katakana and Hangul characters become one token each,
+// since those scripts don't separate words with spaces.
+func tokenize(s string) []string {
+	var tokens []string
+	var word strings.Builder
+
+	flush := func() {
+		if word.Len() > 0 {
+			if w := word.String(); !stopwords[w] {
+				tokens = append(tokens, w)
+			}
+			word.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unspacedScript(r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// unspacedScript reports whether r belongs to a script that doesn't
+// separate words with spaces, where each character is treated as a word.
+func unspacedScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// rankPassages scores passages against query with BM25 and returns them
+// best first. Heading paths are indexed along with the text, so a
+// passage under "Configuration" matches a query about configuration.
+// Passages with no query terms at all are dropped.
+func rankPassages(passages []passage, query string) []passage {
+	queryTerms := tokenize(query)
+	if len(queryTerms) == 0 || len(passages) == 0 {
+		return nil
+	}
+
+	docFreq := make(map[string]int)
+	totalLen := 0
+	for i := range passages {
+		p := &passages[i]
+		p.terms = make(map[string]int)
+		tokens := tokenize(strings.Join(p.Path, " ") + " " + p.Text)
+		for _, t := range tokens {
+			p.terms[t]++
+		}
+		p.len = len(tokens)
+		totalLen += p.len
+		for t := range p.terms {
+			docFreq[t]++
+		}
+	}
+
+	n := float64(len(passages))
+	avgLen := float64(totalLen) / n
+
+	var ranked []passage
+	for _, p := range passages {
+		score := 0.0
+		for _, t := range queryTerms {
+			tf := float64(p.terms[t])
+			if tf == 0 {
+				continue
+			}
+			df := float64(docFreq[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(p.len)/avgLen))
+		}
+		if score > 0 {
+			p.Score = score
+			ranked = append(ranked, p)
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+
+	return ranked
+}
+
+// renderRankedPassages formats the top passages as Markdown.
+func renderRankedPassages(query string, ranked []passage, total, topK int) string {
+	if len(ranked) == 0 {
+		return fmt.Sprintf("# No Relevant Passages\n\nNo passages in this page mention the terms in \"%s\".", query)
+	}
+
+	shown := ranked[:min(topK, len(ranked))]
+	output := fmt.Sprintf("# Most Relevant Passages for \"%s\"\n\n", query)
+	output += fmt.Sprintf("%d of %d chunks matched; showing the top %d.\n\n", len(ranked), total, len(shown))
+
+	for i, p := range shown {
+		output += fmt.Sprintf("## %d. ", i+1)
+		if len(p.Path) > 0 {
+			output += strings.Join(p.Path, " > ") + " · "
+		}
+		output += fmt.Sprintf("score %.2f · offset %d, %d chars\n\n", p.Score, p.Offset, p.Size)
+		output += p.Text + "\n\n---\n\n"
+	}
+
+	return output
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// largePage builds a Markdown page of roughly size bytes with headings,
+// prose, code fences and tables, like a long documentation page.
+func largePage(size int) string {
+	var b strings.Builder
+	for section := 1; b.Len() < size; section++ {
+		fmt.Fprintf(&b, "## Section %d\n\n", section)
+		for i := 0; i < 20 && b.Len() < size; i++ {
+			b.WriteString("Prose about configuration, caching and the [reference](https://example.com/ref) with `flags` and 日本語.\n\n")
+			if i%5 == 0 {
+				b.WriteString("```go\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n```\n\n| option | default |\n|---|---|\n| timeout | 30s |\n\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+func TestChunkPassagesOffsets(t *testing.T) {
+	content := "Intro text before any heading.\n\n" + largePage(40_000)
+	runes := []rune(content)
+	toc := buildTOC(content)
+
+	passages := chunkPassages(content)
+	if len(passages) < 20 {
+		t.Fatalf("got %d passages, want a few dozen", len(passages))
+	}
+	next := 0
+	for i, p := range passages {
+		if p.Offset < next {
+			t.Fatalf("passage %d at %d overlaps the previous one ending at %d", i, p.Offset, next)
+		}
+		raw := string(runes[p.Offset : p.Offset+p.Size])
+		if strings.TrimSpace(raw) != p.Text {
+			t.Fatalf("passage %d offset/size don't match its text: %q vs %q", i, raw, p.Text)
+		}
+		if p.Size > passageChunkSize {
+			t.Fatalf("passage %d has %d characters, over %d", i, p.Size, passageChunkSize)
+		}
+		if strings.Contains(strings.TrimPrefix(p.Text, "## "), "\n## ") {
+			t.Fatalf("passage %d spans a heading: %q", i, p.Text)
+		}
+		byteStart := len(string(runes[:p.Offset]))
+		if want := headingPathAt(toc, byteStart); strings.Join(p.Path, ">") != strings.Join(want, ">") {
+			t.Fatalf("passage %d has path %v, want %v", i, p.Path, want)
+		}
+		next = p.Offset + p.Size
+	}
+	if next != len(runes) {
+		t.Fatalf("passages end at %d, document has %d characters", next, len(runes))
+	}
+}
+
+// TestChunkPassagesScalesLinearly guards against chunking going
+// quadratic again: at 10MB (url_reader.max_bytes' default) that took
+// minutes. The bound is loose enough for slow CI machines.
+func TestChunkPassagesScalesLinearly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("large input")
+	}
+	for name, content := range map[string]string{
+		"markdown": largePage(10 << 20),
+		"one line": strings.Repeat("word ", 2<<20),
+	} {
+		start := time.Now()
+		passages := chunkPassages(content)
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("%s: chunking %d bytes took %s", name, len(content), elapsed)
+		}
+		if len(passages) == 0 || !utf8.ValidString(passages[len(passages)-1].Text) {
+			t.Errorf("%s: bad passages", name)
+		}
+	}
+}
+
+func BenchmarkChunkPassages(b *testing.B) {
+	for _, size := range []int{1 << 20, 4 << 20} {
+		content := largePage(size)
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				chunkPassages(content)
+			}
+		})
+	}
+}
+
+func TestRankPassages(t *testing.T) {
+	content := "# Guide\n\n## Install\n\nDownload the binary and run it.\n\n## Configuration\n\nSet the timeout in the config file.\n\n## 日本語\n\n設定ファイルでタイムアウトを設定します。\n"
+	ranked := rankPassages(chunkPassages(content), "how to configure the timeout")
+	if len(ranked) == 0 || ranked[0].Path[len(ranked[0].Path)-1] != "Configuration" {
+		t.Fatalf("top passage = %+v, want the Configuration section", ranked)
+	}
+	if ranked := rankPassages(chunkPassages(content), "タイムアウト"); len(ranked) == 0 || ranked[0].Path[1] != "日本語" {
+		t.Fatalf("CJK query ranked %+v, want the 日本語 section first", ranked)
+	}
+	if ranked := rankPassages(chunkPassages(content), "the of and"); ranked != nil {
+		t.Fatalf("stopword-only query ranked %d passages", len(ranked))
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configWatchInterval is how often the config file is checked for changes.
+const configWatchInterval = 5 * time.Second
+
+// Reloader holds the running configuration and reloads it on SIGHUP or
+// when the config file (or a file it names, such as a tokens file or a
+// secret) changes. An invalid configuration, or one the services fail to
+// apply, is logged and rejected, leaving the running one in place.
+type Reloader struct {
+	args []string
+
+	// reloadMu serializes reloads, so callbacks never run concurrently
+	reloadMu sync.Mutex
+
+	mu       sync.RWMutex
+	config   *Config
+	onReload []ReloadFunc
+}
+
+// A ReloadFunc prepares services for a reloaded configuration without
+// changing them: it does everything that can fail, such as loading keys
+// and opening files. commit applies what it prepared and can't fail;
+// abort, which may be nil, releases it if the reload is abandoned.
+type ReloadFunc func(old, new *Config) (commit, abort func(), err error)
+
+// NewReloader starts from config, which was loaded from args; reloads
+// load the same args again.
+func NewReloader(config *Config, args []string) *Reloader {
+	return &Reloader{args: args, config: config}
+}
+
+// Current returns the configuration in effect.
+func (r *Reloader) Current() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.config
+}
+
+// OnReload registers fn to apply a reloaded configuration.
+func (r *Reloader) OnReload(fn ReloadFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onReload = append(r.onReload, fn)
+}
+
+// Reload loads and validates the configuration again and, if it's valid
+// and every ReloadFunc prepares it, applies it. Otherwise nothing is
+// applied and the running configuration stays current. It's applied even
+// if no setting changed, since the files the settings point to (e.g. the
+// tokens file) may have.
+func (r *Reloader) Reload() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	config, err := LoadConfig(r.args)
+	if err != nil {
+		return err
+	}
+
+	old := r.Current()
+	kept := keepRestartOnly(old, config)
+	changes := configChanges(old, config)
+
+	r.mu.RLock()
+	callbacks := slices.Clone(r.onReload)
+	r.mu.RUnlock()
+
+	var commits, aborts []func()
+	for _, fn := range callbacks {
+		commit, abort, err := fn(old, config)
+		if err != nil {
+			for _, abort := range aborts {
+				abort()
+			}
+			return err
+		}
+		commits = append(commits, commit)
+		if abort != nil {
+			aborts = append(aborts, abort)
+		}
+	}
+
+	for _, commit := range commits {
+		commit()
+	}
+	r.mu.Lock()
+	r.config = config
+	r.mu.Unlock()
+
+	for _, key := range kept {
+		log.Printf("warning: %s changed in the configuration but only takes effect after a restart", key)
+	}
+	if len(changes) == 0 {
+		log.Printf("Configuration reloaded, no settings changed")
+	} else {
+		log.Printf("Configuration reloaded: %s", strings.Join(changes, "; "))
+	}
+	return nil
+}
+
+// Run reloads on SIGHUP, and when the config file changes, until ctx is
+// cancelled.
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	lastMod := r.Current().filesVersion()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Printf("SIGHUP received, reloading configuration")
+		case <-ticker.C:
+			mod := r.Current().filesVersion()
+			if mod == lastMod {
+				continue
+			}
+			lastMod = mod
+			log.Printf("Configuration files changed, reloading configuration")
+		}
+
+		if err := r.Reload(); err != nil {
+			log.Printf("warning: configuration reload rejected, keeping the running configuration:\n%v", err)
+		}
+	}
+}
+
+// filesVersion identifies the versions of the config file and the files
+// it names (tokens, secrets, certificates) by their modification times
+// and sizes.
+func (c *Config) filesVersion() string {
+	var version string
+	paths := []string{c.File, c.Auth.TokensFile, c.Auth.JWKSFile,
+		c.SearXNG.PasswordFile, c.SearXNG.BearerTokenFile, c.SearXNG.TLSCertFile, c.SearXNG.TLSKeyFile, c.SearXNG.CAFile}
+	for _, path := range c.SearXNG.HeaderFiles {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			version += fmt.Sprintf("%s:%d/%d;", path, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return version
+}
+
+// keepRestartOnly copies the settings that can't change while running
+// (the listener and the background check intervals) from old into new,
+// returning the keys that were changed.
+func keepRestartOnly(old, new *Config) []string {
+	var kept []string
+	if new.Server.Transport != old.Server.Transport {
+		kept = append(kept, "server.transport")
+		new.Server.Transport = old.Server.Transport
+	}
+	if new.Server.HTTPAddr != old.Server.HTTPAddr {
+		kept = append(kept, "server.http_addr")
+		new.Server.HTTPAddr = old.Server.HTTPAddr
+	}
+	if new.Health.Interval != old.Health.Interval {
+		kept = append(kept, "health.interval")
+		new.Health.Interval = old.Health.Interval
+	}
+	if new.SearXNG.ConfigRefresh != old.SearXNG.ConfigRefresh {
+		kept = append(kept, "searxng.config_refresh")
+		new.SearXNG.ConfigRefresh = old.SearXNG.ConfigRefresh
+	}
+	return kept
+}
+
+// configChanges lists the settings that differ between two configs, as
+// "cache.ttl: 60 -> 120". Secrets are reported as changed, never shown.
+func configChanges(old, new *Config) []string {
+	before, after := old.flatten(), new.flatten()
+
+	var changes []string
+	for key, value := range after {
+		if before[key] != value {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", key, before[key], value))
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			changes = append(changes, fmt.Sprintf("%s: %s -> (unset)", key, value))
+		}
+	}
+	if old.SearXNG.Password != new.SearXNG.Password {
+		changes = append(changes, "searxng.password: changed")
+	}
+	if old.SearXNG.BearerToken != new.SearXNG.BearerToken {
+		changes = append(changes, "searxng.bearer_token: changed")
+	}
+	if !maps.Equal(old.SearXNG.Headers, new.SearXNG.Headers) {
+		changes = append(changes, "searxng.headers: changed")
+	}
+	if old.Auth.HMACSecret != new.Auth.HMACSecret {
+		changes = append(changes, "auth.hmac_secret: changed")
+	}
+	if old.File != new.File {
+		changes = append(changes, fmt.Sprintf("config file: %q -> %q", old.File, new.File))
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// flatten renders the settings as "section.key" -> value, without
+// secrets.
+func (c *Config) flatten() map[string]string {
+	var sections map[string]map[string]any
+	data, _ := yaml.Marshal(c.redacted(""))
+	_ = yaml.Unmarshal(data, &sections)
+
+	flat := make(map[string]string)
+	for section, fields := range sections {
+		for key, value := range fields {
+			flat[section+"."+key] = fmt.Sprint(value)
+		}
+	}
+	delete(flat, "searxng.password")
+	delete(flat, "searxng.bearer_token")
+	delete(flat, "searxng.headers")
+	delete(flat, "auth.hmac_secret")
+	return flat
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReloadCommitsOnlyIfEveryServicePrepares(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "http://localhost:8080")
+	t.Setenv("CACHE_TTL", "60")
+	initial, err := LoadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader := NewReloader(initial, nil)
+
+	var committed, aborted []string
+	failing := false
+	reloader.OnReload(func(old, new *Config) (func(), func(), error) {
+		return func() { committed = append(committed, "first") }, func() { aborted = append(aborted, "first") }, nil
+	})
+	reloader.OnReload(func(old, new *Config) (func(), func(), error) {
+		if failing {
+			return nil, nil, errors.New("cannot load keys")
+		}
+		return func() { committed = append(committed, "second") }, nil, nil
+	})
+
+	t.Setenv("CACHE_TTL", "120")
+	failing = true
+	if err := reloader.Reload(); err == nil || !strings.Contains(err.Error(), "cannot load keys") {
+		t.Fatalf("Reload error = %v, want the service's error", err)
+	}
+	if reloader.Current() != initial || len(committed) != 0 || strings.Join(aborted, ",") != "first" {
+		t.Fatalf("failed reload: config ttl %d, committed %v, aborted %v", reloader.Current().Cache.TTL, committed, aborted)
+	}
+
+	failing = false
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if reloader.Current().Cache.TTL != 120 || strings.Join(committed, ",") != "first,second" {
+		t.Fatalf("reload: config ttl %d, committed %v", reloader.Current().Cache.TTL, committed)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "http://localhost:8080")
+	initial, err := LoadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader := NewReloader(initial, nil)
+	called := false
+	reloader.OnReload(func(old, new *Config) (func(), func(), error) {
+		called = true
+		return func() {}, nil, nil
+	})
+
+	t.Setenv("CACHE_TTL", "-5")
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("Reload accepted an invalid configuration")
+	}
+	if called || reloader.Current() != initial {
+		t.Fatal("invalid configuration reached the services")
+	}
+}
+
+func TestAuditPrepareReconfigureAbort(t *testing.T) {
+	dir := t.TempDir()
+	audit, err := NewAuditLog(AuditSettings{File: dir + "/a.jsonl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, abort, err := audit.PrepareReconfigure(AuditSettings{File: dir + "/b.jsonl"})
+	if err != nil || commit == nil {
+		t.Fatalf("PrepareReconfigure: %v", err)
+	}
+	abort()
+	if audit.settings.File != dir+"/a.jsonl" {
+		t.Fatalf("aborted reconfigure switched to %s", audit.settings.File)
+	}
+	if _, _, err := audit.PrepareReconfigure(AuditSettings{File: dir + "/missing/c.jsonl"}); err == nil {
+		t.Fatal("PrepareReconfigure opened a file in a missing directory")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RankingConfig controls how web_search re-orders SearXNG's results.
+type RankingConfig struct {
+	// DomainWeights multiplies the score of results from a domain (and
+	// its subdomains): >1 boosts, <1 penalizes. A weight of 0 ranks the
+	// domain's results last, but they are still shown.
+	DomainWeights map[string]float64
+	// RecencyWeight is how much a brand-new result is boosted relative
+	// to an undated or very old one; 0 disables recency weighting.
+	RecencyWeight float64
+	// RecencyHalfLife is the age at which the recency boost halves.
+	RecencyHalfLife time.Duration
+}
+
+// ScoreComponents explains a result's final score:
+// Final = Base * Domain * (1 + Recency).
+type ScoreComponents struct {
+	Base    float64 `json:"base" jsonschema:"SearXNG's own relevance score, or 1/position if it reported none"`
+	Domain  float64 `json:"domain" jsonschema:"configured domain weight (1 if none applies)"`
+	Recency float64 `json:"recency" jsonschema:"recency boost from publishedDate (0 if undated or disabled)"`
+}
+
+// RankedResult is a search result with its re-ranked score.
+type RankedResult struct {
+	Rank            int             `json:"rank"`
+	Title           string          `json:"title"`
+	URL             string          `json:"url"`
+	Content         string          `json:"content"`
+	Engines         []string        `json:"engines,omitempty"`
+	PublishedDate   string          `json:"publishedDate,omitempty"`
+	Score           float64         `json:"score"`
+	ScoreComponents ScoreComponents `json:"scoreComponents"`
+}
+
+// NewRankingConfig builds the re-ranking config from validated settings.
+func NewRankingConfig(settings RankingSettings) *RankingConfig {
+	config := &RankingConfig{
+		DomainWeights:   make(map[string]float64),
+		RecencyWeight:   settings.RecencyWeight,
+		RecencyHalfLife: time.Duration(settings.RecencyHalfLifeDays * float64(24*time.Hour)),
+	}
+	for domain, w := range settings.DomainWeights {
+		config.DomainWeights[normalizeDomain(domain)] = w
+	}
+	return config
+}
+
+// enabled reports whether re-ranking changes anything, i.e. whether
+// results should be re-sorted rather than kept in SearXNG's order.
+func (c *RankingConfig) enabled() bool {
+	return c != nil && (len(c.DomainWeights) > 0 || c.RecencyWeight > 0)
+}
+
+// domainWeight returns the weight for host, matching the most specific
+// configured domain (so "pkg.go.dev" beats "go.dev").
+func (c *RankingConfig) domainWeight(host string) float64 {
+	host = normalizeDomain(host)
+	for {
+		if w, ok := c.DomainWeights[host]; ok {
+			return w
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found || !strings.Contains(parent, ".") {
+			return 1
+		}
+		host = parent
+	}
+}
+
+// recencyBoost returns RecencyWeight scaled by an exponential decay on
+// the result's age, or 0 if it has no usable date.
+func (c *RankingConfig) recencyBoost(published time.Time, now time.Time) float64 {
+	if c.RecencyWeight == 0 || published.IsZero() {
+		return 0
+	}
+	age := max(now.Sub(published), 0)
+	return c.RecencyWeight * math.Pow(0.5, float64(age)/float64(c.RecencyHalfLife))
+}
+
+// rankResults scores results and, if re-ranking is configured, sorts
+// them by final score. Ties keep SearXNG's order.
+func rankResults(results []SearXNGResult, config *RankingConfig) []RankedResult {
+	now := time.Now()
+	ranked := make([]RankedResult, len(results))
+
+	for i, r := range results {
+		components := ScoreComponents{Base: r.Score, Domain: 1}
+		if components.Base <= 0 {
+			components.Base = 1 / float64(i+1)
+		}
+		if config.enabled() {
+			if u, err := url.Parse(r.URL); err == nil {
+				components.Domain = config.domainWeight(u.Hostname())
+			}
+			components.Recency = config.recencyBoost(parsePublishedDate(r.PublishedDate), now)
+		}
+
+		ranked[i] = RankedResult{
+			Title:           r.Title,
+			URL:             r.URL,
+			Content:         r.Content,
+			Engines:         r.Engines,
+			PublishedDate:   r.PublishedDate,
+			Score:           components.Base * components.Domain * (1 + components.Recency),
+			ScoreComponents: components,
+		}
+	}
+
+	if config.enabled() {
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return ranked[i].Score > ranked[j].Score
+		})
+	}
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+
+	return ranked
+}
+
+// parsePublishedDate parses the publishedDate SearXNG reports, which
+// depending on the engine is a full timestamp or just a date.
+func parsePublishedDate(s string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDomainWeight(t *testing.T) {
+	config := NewRankingConfig(RankingSettings{DomainWeights: map[string]float64{
+		"go.dev":      2,
+		"pkg.go.dev":  3,
+		"WWW.Foo.com": 0.5,
+	}})
+	tests := map[string]float64{
+		"go.dev":          2,
+		"tip.go.dev":      2,
+		"pkg.go.dev":      3,
+		"sub.pkg.go.dev":  3,
+		"foo.com":         0.5,
+		"www.foo.com":     0.5,
+		"example.com":     1,
+		"notgo.dev":       1,
+		"dev":             1,
+		"a.b.example.org": 1,
+	}
+	for host, want := range tests {
+		if got := config.domainWeight(host); got != want {
+			t.Errorf("domainWeight(%q) = %g, want %g", host, got, want)
+		}
+	}
+}
+
+func TestRecencyBoost(t *testing.T) {
+	config := NewRankingConfig(RankingSettings{RecencyWeight: 2, RecencyHalfLifeDays: 10})
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		published time.Time
+		want      float64
+	}{
+		{now, 2},
+		{now.AddDate(0, 0, -10), 1},
+		{now.AddDate(0, 0, -20), 0.5},
+		{now.Add(time.Hour), 2}, // future dates count as new
+		{time.Time{}, 0},
+	}
+	for _, tt := range tests {
+		if got := config.recencyBoost(tt.published, now); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("recencyBoost(%v) = %g, want %g", tt.published, got, tt.want)
+		}
+	}
+	if got := NewRankingConfig(RankingSettings{}).recencyBoost(now, now); got != 0 {
+		t.Errorf("recencyBoost with weight 0 = %g", got)
+	}
+}
+
+func TestRankResultsScores(t *testing.T) {
+	now := time.Now().UTC()
+	results := []SearXNGResult{
+		{Title: "hidden", URL: "https://spam.example/a", Score: 9},
+		{Title: "plain", URL: "https://example.com/a", Score: 2},
+		{Title: "boosted", URL: "https://docs.go.dev/a", Score: 1},
+		{Title: "fresh", URL: "https://news.example.com/a", Score: 1, PublishedDate: now.Format(time.RFC3339)},
+		{Title: "also hidden", URL: "https://spam.example/b", Score: 8},
+	}
+	config := NewRankingConfig(RankingSettings{
+		DomainWeights:       map[string]float64{"go.dev": 3, "spam.example": 0},
+		RecencyWeight:       1,
+		RecencyHalfLifeDays: 7,
+	})
+	ranked := rankResults(results, config)
+
+	want := []string{"boosted", "plain", "fresh", "hidden", "also hidden"}
+	for i, r := range ranked {
+		if r.Title != want[i] || r.Rank != i+1 {
+			t.Errorf("rank %d = %s (rank field %d), want %s", i+1, r.Title, r.Rank, want[i])
+		}
+		c := r.ScoreComponents
+		if math.Abs(r.Score-c.Base*c.Domain*(1+c.Recency)) > 1e-9 {
+			t.Errorf("%s: score %g doesn't match its components %+v", r.Title, r.Score, c)
+		}
+	}
+	// A zero weight ranks results last without removing them
+	if len(ranked) != len(results) || ranked[3].Score != 0 {
+		t.Errorf("zero-weight results: %+v", ranked[3:])
+	}
+	if fresh := ranked[2].ScoreComponents.Recency; math.Abs(fresh-1) > 0.01 {
+		t.Errorf("fresh result's recency = %g, want about 1", fresh)
+	}
+}
+
+func TestRankResultsWithoutRanking(t *testing.T) {
+	results := []SearXNGResult{
+		{Title: "a", URL: "https://a.example", Score: 0},
+		{Title: "b", URL: "https://b.example", Score: 5},
+		{Title: "c", URL: "https://c.example"},
+	}
+	ranked := rankResults(results, NewRankingConfig(RankingSettings{}))
+	for i, r := range ranked {
+		if r.Title != results[i].Title {
+			t.Fatalf("order changed without ranking: %v", ranked)
+		}
+	}
+	// Results without a SearXNG score fall back to 1/position
+	if ranked[0].Score != 1 || ranked[1].Score != 5 || math.Abs(ranked[2].Score-1.0/3) > 1e-9 {
+		t.Errorf("scores = %g, %g, %g", ranked[0].Score, ranked[1].Score, ranked[2].Score)
+	}
+}
+
+func TestParsePublishedDate(t *testing.T) {
+	want := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+	for _, s := range []string{"2024-03-05T14:30:00Z", "2024-03-05T14:30:00", "2024-03-05 14:30:00"} {
+		if got := parsePublishedDate(s); !got.Equal(want) {
+			t.Errorf("parsePublishedDate(%q) = %v", s, got)
+		}
+	}
+	if got := parsePublishedDate("2024-03-05"); !got.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date only = %v", got)
+	}
+	for _, s := range []string{"", "yesterday", "05/03/2024"} {
+		if got := parsePublishedDate(s); !got.IsZero() {
+			t.Errorf("parsePublishedDate(%q) = %v, want zero", s, got)
+		}
+	}
+}
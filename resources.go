@@ -3,23 +3,80 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"os"
+	"sort"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-func createConfigResource() string {
+func createConfigResource(cfg *Config) string {
+	password := ""
+	if cfg.SearXNG.Password != "" {
+		password = "(set)"
+	}
+	bearerToken := ""
+	if cfg.SearXNG.BearerToken != "" {
+		bearerToken = "(set)"
+	}
+	headers := make([]string, 0, len(cfg.SearXNG.Headers))
+	for name := range cfg.SearXNG.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
 	config := map[string]interface{}{
 		"version":     VERSION,
-		"searxng_url": os.Getenv("SEARXNG_URL"),
+		"config_file": cfg.File,
+		"searxng": map[string]interface{}{
+			"url":            cfg.SearXNG.URL,
+			"username":       cfg.SearXNG.Username,
+			"password":       password,
+			"bearer_token":   bearerToken,
+			"headers":        headers,
+			"tls_cert_file":  cfg.SearXNG.TLSCertFile,
+			"ca_file":        cfg.SearXNG.CAFile,
+			"config_refresh": cfg.SearXNG.ConfigRefresh,
+		},
 		"proxy": map[string]string{
-			"http":  os.Getenv("HTTP_PROXY"),
-			"https": os.Getenv("HTTPS_PROXY"),
+			"http":  cfg.Proxy.HTTP,
+			"https": cfg.Proxy.HTTPS,
 		},
 		"cache": map[string]interface{}{
 			"enabled":     true,
-			"ttl":         cacheTTLSeconds(),
-			"max_entries": cacheMaxEntries(),
+			"ttl":         cfg.Cache.TTL,
+			"max_entries": cfg.Cache.MaxEntries,
+		},
+		"url_reader": map[string]interface{}{
+			"timeout":   cfg.URLReader.Timeout,
+			"max_bytes": cfg.URLReader.MaxBytes,
+		},
+		"ranking": map[string]interface{}{
+			"domain_weights":         cfg.Ranking.DomainWeights,
+			"recency_weight":         cfg.Ranking.RecencyWeight,
+			"recency_half_life_days": cfg.Ranking.RecencyHalfLifeDays,
+		},
+		"server": map[string]string{
+			"transport": cfg.Server.Transport,
+			"http_addr": cfg.Server.HTTPAddr,
+		},
+		"health": map[string]int{
+			"interval": cfg.Health.Interval,
+		},
+		"auth": map[string]interface{}{
+			"enabled":     cfg.Auth.enabled(),
+			"tokens_file": cfg.Auth.TokensFile,
+			"hmac":        cfg.Auth.HMACSecret != "",
+			"jwks_file":   cfg.Auth.JWKSFile,
+			"issuer":      cfg.Auth.Issuer,
+			"audience":    cfg.Auth.Audience,
+		},
+		"audit": map[string]interface{}{
+			"file":            cfg.Audit.File,
+			"max_size_mb":     cfg.Audit.MaxSizeMB,
+			"max_backups":     cfg.Audit.MaxBackups,
+			"redact_patterns": len(cfg.Audit.Redact),
+		},
+		"quotas": map[string]interface{}{
+			"default": cfg.Quotas.QuotaLimits,
+			"clients": cfg.Quotas.Clients,
 		},
 	}
 
@@ -43,9 +100,14 @@ Performs web searches using the SearXNG metasearch engine.
 **Parameters:**
 - ` + "`query`" + ` (required): Search query string
 - ` + "`pageno`" + ` (optional): Page number (default: 1)
-- ` + "`time_range`" + ` (optional): Filter by time ("day", "month", "year")
+- ` + "`time_range`" + ` (optional): Filter by time ("day", "week", "month", "year")
 - ` + "`language`" + ` (optional): Language code (e.g., "en", "fr", "de")
 - ` + "`safesearch`" + ` (optional): Safe search level ("0", "1", "2")
+- ` + "`max_tokens`" + ` (optional): Approximate token budget; extra results are dropped and the offset to continue from is reported
+- ` + "`offset`" + ` (optional): Skip this many ranked results, to continue output trimmed by max_tokens
+- ` + "`max_results`" + ` (optional): Fetch several pages concurrently to return up to this many results (max: 100)
+- ` + "`pages`" + ` (optional): Number of consecutive pages to fetch and merge (max: 10); if a later page fails, the earlier pages' results come with a note and ` + "`partial: true`" + `
+- ` + "`categories`" + ` / ` + "`engines`" + ` (optional): Restrict to SearXNG categories or engines; see the searxng://instance resource for what this instance supports
 
 **Example:**
 ` + "```" + `
@@ -54,7 +116,66 @@ pageno: 1
 language: "en"
 ` + "```" + `
 
-### 2. url_read
+### 2. multi_search
+
+Runs several phrasings of a question at once and fuses the rankings (reciprocal rank fusion).
+
+**Parameters:**
+- ` + "`queries`" + ` (required): 1-8 search queries
+- ` + "`time_range`" + `, ` + "`language`" + `, ` + "`safesearch`" + ` (optional): As for web_search
+- ` + "`max_results`" + ` (optional): Maximum fused results (default: 20, max: 50)
+- ` + "`max_tokens`" + ` (optional): Approximate token budget
+
+### 3. news_search
+
+Searches news articles, newest first, with source and publication time.
+
+**Parameters:**
+- ` + "`query`" + ` (required): Search query string
+- ` + "`time_range`" + ` (optional): "day", "week", "month", or "year"; undated articles are kept and listed last
+- ` + "`from`" + ` / ` + "`to`" + ` (optional): Inclusive date window (YYYY-MM-DD), checked against each article's publication date; undated articles are dropped
+- ` + "`pages`" + ` (optional): Pages to fetch before filtering (default: 2, max: 5)
+- ` + "`max_tokens`" + ` (optional): Approximate token budget
+
+### 4. scholar_search
+
+Searches academic papers with DOI, authors, journal, date and PDF links.
+
+**Parameters:**
+- ` + "`query`" + ` (required): Search query string
+- ` + "`engines`" + ` (optional): Restrict to science engines, e.g. ["arxiv", "pubmed"]
+- ` + "`max_results`" + ` (optional): Maximum papers (default: 10, max: 30)
+- ` + "`citations`" + ` (optional): "bibtex" or "csl-json"
+
+### 5. code_search
+
+Searches repositories, Q&A sites and package registries only, with stars, language, answered status and versions where available.
+
+**Parameters:**
+- ` + "`query`" + ` (required): Search query string
+- ` + "`source`" + ` (optional): "all" (default), "repos", "qa", or "packages"
+- ` + "`engines`" + ` (optional): Restrict to engines, e.g. ["github", "stackoverflow"]
+- ` + "`max_results`" + ` (optional): Maximum results (default: 10, max: 30)
+
+### 6. image_search / video_search
+
+Search images or videos; results include media, thumbnail and page URLs with resolution, format or duration.
+
+**Parameters:**
+- ` + "`query`" + ` (required): Search query string
+- ` + "`max_results`" + ` (optional): Maximum results (default: 10, max: 30)
+- ` + "`include_thumbnails`" + ` (optional): Return up to 6 thumbnails as inline images (boolean)
+
+### 7. suggest
+
+Returns query completions from SearXNG's autocompleter.
+
+**Parameters:**
+- ` + "`query`" + ` (required): Partial query to complete
+- ` + "`language`" + ` (optional): Language code
+- ` + "`backend`" + ` (optional): Autocomplete backend, e.g. "duckduckgo"
+
+### 8. url_read
 
 Reads and converts web page content to Markdown format.
 
@@ -62,9 +183,13 @@ Reads and converts web page content to Markdown format.
 - ` + "`url`" + ` (required): URL to read
 - ` + "`startChar`" + ` (optional): Starting character position
 - ` + "`maxLength`" + ` (optional): Maximum characters to return
-- ` + "`section`" + ` (optional): Extract specific heading section
+- ` + "`section`" + ` (optional): Section ID (e.g. "2.1"), exact heading path (e.g. "Usage > Configuration") or heading title; ambiguous or unknown selectors are errors listing section IDs
 - ` + "`paragraphRange`" + ` (optional): Paragraph range (e.g., "1-5", "10-")
-- ` + "`readHeadings`" + ` (optional): Return only headings (boolean)
+- ` + "`readHeadings`" + ` (optional): Return a table of contents with section IDs, offsets and sizes (boolean)
+- ` + "`maxTokens`" + ` (optional): Approximate token budget; output is trimmed at a paragraph boundary and the next ` + "`startChar`" + ` is reported
+- ` + "`find`" + ` (optional): Return only passages matching a phrase, with offsets (` + "`findRegex`" + ` for a regex, ` + "`findContext`" + ` for context size)
+- ` + "`query`" + ` (optional): Return the top passages relevant to a question, ranked locally with BM25 (` + "`topK`" + ` sets how many, default 5)
+- ` + "`cursor`" + ` (optional): Continuation cursor from a previous paginated read; returns the next chunk (` + "`url`" + ` may be omitted)
 
 **Example:**
 ` + "```" + `
@@ -74,15 +199,37 @@ maxLength: 5000
 
 ## Configuration
 
-The server requires the following environment variables:
+Settings can come from a YAML config file (` + "`-config`" + ` flag or ` + "`MCP_SEARXNG_CONFIG`" + `), environment variables and command-line flags, each overriding the one before. Invalid settings stop the server at startup with a message naming the setting. The configuration is reloaded when the config file changes or on SIGHUP; invalid reloads are rejected and the running configuration kept. The environment variables are:
 
 - ` + "`SEARXNG_URL`" + `: SearXNG instance URL (required)
 - ` + "`AUTH_USERNAME`" + `: Basic auth username (optional)
-- ` + "`AUTH_PASSWORD`" + `: Basic auth password (optional)
+- ` + "`AUTH_PASSWORD`" + `: Basic auth password (optional; ` + "`AUTH_PASSWORD_FILE`" + ` reads it from a file)
+- ` + "`SEARXNG_BEARER_TOKEN`" + `: Bearer token for SearXNG or its gateway (optional; ` + "`SEARXNG_BEARER_TOKEN_FILE`" + ` reads it from a file)
+- ` + "`SEARXNG_HEADERS`" + ` / ` + "`SEARXNG_HEADER_FILES`" + `: Extra request headers, e.g. "X-Api-Key=abc", or header values read from files (optional)
+- ` + "`SEARXNG_TLS_CERT_FILE`" + ` / ` + "`SEARXNG_TLS_KEY_FILE`" + `: Client certificate for mutual TLS (optional)
+- ` + "`SEARXNG_CA_FILE`" + `: PEM bundle of CAs to trust for SearXNG (optional)
 - ` + "`HTTP_PROXY`" + `: HTTP proxy URL (optional)
 - ` + "`HTTPS_PROXY`" + `: HTTPS proxy URL (optional)
-- ` + "`CACHE_TTL`" + `: URL-read cache time-to-live in seconds (optional, default: 60)
+- ` + "`CACHE_TTL`" + `: URL-read and autocomplete cache time-to-live in seconds (optional, default: 60)
 - ` + "`CACHE_MAX_ENTRIES`" + `: Max cached URLs kept in memory (optional, default: 500)
+- ` + "`RANK_DOMAIN_WEIGHTS`" + `: web_search domain weights, e.g. "go.dev=2,pinterest.com=0.1" (optional)
+- ` + "`RANK_RECENCY_WEIGHT`" + `: Boost for recently published results, 0 disables (optional, default: 0)
+- ` + "`RANK_RECENCY_HALF_LIFE_DAYS`" + `: Age at which the recency boost halves (optional, default: 30)
+- ` + "`HEALTH_CHECK_INTERVAL`" + `: Seconds between SearXNG health checks, 0 only checks at startup (optional, default: 60)
+- ` + "`MCP_TRANSPORT`" + `: "stdio" or "http" (optional, default: stdio)
+- ` + "`MCP_HTTP_ADDR`" + `: Listen address in HTTP mode (optional, default: :8000)
+- ` + "`SEARXNG_CONFIG_REFRESH`" + `: Seconds between /config refreshes, 0 only fetches at startup (optional, default: 600)
+- ` + "`URL_READ_TIMEOUT`" + `: url_read request timeout in seconds (optional, default: 30)
+- ` + "`URL_READ_MAX_BYTES`" + `: Largest page url_read will fetch (optional, default: 10485760)
+- ` + "`MCP_AUTH_TOKENS_FILE`" + `: YAML file of static client tokens and scopes, HTTP mode (optional)
+- ` + "`MCP_AUTH_HMAC_SECRET`" + `: Secret for HMAC-signed client tokens (optional)
+- ` + "`MCP_AUTH_JWKS_FILE`" + `: JWKS file for verifying OAuth 2.0 access tokens (optional)
+- ` + "`MCP_AUTH_ISSUER`" + ` / ` + "`MCP_AUTH_AUDIENCE`" + `: Required iss and aud claims of JWT tokens (optional)
+- ` + "`QUOTA_CALLS_PER_MINUTE`" + `, ` + "`QUOTA_CALLS_PER_DAY`" + `, ` + "`QUOTA_UPSTREAM_PER_MINUTE`" + `, ` + "`QUOTA_UPSTREAM_PER_DAY`" + `, ` + "`QUOTA_BYTES_PER_DAY`" + `: Per-client quotas, 0 is unlimited (optional, default: 0)
+
+## Errors
+
+Failed calls end with a line like ` + "`[error code: rate_limited, retryable after 30s]`" + ` and a hint; tools with structured output also return an ` + "`error`" + ` object with ` + "`code`" + `, ` + "`message`" + ` and ` + "`retryable`" + `. A ` + "`permission_denied`" + ` error means the client's token isn't scoped for that tool or resource. After ` + "`quota_exceeded`" + `, wait ` + "`retryAfterSeconds`" + `; your usage is in the ` + "`usage://mcp-searxng`" + ` resource. Retry ` + "`timeout`" + `, ` + "`rate_limited`" + `, ` + "`connection_failed`" + ` and ` + "`upstream_5xx`" + ` errors; for ` + "`blocked_by_policy`" + `, ` + "`unsupported_type`" + `, ` + "`content_too_large`" + ` or ` + "`upstream_4xx`" + `, move on to another source.
 
 ## Features
 
@@ -97,17 +244,19 @@ For more information, visit: https://github.com/Tomlord1122/mcp-searxng-claude-g
 `
 }
 
-func createConfigResourceHandler(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
-	content := createConfigResource()
-	return &mcp.ReadResourceResult{
-		Contents: []*mcp.ResourceContents{
-			{
-				URI:      "config://mcp-searxng",
-				MIMEType: "application/json",
-				Text:     content,
+func createConfigResourceHandler(reloader *Reloader) mcp.ResourceHandler {
+	return func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		content := createConfigResource(reloader.Current())
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{
+				{
+					URI:      "config://mcp-searxng",
+					MIMEType: "application/json",
+					Text:     content,
+				},
 			},
-		},
-	}, nil
+		}, nil
+	}
 }
 
 func createHelpResourceHandler(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
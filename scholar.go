@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultScholarResults = 10
+	maxScholarResults     = 30
+	// maxListedAuthors caps the authors shown in the Markdown output; the
+	// structured output and citations always carry the full list.
+	maxListedAuthors = 8
+)
+
+// ScholarSearchArgs defines the parameters for academic search
+type ScholarSearchArgs struct {
+	Query      string   `json:"query" jsonschema:"the search query"`
+	PageNo     int      `json:"pageno,omitempty" jsonschema:"search page number (starts at 1)"`
+	TimeRange  string   `json:"time_range,omitempty" jsonschema:"time range of search (day, week, month, or year)"`
+	Language   string   `json:"language,omitempty" jsonschema:"language code for search results (e.g., 'en', 'fr', 'de')"`
+	Engines    []string `json:"engines,omitempty" jsonschema:"restrict to these science engines, e.g. arxiv, pubmed, semantic scholar, crossref"`
+	MaxResults int      `json:"max_results,omitempty" jsonschema:"maximum number of papers to return (default: 10, max: 30)"`
+	Citations  string   `json:"citations,omitempty" jsonschema:"also emit citations for the results: bibtex or csl-json"`
+}
+
+// Paper is a scholarly search result with its citation metadata.
+type Paper struct {
+	Rank          int      `json:"rank"`
+	Title         string   `json:"title"`
+	URL           string   `json:"url"`
+	Content       string   `json:"content,omitempty" jsonschema:"abstract or snippet"`
+	DOI           string   `json:"doi,omitempty"`
+	Authors       []string `json:"authors,omitempty"`
+	Journal       string   `json:"journal,omitempty"`
+	Publisher     string   `json:"publisher,omitempty"`
+	Volume        string   `json:"volume,omitempty"`
+	Issue         string   `json:"issue,omitempty"`
+	Pages         string   `json:"pages,omitempty"`
+	ISSN          []string `json:"issn,omitempty"`
+	ISBN          []string `json:"isbn,omitempty"`
+	PDFURL        string   `json:"pdfUrl,omitempty"`
+	Type          string   `json:"type,omitempty" jsonschema:"publication type reported by the engine, e.g. journal-article"`
+	PublishedDate string   `json:"publishedDate,omitempty"`
+	Engines       []string `json:"engines,omitempty"`
+	CitationKey   string   `json:"citationKey" jsonschema:"key used for this paper in the BibTeX and CSL-JSON citations"`
+}
+
+// CSLName is an author in CSL-JSON.
+type CSLName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+// CSLDate is a CSL-JSON date: [[year, month, day]], with trailing parts optional.
+type CSLDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// CSLItem is a citation in CSL-JSON, the format used by Zotero, Pandoc
+// and citeproc.
+type CSLItem struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	Title          string    `json:"title"`
+	Author         []CSLName `json:"author,omitempty"`
+	ContainerTitle string    `json:"container-title,omitempty"`
+	Publisher      string    `json:"publisher,omitempty"`
+	Volume         string    `json:"volume,omitempty"`
+	Issue          string    `json:"issue,omitempty"`
+	Page           string    `json:"page,omitempty"`
+	DOI            string    `json:"DOI,omitempty"`
+	ISSN           string    `json:"ISSN,omitempty"`
+	ISBN           string    `json:"ISBN,omitempty"`
+	URL            string    `json:"URL,omitempty"`
+	Issued         *CSLDate  `json:"issued,omitempty"`
+}
+
+// ScholarSearchOutput is scholar_search's structured output.
+type ScholarSearchOutput struct {
+	Query   string     `json:"query"`
+	Results []Paper    `json:"results,omitempty"`
+	BibTeX  string     `json:"bibtex,omitempty"`
+	CSLJSON []CSLItem  `json:"cslJson,omitempty"`
+	Error   *ToolError `json:"error,omitempty"`
+}
+
+// normalizeDOI strips resolver prefixes so DOIs compare and link cleanly.
+func normalizeDOI(doi string) string {
+	doi = strings.TrimSpace(doi)
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"} {
+		if len(doi) >= len(prefix) && strings.EqualFold(doi[:len(prefix)], prefix) {
+			doi = doi[len(prefix):]
+		}
+	}
+	return doi
+}
+
+// mergePapers merges results that share a DOI. The same paper often comes
+// back from several engines under different URLs (arXiv, Crossref, the
+// publisher), each with part of the metadata, so the first occurrence
+// keeps its position and fills its gaps from the others.
+func mergePapers(results []SearXNGResult) []SearXNGResult {
+	index := make(map[string]int)
+	merged := make([]SearXNGResult, 0, len(results))
+
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	for _, r := range results {
+		r.DOI = normalizeDOI(r.DOI)
+		key := strings.ToLower(r.DOI)
+		i, seen := index[key]
+		if key == "" || !seen {
+			if key != "" {
+				index[key] = len(merged)
+			}
+			merged = append(merged, r)
+			continue
+		}
+
+		kept := &merged[i]
+		if len(r.Content) > len(kept.Content) {
+			kept.Content = r.Content
+		}
+		if len(r.Authors) > len(kept.Authors) {
+			kept.Authors = r.Authors
+		}
+		fill(&kept.Journal, r.Journal)
+		fill(&kept.Publisher, r.Publisher)
+		fill(&kept.PDFURL, r.PDFURL)
+		fill(&kept.Type, r.Type)
+		fill(&kept.PublishedDate, r.PublishedDate)
+		fill((*string)(&kept.Volume), string(r.Volume))
+		fill((*string)(&kept.Pages), string(r.Pages))
+		fill((*string)(&kept.Number), string(r.Number))
+		if len(kept.ISSN) == 0 {
+			kept.ISSN = r.ISSN
+		}
+		if len(kept.ISBN) == 0 {
+			kept.ISBN = r.ISBN
+		}
+		for _, engine := range r.Engines {
+			if !containsFold(kept.Engines, engine) {
+				kept.Engines = append(kept.Engines, engine)
+			}
+		}
+	}
+
+	return merged
+}
+
+func toPaper(rank int, r SearXNGResult) Paper {
+	p := Paper{
+		Rank:      rank,
+		Title:     strings.TrimSpace(r.Title),
+		URL:       r.URL,
+		Content:   r.Content,
+		DOI:       r.DOI,
+		Authors:   r.Authors,
+		Journal:   r.Journal,
+		Publisher: r.Publisher,
+		Volume:    string(r.Volume),
+		Issue:     string(r.Number),
+		Pages:     string(r.Pages),
+		ISSN:      r.ISSN,
+		ISBN:      r.ISBN,
+		PDFURL:    r.PDFURL,
+		Type:      r.Type,
+		Engines:   r.Engines,
+	}
+	if published := parsePublishedDate(r.PublishedDate); !published.IsZero() {
+		p.PublishedDate = published.Format("2006-01-02")
+	}
+	return p
+}
+
+// splitAuthorName splits "Given Family" or "Family, Given" into its parts.
+// Single-word names (often consortia) are returned as the family name.
+func splitAuthorName(name string) (family, given string) {
+	name = strings.TrimSpace(name)
+	if f, g, ok := strings.Cut(name, ","); ok {
+		return strings.TrimSpace(f), strings.TrimSpace(g)
+	}
+	if i := strings.LastIndex(name, " "); i > 0 {
+		return name[i+1:], strings.TrimSpace(name[:i])
+	}
+	return name, ""
+}
+
+func paperYear(p Paper) int {
+	if t, err := time.Parse("2006-01-02", p.PublishedDate); err == nil {
+		return t.Year()
+	}
+	return 0
+}
+
+// citationKeys assigns BibTeX-style keys (family name + year + first title
+// word, e.g. "vaswani2017attention"), suffixing a, b, ..., z, aa, ab, ...
+// on collisions.
+func citationKeys(papers []Paper) {
+	alnum := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, s)
+	}
+
+	taken := make(map[string]bool)
+	suffixes := make(map[string]int) // next suffix to try per base key
+	for i := range papers {
+		p := &papers[i]
+		key := "anon"
+		if len(p.Authors) > 0 {
+			family, _ := splitAuthorName(p.Authors[0])
+			if k := alnum(family); k != "" {
+				key = k
+			}
+		}
+		if year := paperYear(*p); year > 0 {
+			key += fmt.Sprint(year)
+		}
+		for _, word := range strings.Fields(p.Title) {
+			if w := alnum(word); len(w) > 3 && !stopwords[w] {
+				key += w
+				break
+			}
+		}
+
+		// Suffix until the key is unique; a suffixed key may itself be
+		// another paper's unsuffixed one.
+		unique := key
+		for taken[unique] {
+			unique = key + alphaSuffix(suffixes[key])
+			suffixes[key]++
+		}
+		taken[unique] = true
+		p.CitationKey = unique
+	}
+}
+
+// alphaSuffix returns the n'th (from 0) of a, b, ..., z, aa, ab, ...
+func alphaSuffix(n int) string {
+	suffix := ""
+	for n++; n > 0; n = (n - 1) / 26 {
+		suffix = string(rune('a'+(n-1)%26)) + suffix
+	}
+	return suffix
+}
+
+// bibtexEscape escapes LaTeX special characters in a field value.
+var bibtexEscape = strings.NewReplacer(
+	`\`, `\textbackslash{}`, "{", `\{`, "}", `\}`,
+	"&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`, "_", `\_`,
+)
+
+// renderBibTeX formats papers as BibTeX entries: @article when there's a
+// journal, @book for books, @inproceedings for conference papers, and
+// @misc otherwise (preprints, datasets).
+func renderBibTeX(papers []Paper) string {
+	var b strings.Builder
+	for _, p := range papers {
+		entryType := "misc"
+		switch {
+		case strings.Contains(p.Type, "proceedings") || strings.Contains(p.Type, "conference"):
+			entryType = "inproceedings"
+		case strings.Contains(p.Type, "book") && !strings.Contains(p.Type, "chapter"):
+			entryType = "book"
+		case p.Journal != "":
+			entryType = "article"
+		}
+
+		var authors []string
+		for _, a := range p.Authors {
+			if family, given := splitAuthorName(a); given != "" {
+				authors = append(authors, family+", "+given)
+			} else {
+				authors = append(authors, "{"+family+"}")
+			}
+		}
+
+		container := "journal"
+		if entryType == "inproceedings" {
+			container = "booktitle"
+		}
+		fields := []struct{ name, value string }{
+			{"title", "{" + bibtexEscape.Replace(p.Title) + "}"},
+			{"author", bibtexEscape.Replace(strings.Join(authors, " and "))},
+			{container, bibtexEscape.Replace(p.Journal)},
+			{"publisher", bibtexEscape.Replace(p.Publisher)},
+			{"volume", p.Volume},
+			{"number", p.Issue},
+			{"pages", strings.Replace(p.Pages, "-", "--", 1)},
+			{"doi", p.DOI},
+			{"url", p.URL},
+		}
+		if year := paperYear(p); year > 0 {
+			fields = append(fields, struct{ name, value string }{"year", fmt.Sprint(year)})
+		}
+		if len(p.ISSN) > 0 {
+			fields = append(fields, struct{ name, value string }{"issn", p.ISSN[0]})
+		}
+
+		fmt.Fprintf(&b, "@%s{%s,\n", entryType, p.CitationKey)
+		for _, f := range fields {
+			if f.value != "" && f.value != "{}" {
+				fmt.Fprintf(&b, "  %s = {%s},\n", f.name, f.value)
+			}
+		}
+		b.WriteString("}\n\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// toCSL converts papers to CSL-JSON items.
+func toCSL(papers []Paper) []CSLItem {
+	items := make([]CSLItem, 0, len(papers))
+	for _, p := range papers {
+		item := CSLItem{
+			ID:             p.CitationKey,
+			Type:           "article",
+			Title:          p.Title,
+			ContainerTitle: p.Journal,
+			Publisher:      p.Publisher,
+			Volume:         p.Volume,
+			Issue:          p.Issue,
+			Page:           p.Pages,
+			DOI:            p.DOI,
+			URL:            p.URL,
+		}
+		switch {
+		case strings.Contains(p.Type, "proceedings") || strings.Contains(p.Type, "conference"):
+			item.Type = "paper-conference"
+		case strings.Contains(p.Type, "chapter"):
+			item.Type = "chapter"
+		case strings.Contains(p.Type, "book"):
+			item.Type = "book"
+		case p.Journal != "":
+			item.Type = "article-journal"
+		}
+		if len(p.ISSN) > 0 {
+			item.ISSN = p.ISSN[0]
+		}
+		if len(p.ISBN) > 0 {
+			item.ISBN = p.ISBN[0]
+		}
+		for _, a := range p.Authors {
+			if family, given := splitAuthorName(a); given != "" {
+				item.Author = append(item.Author, CSLName{Family: family, Given: given})
+			} else {
+				item.Author = append(item.Author, CSLName{Literal: family})
+			}
+		}
+		if t, err := time.Parse("2006-01-02", p.PublishedDate); err == nil {
+			item.Issued = &CSLDate{DateParts: [][]int{{t.Year(), int(t.Month()), t.Day()}}}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func handleScholarSearch(ctx context.Context, req *mcp.CallToolRequest, client *SearXNGClient, args ScholarSearchArgs) (*mcp.CallToolResult, *ScholarSearchOutput, error) {
+	// Validate parameters
+	if args.Query == "" {
+		terr := invalidArgument("query parameter is required")
+		return toolErrorResult("", terr), &ScholarSearchOutput{Query: args.Query, Error: terr}, nil
+	}
+	args.Citations = strings.ToLower(strings.TrimSpace(args.Citations))
+	if args.Citations != "" && args.Citations != "bibtex" && args.Citations != "csl-json" {
+		terr := invalidArgument("invalid citations %q: use bibtex or csl-json", args.Citations)
+		return toolErrorResult("", terr), &ScholarSearchOutput{Query: args.Query, Error: terr}, nil
+	}
+
+	// Set defaults
+	if args.PageNo == 0 {
+		args.PageNo = 1
+	}
+	if args.Language == "" {
+		args.Language = "all"
+	}
+	maxResults := args.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultScholarResults
+	}
+	maxResults = min(maxResults, maxScholarResults)
+
+	// Perform search
+	startTime := time.Now()
+	resp, err := client.Search(ctx, SearchRequest{
+		Query:      args.Query,
+		PageNo:     args.PageNo,
+		TimeRange:  args.TimeRange,
+		Language:   args.Language,
+		Categories: []string{"science"},
+		Engines:    args.Engines,
+	})
+	if err != nil {
+		terr := classifyError(err)
+		return toolErrorResult("Scholar search failed", terr), &ScholarSearchOutput{Query: args.Query, Error: terr}, nil
+	}
+	duration := time.Since(startTime)
+
+	raw, _ := dedupeResults(resp.Results)
+	raw = mergePapers(raw)
+	if len(raw) > maxResults {
+		raw = raw[:maxResults]
+	}
+	papers := make([]Paper, len(raw))
+	for i, r := range raw {
+		papers[i] = toPaper(i+1, r)
+	}
+	citationKeys(papers)
+
+	// Format output
+	if len(papers) == 0 {
+		output := fmt.Sprintf("# No Papers Found\n\nNo papers found for query: \"%s\"", args.Query)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: output},
+			},
+		}, &ScholarSearchOutput{Query: args.Query}, nil
+	}
+
+	output := fmt.Sprintf("# Papers for \"%s\"\n\n", args.Query)
+	output += fmt.Sprintf("Found %d papers (page %d) in %dms\n\n", len(papers), args.PageNo, duration.Milliseconds())
+
+	for _, p := range papers {
+		output += fmt.Sprintf("## %d. %s\n\n", p.Rank, p.Title)
+		if len(p.Authors) > 0 {
+			authors := strings.Join(p.Authors[:min(len(p.Authors), maxListedAuthors)], ", ")
+			if len(p.Authors) > maxListedAuthors {
+				authors += fmt.Sprintf(" et al. (%d authors)", len(p.Authors))
+			}
+			output += fmt.Sprintf("**Authors:** %s\n\n", authors)
+		}
+
+		venue := p.Journal
+		if p.Volume != "" {
+			venue += " " + p.Volume
+			if p.Issue != "" {
+				venue += "(" + p.Issue + ")"
+			}
+		}
+		if p.Pages != "" {
+			venue += ", pp. " + p.Pages
+		}
+		var details []string
+		for _, d := range []struct{ label, value string }{
+			{"Published in", strings.TrimPrefix(venue, ", ")},
+			{"Publisher", p.Publisher},
+			{"Date", p.PublishedDate},
+			{"Type", p.Type},
+		} {
+			if strings.TrimSpace(d.value) != "" {
+				details = append(details, fmt.Sprintf("**%s:** %s", d.label, strings.TrimSpace(d.value)))
+			}
+		}
+		if len(details) > 0 {
+			output += strings.Join(details, " · ") + "\n\n"
+		}
+
+		if p.DOI != "" {
+			output += fmt.Sprintf("**DOI:** %s (https://doi.org/%s)\n\n", p.DOI, p.DOI)
+		}
+		output += fmt.Sprintf("**URL:** %s\n\n", p.URL)
+		if p.PDFURL != "" {
+			output += fmt.Sprintf("**PDF:** %s\n\n", p.PDFURL)
+		}
+		if p.Content != "" {
+			output += p.Content + "\n\n"
+		}
+		output += fmt.Sprintf("*Cite as `%s`*\n\n", p.CitationKey)
+		output += "---\n\n"
+	}
+
+	result := &ScholarSearchOutput{Query: args.Query, Results: papers}
+	switch args.Citations {
+	case "bibtex":
+		result.BibTeX = renderBibTeX(papers)
+		output += "# BibTeX\n\n```bibtex\n" + result.BibTeX + "```\n"
+	case "csl-json":
+		result.CSLJSON = toCSL(papers)
+		var data strings.Builder
+		enc := json.NewEncoder(&data)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		enc.Encode(result.CSLJSON)
+		output += "# CSL-JSON\n\n```json\n" + data.String() + "```\n"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: output},
+		},
+	}, result, nil
+}
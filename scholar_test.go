@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestCitationKeys(t *testing.T) {
+	paper := func(author, date, title string) Paper {
+		return Paper{Authors: []string{author}, PublishedDate: date, Title: title}
+	}
+	papers := []Paper{
+		paper("Ashish Vaswani", "2017-06-12", "Attention Is All You Need"),
+		paper("Vaswani, Ashish", "2017-12-01", "Attention heads revisited"),
+		// Its key is the suffixed key of the second paper
+		paper("A. Vaswani", "2017-01-01", "Attentiona"),
+		paper("", "", "On the of"),
+	}
+	citationKeys(papers)
+
+	want := []string{"vaswani2017attention", "vaswani2017attentiona", "vaswani2017attentionaa", "anon"}
+	for i, p := range papers {
+		if p.CitationKey != want[i] {
+			t.Errorf("paper %d key = %q, want %q", i, p.CitationKey, want[i])
+		}
+	}
+}
+
+func TestCitationKeysManyDuplicates(t *testing.T) {
+	papers := make([]Paper, 60)
+	for i := range papers {
+		papers[i] = Paper{Authors: []string{"Jane Smith"}, PublishedDate: "2020-01-01", Title: "Deep learning"}
+	}
+	citationKeys(papers)
+
+	seen := make(map[string]bool)
+	for _, p := range papers {
+		if seen[p.CitationKey] {
+			t.Fatalf("duplicate key %q", p.CitationKey)
+		}
+		seen[p.CitationKey] = true
+	}
+	for i, want := range map[int]string{0: "smith2020deep", 1: "smith2020deepa", 26: "smith2020deepz", 27: "smith2020deepaa", 53: "smith2020deepba"} {
+		if papers[i].CitationKey != want {
+			t.Errorf("paper %d key = %q, want %q", i, papers[i].CitationKey, want)
+		}
+	}
+}
+
+func TestAlphaSuffix(t *testing.T) {
+	for n, want := range map[int]string{0: "a", 25: "z", 26: "aa", 51: "az", 52: "ba", 701: "zz", 702: "aaa"} {
+		if got := alphaSuffix(n); got != want {
+			t.Errorf("alphaSuffix(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
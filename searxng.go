@@ -2,132 +2,489 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type SearXNGClient struct {
+	mu    sync.RWMutex
+	conn  *searxngConn
+	cache *Cache
+}
+
+// searxngConn is the endpoint, credentials and HTTP client requests use.
+// A reload replaces it as a whole, so a request never mixes old and new
+// settings.
+type searxngConn struct {
 	baseURL    string
+	settings   SearXNGSettings
 	httpClient *http.Client
 }
 
 type SearXNGResult struct {
-	Title   string  `json:"title"`
-	Content string  `json:"content"`
-	URL     string  `json:"url"`
-	Score   float64 `json:"score"`
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	URL     string   `json:"url"`
+	Score   float64  `json:"score"`
+	Engines []string `json:"engines"`
+
+	PublishedDate string `json:"publishedDate"`
+
+	// Media fields, set by engines in the images and videos categories
+	ImgSrc       string     `json:"img_src"`
+	ThumbnailSrc string     `json:"thumbnail_src"`
+	Thumbnail    string     `json:"thumbnail"`
+	Resolution   string     `json:"resolution"`
+	ImgFormat    string     `json:"img_format"`
+	Length       flexString `json:"length"`
+	Duration     flexString `json:"duration"`
+	IframeSrc    string     `json:"iframe_src"`
+	Author       string     `json:"author"`
+	Source       string     `json:"source"`
+
+	// Paper fields, set by engines in the science category
+	DOI       string      `json:"doi"`
+	Authors   flexStrings `json:"authors"`
+	Journal   string      `json:"journal"`
+	Publisher string      `json:"publisher"`
+	ISSN      flexStrings `json:"issn"`
+	ISBN      flexStrings `json:"isbn"`
+	PDFURL    string      `json:"pdf_url"`
+	Volume    flexString  `json:"volume"`
+	Pages     flexString  `json:"pages"`
+	Number    flexString  `json:"number"`
+	Type      string      `json:"type"`
+
+	// Package and repository fields, set by engines in the it category
+	PackageName   string      `json:"package_name"`
+	Version       flexString  `json:"version"`
+	Maintainer    string      `json:"maintainer"`
+	Popularity    flexString  `json:"popularity"`
+	LicenseName   string      `json:"license_name"`
+	Homepage      string      `json:"homepage"`
+	SourceCodeURL string      `json:"source_code_url"`
+	Tags          flexStrings `json:"tags"`
+}
+
+// flexString decodes a JSON string, number or null into a string. Some
+// SearXNG fields (e.g. a video's length) are strings for one engine and
+// numbers for another.
+type flexString string
+
+func (f *flexString) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*f = flexString(s)
+		return nil
+	}
+	*f = flexString(strings.Trim(string(data), `"`))
+	return nil
+}
+
+// flexStrings decodes a JSON list, a single string or null into a string
+// slice; engines aren't consistent about whether e.g. authors is a list.
+type flexStrings []string
+
+func (f *flexStrings) UnmarshalJSON(data []byte) error {
+	var list []flexString
+	if err := json.Unmarshal(data, &list); err == nil {
+		*f = nil
+		for _, v := range list {
+			if v != "" {
+				*f = append(*f, string(v))
+			}
+		}
+		return nil
+	}
+	var single flexString
+	if err := single.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*f = nil
+	if single != "" {
+		*f = flexStrings{string(single)}
+	}
+	return nil
 }
 
 type SearXNGResponse struct {
 	Results []SearXNGResult `json:"results"`
 }
 
+// WebSearchOutput is web_search's structured output: the results shown,
+// in final order, with each score broken down into its components.
+type WebSearchOutput struct {
+	Query   string         `json:"query"`
+	Results []RankedResult `json:"results,omitempty"`
+	Partial bool           `json:"partial,omitempty" jsonschema:"a page after the first failed, so only the results of the pages before it are included"`
+	Error   *ToolError     `json:"error,omitempty"`
+}
+
 // WebSearchArgs defines the parameters for web search
 type WebSearchArgs struct {
 	Query      string `json:"query" jsonschema:"the search query"`
 	PageNo     int    `json:"pageno,omitempty" jsonschema:"search page number (starts at 1)"`
-	TimeRange  string `json:"time_range,omitempty" jsonschema:"time range of search (day, month, or year)"`
+	TimeRange  string `json:"time_range,omitempty" jsonschema:"time range of search (day, week, month, or year)"`
 	Language   string `json:"language,omitempty" jsonschema:"language code for search results (e.g., 'en', 'fr', 'de')"`
 	SafeSearch string `json:"safesearch,omitempty" jsonschema:"safe search filter level (0: None, 1: Moderate, 2: Strict)"`
+	MaxTokens  int    `json:"max_tokens,omitempty" jsonschema:"approximate token budget for the output; results that don't fit are dropped whole and the offset to continue from is reported"`
+	Offset     int    `json:"offset,omitempty" jsonschema:"skip this many of the deduplicated, ranked results; use the offset reported when max_tokens trims the output"`
+	MaxResults int    `json:"max_results,omitempty" jsonschema:"fetch enough pages (starting at pageno) to return up to this many deduplicated results (max: 100)"`
+	Pages      int    `json:"pages,omitempty" jsonschema:"number of consecutive pages to fetch concurrently and merge, starting at pageno (max: 10)"`
+
+	Categories []string `json:"categories,omitempty" jsonschema:"restrict to these SearXNG categories, e.g. general, it, science"`
+	Engines    []string `json:"engines,omitempty" jsonschema:"restrict to these SearXNG engines, e.g. wikipedia, github"`
+}
+
+const (
+	// resultsPerPage is roughly how many results SearXNG returns per page;
+	// used to turn max_results into a page count.
+	resultsPerPage = 10
+	maxPages       = 10
+	maxResultsCap  = 100
+)
+
+func NewSearXNGClient(settings SearXNGSettings, cache *Cache, proxyConfig *ProxyConfig) (*SearXNGClient, error) {
+	conn, err := newSearXNGConn(settings, proxyConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &SearXNGClient{
+		conn:  conn,
+		cache: cache,
+	}, nil
 }
 
-func NewSearXNGClient(baseURL string, proxyConfig *ProxyConfig) *SearXNGClient {
+func newSearXNGConn(settings SearXNGSettings, proxyConfig *ProxyConfig) (*searxngConn, error) {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
 
+	var transport http.RoundTripper
 	if proxyConfig != nil && proxyConfig.Transport != nil {
-		client.Transport = proxyConfig.Transport
+		transport = proxyConfig.Transport
 	}
 
-	return &SearXNGClient{
-		baseURL:    baseURL,
+	// Present a client certificate and/or trust a private CA. The
+	// transport is cloned, since the proxy's is shared with url_read.
+	if settings.TLSCertFile != "" || settings.CAFile != "" {
+		tlsConfig, err := settings.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		base, ok := transport.(*http.Transport)
+		if !ok {
+			base = http.DefaultTransport.(*http.Transport)
+		}
+		custom := base.Clone()
+		custom.TLSClientConfig = tlsConfig
+		transport = custom
+	}
+	client.Transport = countUsage(transport)
+
+	return &searxngConn{
+		baseURL:    settings.URL,
+		settings:   settings,
 		httpClient: client,
+	}, nil
+}
+
+// tlsConfig loads the client certificate and CA bundle.
+func (s SearXNGSettings) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.TLSCertFile, s.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("SearXNG client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
 	}
+	if s.CAFile != "" {
+		pool, err := loadCABundle(s.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// loadCABundle reads a PEM file of CA certificates.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA bundle %s: no PEM certificates found", path)
+	}
+	return pool, nil
+}
+
+// PrepareReconfigure builds a connection with new settings and returns
+// commit, which switches to it. Nothing changes until commit is called;
+// requests already in flight finish with the old connection.
+func (c *SearXNGClient) PrepareReconfigure(settings SearXNGSettings, proxyConfig *ProxyConfig) (commit func(), err error) {
+	conn, err := newSearXNGConn(settings, proxyConfig)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.conn = conn
+	}, nil
+}
+
+// current returns the connection settings in effect.
+func (c *SearXNGClient) current() *searxngConn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn
+}
+
+// SearchRequest holds the parameters of one SearXNG search.
+type SearchRequest struct {
+	Query      string
+	PageNo     int
+	TimeRange  string
+	Language   string
+	SafeSearch string
+	Categories []string // e.g. "news", "images"; empty means SearXNG's default
+	Engines    []string // restrict to these engines; empty means all enabled
+}
+
+// newRequest builds a GET request to SearXNG with the headers and auth
+// every endpoint needs.
+func (conn *searxngConn) newRequest(ctx context.Context, u *url.URL) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Add required headers to prevent bot detection
+	req.Header.Set("X-Forwarded-For", "127.0.0.1")
+	req.Header.Set("X-Real-IP", "127.0.0.1")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; MCP-SearXNG-Go/1.0)")
+
+	// Add basic auth or a bearer token if configured
+	if conn.settings.Username != "" && conn.settings.Password != "" {
+		req.SetBasicAuth(conn.settings.Username, conn.settings.Password)
+	}
+	if conn.settings.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+conn.settings.BearerToken)
+	}
+
+	// Configured headers come last, so they can replace the ones above
+	for name, value := range conn.settings.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
 }
 
-func (c *SearXNGClient) Search(ctx context.Context, query string, pageno int, timeRange, language, safesearch string) (*SearXNGResponse, error) {
+func (c *SearXNGClient) Search(ctx context.Context, sr SearchRequest) (*SearXNGResponse, error) {
 	// Build URL
-	searchURL, err := url.Parse(c.baseURL + "/search")
+	conn := c.current()
+	searchURL, err := url.Parse(conn.baseURL + "/search")
 	if err != nil {
 		return nil, fmt.Errorf("invalid SearXNG URL: %w", err)
 	}
 
 	params := url.Values{}
-	params.Set("q", query)
+	params.Set("q", sr.Query)
 	params.Set("format", "json")
-	params.Set("pageno", strconv.Itoa(pageno))
+	params.Set("pageno", strconv.Itoa(max(sr.PageNo, 1)))
 
-	if timeRange != "" && (timeRange == "day" || timeRange == "month" || timeRange == "year") {
-		params.Set("time_range", timeRange)
+	if sr.TimeRange == "day" || sr.TimeRange == "week" || sr.TimeRange == "month" || sr.TimeRange == "year" {
+		params.Set("time_range", sr.TimeRange)
 	}
 
-	if language != "" && language != "all" {
-		params.Set("language", language)
+	if sr.Language != "" && sr.Language != "all" {
+		params.Set("language", sr.Language)
 	}
 
-	if safesearch != "" && (safesearch == "0" || safesearch == "1" || safesearch == "2") {
-		params.Set("safesearch", safesearch)
+	if sr.SafeSearch == "0" || sr.SafeSearch == "1" || sr.SafeSearch == "2" {
+		params.Set("safesearch", sr.SafeSearch)
 	}
 
-	searchURL.RawQuery = params.Encode()
+	if len(sr.Categories) > 0 {
+		params.Set("categories", strings.Join(sr.Categories, ","))
+	}
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "GET", searchURL.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	if len(sr.Engines) > 0 {
+		params.Set("engines", strings.Join(sr.Engines, ","))
 	}
 
-	// Add required headers to prevent bot detection
-	req.Header.Set("X-Forwarded-For", "127.0.0.1")
-	req.Header.Set("X-Real-IP", "127.0.0.1")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; MCP-SearXNG-Go/1.0)")
+	searchURL.RawQuery = params.Encode()
 
-	// Add basic auth if configured
-	username := os.Getenv("AUTH_USERNAME")
-	password := os.Getenv("AUTH_PASSWORD")
-	if username != "" && password != "" {
-		req.SetBasicAuth(username, password)
+	// Create request
+	req, err := conn.newRequest(ctx, searchURL)
+	if err != nil {
+		return nil, err
 	}
 
 	// Execute request
-	resp, err := c.httpClient.Do(req)
+	resp, err := conn.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("search request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("SearXNG returned status %d: %s", resp.StatusCode, string(body))
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, httpStatusError(resp, "SearXNG", string(body))
 	}
 
 	// Parse response
 	var result SearXNGResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, newToolError(ErrInvalidResponse, err, "failed to parse SearXNG response (is the json format enabled?): %v", err)
 	}
 
 	return &result, nil
 }
 
-func handleWebSearch(ctx context.Context, req *mcp.CallToolRequest, client *SearXNGClient, args WebSearchArgs) (*mcp.CallToolResult, any, error) {
+// SearchPages fetches count consecutive result pages starting at
+// sr.PageNo concurrently and returns their results concatenated in page
+// order, preserving rank. A page that comes back empty marks the end of
+// the results: later pages are cancelled and discarded. The second return
+// value is the last page that contributed results. An error on the first
+// page is returned without results; an error on a later page ends the
+// results there and is returned with them as a *PageError, so callers
+// can show what they got and say it's incomplete.
+func (c *SearXNGClient) SearchPages(ctx context.Context, sr SearchRequest, count int) ([]SearXNGResult, int, error) {
+	type pageResult struct {
+		results []SearXNGResult
+		err     error
+	}
+
+	firstPage := max(sr.PageNo, 1)
+	pages := make([]pageResult, count)
+	cancels := make([]context.CancelFunc, count)
+	done := make(chan int, count)
+
+	for i := 0; i < count; i++ {
+		pageCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		go func(i int) {
+			pageReq := sr
+			pageReq.PageNo = firstPage + i
+			resp, err := c.Search(pageCtx, pageReq)
+			if err == nil {
+				pages[i].results = resp.Results
+			}
+			pages[i].err = err
+			done <- i
+		}(i)
+	}
+
+	// As soon as a page is known to be empty, stop fetching the ones after it.
+	for n := 0; n < count; n++ {
+		i := <-done
+		if pages[i].err == nil && len(pages[i].results) == 0 {
+			for j := i + 1; j < count; j++ {
+				cancels[j]()
+			}
+		}
+	}
+	for _, cancel := range cancels {
+		cancel()
+	}
+
+	var results []SearXNGResult
+	lastPage := firstPage - 1
+	for i, page := range pages {
+		if page.err != nil {
+			if i == 0 {
+				return nil, 0, page.err
+			}
+			return results, lastPage, &PageError{Page: firstPage + i, Err: page.err}
+		}
+		if len(page.results) == 0 {
+			break
+		}
+		results = append(results, page.results...)
+		lastPage = firstPage + i
+	}
+
+	return results, lastPage, nil
+}
+
+// PageError is a result page that failed after the pages before it
+// succeeded.
+type PageError struct {
+	Page int
+	Err  error
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("page %d failed: %v", e.Page, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// partialResultsNote tells the caller that the results stop before a
+// failed page.
+func partialResultsNote(e *PageError) string {
+	terr := classifyError(e.Err)
+	return fmt.Sprintf("**Partial results:** page %d failed (%s: %s), so only the results of the pages before it are shown. Retry, or continue with pageno=%d.\n\n",
+		e.Page, terr.Code, terr.Message, e.Page)
+}
+
+// pageRange labels the SearXNG pages a web_search call covered.
+func pageRange(first, last int) string {
+	if last > first {
+		return fmt.Sprintf("pages %d-%d", first, last)
+	}
+	return fmt.Sprintf("page %d", first)
+}
+
+// pagesToFetch works out how many pages a web_search call needs from its
+// pages and max_results arguments.
+func pagesToFetch(args WebSearchArgs) int {
+	pages := args.Pages
+	if pages <= 0 && args.MaxResults > 0 {
+		pages = (min(args.MaxResults, maxResultsCap) + resultsPerPage - 1) / resultsPerPage
+	}
+	return min(max(pages, 1), maxPages)
+}
+
+func handleWebSearch(ctx context.Context, req *mcp.CallToolRequest, client *SearXNGClient, ranking *RankingConfig, capabilities *Capabilities, args WebSearchArgs) (*mcp.CallToolResult, *WebSearchOutput, error) {
 	// Validate required parameter
 	if args.Query == "" {
-		return &mcp.CallToolResult{
-			IsError: true,
-			Content: []mcp.Content{
-				&mcp.TextContent{Text: "query parameter is required"},
-			},
-		}, nil, nil
+		terr := invalidArgument("query parameter is required")
+		return toolErrorResult("", terr), &WebSearchOutput{Query: args.Query, Error: terr}, nil
+	}
+
+	// Validate against what the instance supports
+	if err := capabilities.Get().validateSearch(args.Categories, args.Engines, args.Language); err != nil {
+		terr := invalidArgument("%v", err)
+		return toolErrorResult("", terr), &WebSearchOutput{Query: args.Query, Error: terr}, nil
 	}
 
 	// Set defaults
@@ -143,41 +500,118 @@ func handleWebSearch(ctx context.Context, req *mcp.CallToolRequest, client *Sear
 
 	// Perform search
 	startTime := time.Now()
-	results, err := client.Search(ctx, args.Query, args.PageNo, args.TimeRange, args.Language, args.SafeSearch)
+	pages := pagesToFetch(args)
+	results := &SearXNGResponse{}
+	lastPage := args.PageNo
+	var err error
+	sr := SearchRequest{
+		Query:      args.Query,
+		PageNo:     args.PageNo,
+		TimeRange:  args.TimeRange,
+		Language:   args.Language,
+		SafeSearch: args.SafeSearch,
+		Categories: args.Categories,
+		Engines:    args.Engines,
+	}
+	if pages == 1 {
+		results, err = client.Search(ctx, sr)
+	} else {
+		results.Results, lastPage, err = client.SearchPages(ctx, sr, pages)
+	}
+	var pageErr *PageError
+	if errors.As(err, &pageErr) {
+		err = nil
+	}
 	if err != nil {
-		return &mcp.CallToolResult{
-			IsError: true,
-			Content: []mcp.Content{
-				&mcp.TextContent{Text: fmt.Sprintf("Search failed: %v", err)},
-			},
-		}, nil, nil
+		terr := classifyError(err)
+		return toolErrorResult("Search failed", terr), &WebSearchOutput{Query: args.Query, Error: terr}, nil
 	}
 
 	duration := time.Since(startTime)
 
+	// Merge the same page reached via different URLs
+	var merged int
+	results.Results, merged = dedupeResults(results.Results)
+
+	// Re-rank before trimming so boosted results from later pages count
+	ranked := rankResults(results.Results, ranking)
+	if args.MaxResults > 0 && len(ranked) > min(args.MaxResults, maxResultsCap) {
+		ranked = ranked[:min(args.MaxResults, maxResultsCap)]
+	}
+	total := len(ranked)
+	offset := min(max(args.Offset, 0), total)
+	ranked = ranked[offset:]
+
 	// Format output
-	if len(results.Results) == 0 {
+	if len(ranked) == 0 && total > 0 {
+		output := fmt.Sprintf("# No More Results\n\nOffset %d is past the %d results for \"%s\" on %s. Continue with pageno=%d and no offset.", args.Offset, total, args.Query, pageRange(args.PageNo, lastPage), lastPage+1)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: output},
+			},
+		}, &WebSearchOutput{Query: args.Query}, nil
+	}
+	if len(ranked) == 0 {
 		output := fmt.Sprintf("# No Results Found\n\nNo results found for query: \"%s\"\n\nTry:\n- Different keywords\n- Broader search terms\n- Checking spelling", args.Query)
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
 				&mcp.TextContent{Text: output},
 			},
-		}, nil, nil
+		}, &WebSearchOutput{Query: args.Query}, nil
 	}
 
 	output := fmt.Sprintf("# Search Results for \"%s\"\n\n", args.Query)
-	output += fmt.Sprintf("Found %d results (page %d) in %dms\n\n", len(results.Results), args.PageNo, duration.Milliseconds())
+	output += fmt.Sprintf("Found %d results (%s) in %dms", total, pageRange(args.PageNo, lastPage), duration.Milliseconds())
+	if offset > 0 {
+		output += fmt.Sprintf(", starting after the first %d", offset)
+	}
+	if merged > 0 {
+		output += fmt.Sprintf(", %d duplicates merged", merged)
+	}
+
+	if ranking.enabled() {
+		output += ", re-ranked by domain and recency"
+	}
+	output += "\n\n"
+	if pageErr != nil {
+		output += partialResultsNote(pageErr)
+	}
+
+	shown := 0
+	for _, result := range ranked {
+		entry := fmt.Sprintf("## %d. %s\n\n", result.Rank, result.Title)
+		entry += fmt.Sprintf("**URL:** %s\n\n", result.URL)
+		if ranking.enabled() {
+			entry += fmt.Sprintf("**Score:** %.2f (base %.2f × domain %.2f × recency %.2f)\n\n",
+				result.Score, result.ScoreComponents.Base, result.ScoreComponents.Domain, 1+result.ScoreComponents.Recency)
+		}
+		entry += fmt.Sprintf("%s\n\n", result.Content)
+		entry += "---\n\n"
+
+		// Stop at a result boundary once the budget is spent. The first
+		// result is always shown (trimmed if need be) so the caller gets
+		// something actionable even with a tiny budget.
+		if args.MaxTokens > 0 && estimateTokens(output+entry) > args.MaxTokens {
+			if shown == 0 {
+				entry, _ = truncateToTokenBudget(entry, max(args.MaxTokens-estimateTokens(output), 1))
+				output += entry + "\n\n"
+				shown++
+			}
+			break
+		}
+
+		output += entry
+		shown++
+	}
 
-	for i, result := range results.Results {
-		output += fmt.Sprintf("## %d. %s\n\n", i+1, result.Title)
-		output += fmt.Sprintf("**URL:** %s\n\n", result.URL)
-		output += fmt.Sprintf("%s\n\n", result.Content)
-		output += "---\n\n"
+	if shown < len(ranked) {
+		output += fmt.Sprintf("*Output truncated to fit max_tokens=%d: showing results %d-%d of %d. Repeat the call with offset=%d to see the rest, or raise max_tokens.*\n",
+			args.MaxTokens, offset+1, offset+shown, total, offset+shown)
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			&mcp.TextContent{Text: output},
 		},
-	}, nil, nil
+	}, &WebSearchOutput{Query: args.Query, Results: ranked[:shown], Partial: pageErr != nil}, nil
 }
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// newTestSearXNG returns a client for a fake SearXNG served by handler.
+func newTestSearXNG(t *testing.T, handler http.HandlerFunc) *SearXNGClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	client, err := NewSearXNGClient(SearXNGSettings{URL: srv.URL}, NewCache(60, 100), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// servePages answers searches with pages[pageno-1], and no results past
+// the last page.
+func servePages(pages ...[]SearXNGResult) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pageno, _ := strconv.Atoi(r.URL.Query().Get("pageno"))
+		var results []SearXNGResult
+		if pageno >= 1 && pageno <= len(pages) {
+			results = pages[pageno-1]
+		}
+		json.NewEncoder(w).Encode(SearXNGResponse{Results: results})
+	}
+}
+
+// makeResults returns n results with distinct URLs, named prefix-1 to
+// prefix-n.
+func makeResults(prefix string, n int) []SearXNGResult {
+	results := make([]SearXNGResult, n)
+	for i := range results {
+		results[i] = SearXNGResult{
+			Title:   fmt.Sprintf("%s-%d", prefix, i+1),
+			URL:     fmt.Sprintf("https://example.com/%s/%d", prefix, i+1),
+			Content: strings.Repeat("Some snippet text about the result. ", 4),
+		}
+	}
+	return results
+}
+
+func toolText(result *mcp.CallToolResult) string {
+	var text string
+	for _, c := range result.Content {
+		if tc, ok := c.(*mcp.TextContent); ok {
+			text += tc.Text
+		}
+	}
+	return text
+}
+
+func webSearch(t *testing.T, client *SearXNGClient, args WebSearchArgs) (string, *WebSearchOutput) {
+	t.Helper()
+	result, output, err := handleWebSearch(context.Background(), &mcp.CallToolRequest{}, client, NewRankingConfig(RankingSettings{}), nil, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toolText(result), output
+}
+
+var offsetRegex = regexp.MustCompile(`offset=(\d+) to see the rest`)
+
+func TestWebSearchOffsetContinuesTrimmedOutput(t *testing.T) {
+	client := newTestSearXNG(t, servePages(makeResults("r", 10)))
+
+	var titles []string
+	for offset, calls := 0, 0; ; calls++ {
+		if calls > 10 {
+			t.Fatal("runaway pagination")
+		}
+		text, output := webSearch(t, client, WebSearchArgs{Query: "q", MaxTokens: 200, Offset: offset})
+		if output.Error != nil {
+			t.Fatalf("offset %d: %v", offset, output.Error)
+		}
+		if len(output.Results) == 0 {
+			t.Fatalf("offset %d showed nothing: %s", offset, text)
+		}
+		for _, r := range output.Results {
+			titles = append(titles, r.Title)
+		}
+		m := offsetRegex.FindStringSubmatch(text)
+		if m == nil {
+			break
+		}
+		next, _ := strconv.Atoi(m[1])
+		if next != offset+len(output.Results) {
+			t.Fatalf("offset %d showed %d results but continues at %d", offset, len(output.Results), next)
+		}
+		offset = next
+	}
+
+	if len(titles) != 10 {
+		t.Fatalf("saw %d results across calls, want all 10: %v", len(titles), titles)
+	}
+	for i, title := range titles {
+		if want := fmt.Sprintf("r-%d", i+1); title != want {
+			t.Errorf("result %d = %s, want %s", i, title, want)
+		}
+	}
+}
+
+func TestWebSearchOffsetPastTheEnd(t *testing.T) {
+	client := newTestSearXNG(t, servePages(makeResults("r", 3)))
+	text, output := webSearch(t, client, WebSearchArgs{Query: "q", Offset: 3})
+	if len(output.Results) != 0 || !strings.Contains(text, "No More Results") || !strings.Contains(text, "pageno=2") {
+		t.Errorf("offset past the end: %s", text)
+	}
+
+	// A negative offset is treated as 0
+	_, output = webSearch(t, client, WebSearchArgs{Query: "q", Offset: -5})
+	if len(output.Results) != 3 {
+		t.Errorf("negative offset showed %d results, want 3", len(output.Results))
+	}
+}
+
+func TestWebSearchTokenBudget(t *testing.T) {
+	client := newTestSearXNG(t, servePages(makeResults("r", 10)))
+
+	text, output := webSearch(t, client, WebSearchArgs{Query: "q", MaxTokens: 300})
+	if n := len(output.Results); n == 0 || n == 10 {
+		t.Fatalf("max_tokens=300 showed %d of 10 results", n)
+	}
+	// The footer is added after the budget check, so allow for it
+	body, _, _ := strings.Cut(text, "*Output truncated")
+	if tokens := estimateTokens(body); tokens > 300 {
+		t.Errorf("output before the footer is %d tokens, over the budget of 300", tokens)
+	}
+
+	// Even a tiny budget shows the first result
+	text, output = webSearch(t, client, WebSearchArgs{Query: "q", MaxTokens: 1})
+	if len(output.Results) != 1 || !strings.Contains(text, "offset=1") {
+		t.Errorf("max_tokens=1 showed %d results: %s", len(output.Results), text)
+	}
+
+	// Without a budget everything is shown
+	text, output = webSearch(t, client, WebSearchArgs{Query: "q"})
+	if len(output.Results) != 10 || strings.Contains(text, "truncated") {
+		t.Errorf("no max_tokens showed %d results", len(output.Results))
+	}
+}
+
+func TestPagesToFetch(t *testing.T) {
+	tests := []struct {
+		args WebSearchArgs
+		want int
+	}{
+		{WebSearchArgs{}, 1},
+		{WebSearchArgs{Pages: 3}, 3},
+		{WebSearchArgs{Pages: 50}, maxPages},
+		{WebSearchArgs{MaxResults: 25}, 3},
+		{WebSearchArgs{MaxResults: 1000}, maxPages},
+		{WebSearchArgs{Pages: 2, MaxResults: 50}, 2},
+	}
+	for _, tt := range tests {
+		if got := pagesToFetch(tt.args); got != tt.want {
+			t.Errorf("pagesToFetch(%+v) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
+
+// failPage serves pages like servePages, but fails page n with status.
+func failPage(n, status int, pages ...[]SearXNGResult) http.HandlerFunc {
+	serve := servePages(pages...)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageno") == strconv.Itoa(n) {
+			http.Error(w, "upstream exploded", status)
+			return
+		}
+		serve(w, r)
+	}
+}
+
+func TestSearchPagesReportsALaterFailedPage(t *testing.T) {
+	client := newTestSearXNG(t, failPage(2, http.StatusBadGateway, makeResults("a", 3), makeResults("b", 3), makeResults("c", 3)))
+
+	results, lastPage, err := client.SearchPages(context.Background(), SearchRequest{Query: "q", PageNo: 1}, 3)
+	var pageErr *PageError
+	if !errors.As(err, &pageErr) || pageErr.Page != 2 {
+		t.Fatalf("err = %v, want a *PageError for page 2", err)
+	}
+	if len(results) != 3 || lastPage != 1 {
+		t.Errorf("got %d results up to page %d, want page 1's 3", len(results), lastPage)
+	}
+	if classifyError(err).Code != ErrUpstream5xx {
+		t.Errorf("page error classified as %s", classifyError(err).Code)
+	}
+
+	text, output := webSearch(t, client, WebSearchArgs{Query: "q", Pages: 3})
+	if output.Error != nil || !output.Partial || len(output.Results) != 3 {
+		t.Errorf("web_search output = %+v", output)
+	}
+	if !strings.Contains(text, "Partial results") || !strings.Contains(text, "page 2 failed") || !strings.Contains(text, "pageno=2") {
+		t.Errorf("web_search doesn't say page 2 failed: %s", text)
+	}
+}
+
+func TestSearchPagesFirstPageFailure(t *testing.T) {
+	client := newTestSearXNG(t, failPage(1, http.StatusServiceUnavailable, makeResults("a", 3), makeResults("b", 3)))
+
+	results, _, err := client.SearchPages(context.Background(), SearchRequest{Query: "q", PageNo: 1}, 2)
+	var pageErr *PageError
+	if err == nil || errors.As(err, &pageErr) || results != nil {
+		t.Fatalf("results = %v, err = %v, want a plain error", results, err)
+	}
+
+	text, output := webSearch(t, client, WebSearchArgs{Query: "q", Pages: 2})
+	if output.Error == nil || output.Error.Code != ErrUpstream5xx || !strings.Contains(text, "Search failed") {
+		t.Errorf("web_search output = %+v: %s", output, text)
+	}
+}
+
+func TestSearchPagesStopsAtAnEmptyPage(t *testing.T) {
+	client := newTestSearXNG(t, servePages(makeResults("a", 3), makeResults("b", 2)))
+	results, lastPage, err := client.SearchPages(context.Background(), SearchRequest{Query: "q", PageNo: 1}, 5)
+	if err != nil || len(results) != 5 || lastPage != 2 {
+		t.Errorf("got %d results up to page %d (err %v), want 5 up to page 2", len(results), lastPage, err)
+	}
+}
+
+// writePEM writes a PEM block of typ to a file in dir and returns its path.
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClientCert makes a self-signed client certificate named cn and
+// writes it and its key to dir.
+func newClientCert(t *testing.T, dir, cn string) (cert *x509.Certificate, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return must(x509.ParseCertificate(der)), writePEM(t, dir, cn+".crt", "CERTIFICATE", der), writePEM(t, dir, cn+".key", "EC PRIVATE KEY", keyDER)
+}
+
+func TestSearXNGConnTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := newClientCert(t, dir, "mcp-searxng")
+	_, otherCertFile, otherKeyFile := newClientCert(t, dir, "stranger")
+
+	var peer string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer = r.TLS.PeerCertificates[0].Subject.CommonName
+		json.NewEncoder(w).Encode(SearXNGResponse{Results: makeResults("r", 1)})
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: x509.NewCertPool()}
+	srv.TLS.ClientCAs.AddCert(clientCert)
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+
+	search := func(settings SearXNGSettings) error {
+		settings.URL = srv.URL
+		client, err := NewSearXNGClient(settings, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Search(context.Background(), SearchRequest{Query: "q", PageNo: 1})
+		return err
+	}
+
+	if err := search(SearXNGSettings{CAFile: caFile, TLSCertFile: certFile, TLSKeyFile: keyFile}); err != nil {
+		t.Fatalf("mTLS search: %v", err)
+	}
+	if peer != "mcp-searxng" {
+		t.Errorf("server saw client certificate %q", peer)
+	}
+
+	failures := map[string]SearXNGSettings{
+		"no client certificate": {CAFile: caFile},
+		"untrusted client cert": {CAFile: caFile, TLSCertFile: otherCertFile, TLSKeyFile: otherKeyFile},
+		"server CA not trusted": {TLSCertFile: certFile, TLSKeyFile: keyFile},
+	}
+	for name, settings := range failures {
+		if err := search(settings); classifyError(err).Code != ErrTLS {
+			t.Errorf("%s: err = %v, want a TLS error", name, err)
+		}
+	}
+
+	// Unusable files fail when the client is built, not on first use
+	for name, settings := range map[string]SearXNGSettings{
+		"missing CA":        {URL: srv.URL, CAFile: filepath.Join(dir, "nope.pem")},
+		"CA without certs":  {URL: srv.URL, CAFile: keyFile},
+		"key doesn't match": {URL: srv.URL, TLSCertFile: certFile, TLSKeyFile: otherKeyFile},
+	} {
+		if _, err := NewSearXNGClient(settings, nil, nil); err == nil {
+			t.Errorf("%s: client was built", name)
+		}
+	}
+}
+
+func TestSearXNGRequestCredentials(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		json.NewEncoder(w).Encode(SearXNGResponse{})
+	}))
+	t.Cleanup(srv.Close)
+	request := func(settings SearXNGSettings) *http.Request {
+		t.Helper()
+		settings.URL = srv.URL
+		conn, err := newSearXNGConn(settings, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := conn.newRequest(context.Background(), must(url.Parse(srv.URL+"/search")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := conn.httpClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return got
+	}
+
+	r := request(SearXNGSettings{Username: "admin", Password: "hunter2"})
+	if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "hunter2" {
+		t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+	}
+	// A lone username isn't sent
+	if r := request(SearXNGSettings{Username: "admin"}); r.Header.Get("Authorization") != "" {
+		t.Errorf("lone username sent Authorization %q", r.Header.Get("Authorization"))
+	}
+
+	// A bearer token replaces basic auth, and configured headers replace
+	// both, except Host, which sets the request's host
+	r = request(SearXNGSettings{Username: "admin", Password: "hunter2", BearerToken: "tok"})
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		t.Errorf("Authorization = %q, want the bearer token", r.Header.Get("Authorization"))
+	}
+	r = request(SearXNGSettings{BearerToken: "tok", Headers: map[string]string{
+		"authorization": "ApiKey gateway",
+		"X-Tenant":      "search",
+		"User-Agent":    "custom/1.0",
+		"Host":          "searxng.internal",
+	}})
+	if r.Header.Get("Authorization") != "ApiKey gateway" || r.Header.Get("X-Tenant") != "search" || r.UserAgent() != "custom/1.0" {
+		t.Errorf("headers = %v", r.Header)
+	}
+	if r.Host != "searxng.internal" || r.Header.Get("Host") != "" {
+		t.Errorf("host = %q (header %q), want searxng.internal", r.Host, r.Header.Get("Host"))
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxSuggestions caps how many completions suggest returns.
+const maxSuggestions = 15
+
+// SuggestArgs defines the parameters for query suggestions
+type SuggestArgs struct {
+	Query    string `json:"query" jsonschema:"the partial or ambiguous query to complete"`
+	Language string `json:"language,omitempty" jsonschema:"language code for suggestions (e.g., 'en', 'fr', 'de')"`
+	Backend  string `json:"backend,omitempty" jsonschema:"autocomplete backend to use instead of the instance default, e.g. duckduckgo, google, wikipedia"`
+}
+
+// SuggestOutput is suggest's structured output.
+type SuggestOutput struct {
+	Query       string     `json:"query"`
+	Suggestions []string   `json:"suggestions,omitempty"`
+	Error       *ToolError `json:"error,omitempty"`
+}
+
+// Autocomplete returns SearXNG's completions for a partial query, using
+// the instance's autocomplete backend unless backend is set. Results are
+// cached like page reads, since agents tend to retry the same prefixes.
+func (c *SearXNGClient) Autocomplete(ctx context.Context, query, language, backend string) ([]string, error) {
+	cacheKey := "autocomplete:" + backend + ":" + language + ":" + strings.ToLower(query)
+	if c.cache != nil {
+		if cached := c.cache.Get(cacheKey); cached != "" {
+			var suggestions []string
+			if json.Unmarshal([]byte(cached), &suggestions) == nil {
+				return suggestions, nil
+			}
+		}
+	}
+
+	// Build URL
+	conn := c.current()
+	completeURL, err := url.Parse(conn.baseURL + "/autocompleter")
+	if err != nil {
+		return nil, fmt.Errorf("invalid SearXNG URL: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("q", query)
+	if language != "" && language != "all" {
+		params.Set("language", language)
+	}
+	if backend != "" {
+		params.Set("autocomplete", backend)
+	}
+	completeURL.RawQuery = params.Encode()
+
+	req, err := conn.newRequest(ctx, completeURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := conn.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("autocomplete request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read autocomplete response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpStatusError(resp, "SearXNG", string(body))
+	}
+
+	suggestions, err := parseAutocomplete(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't cache empty answers; they're often a transient backend failure.
+	if c.cache != nil && len(suggestions) > 0 {
+		if data, err := json.Marshal(suggestions); err == nil {
+			c.cache.Set(cacheKey, string(data))
+		}
+	}
+
+	return suggestions, nil
+}
+
+// parseAutocomplete decodes either of SearXNG's autocompleter formats: a
+// plain JSON list, or the OpenSearch suggestions format
+// ["query", ["completion", ...]] it returns to non-XHR clients.
+func parseAutocomplete(body []byte) ([]string, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, newToolError(ErrInvalidResponse, err, "failed to parse autocomplete response: %v", err)
+	}
+
+	var openSearch []string
+	if len(list) == 2 && json.Unmarshal(list[1], &openSearch) == nil {
+		return openSearch, nil
+	}
+
+	var suggestions []string
+	for _, item := range list {
+		var s string
+		if json.Unmarshal(item, &s) == nil && s != "" {
+			suggestions = append(suggestions, s)
+		}
+	}
+	return suggestions, nil
+}
+
+func handleSuggest(ctx context.Context, req *mcp.CallToolRequest, client *SearXNGClient, args SuggestArgs) (*mcp.CallToolResult, *SuggestOutput, error) {
+	// Validate required parameter
+	query := strings.TrimSpace(args.Query)
+	if query == "" {
+		terr := invalidArgument("query parameter is required")
+		return toolErrorResult("", terr), &SuggestOutput{Query: query, Error: terr}, nil
+	}
+
+	suggestions, err := client.Autocomplete(ctx, query, args.Language, args.Backend)
+	if err != nil {
+		terr := classifyError(err)
+		return toolErrorResult("Autocomplete failed", terr), &SuggestOutput{Query: query, Error: terr}, nil
+	}
+
+	// Drop blanks, repeats and the query itself
+	var unique []string
+	for _, s := range suggestions {
+		s = strings.TrimSpace(s)
+		if s != "" && !strings.EqualFold(s, query) && !containsFold(unique, s) {
+			unique = append(unique, s)
+		}
+	}
+	if len(unique) > maxSuggestions {
+		unique = unique[:maxSuggestions]
+	}
+
+	// Format output
+	if len(unique) == 0 {
+		output := fmt.Sprintf("# No Suggestions\n\nNo completions found for \"%s\". The SearXNG instance may have autocomplete disabled (search.autocomplete in settings.yml); try the backend parameter.", query)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: output},
+			},
+		}, &SuggestOutput{Query: query}, nil
+	}
+
+	output := fmt.Sprintf("# Suggestions for \"%s\"\n\n", query)
+	for i, s := range unique {
+		output += fmt.Sprintf("%d. %s\n", i+1, s)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: output},
+		},
+	}, &SuggestOutput{Query: query, Suggestions: unique}, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestParseAutocomplete(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{`["golang", "go language", "gopher"]`, []string{"golang", "go language", "gopher"}},
+		{`["go", ["golang", "go language"]]`, []string{"golang", "go language"}},
+		{`["go", "golang"]`, []string{"go", "golang"}},
+		{`["go", []]`, []string{}},
+		{`["a", 1, "", null, "b"]`, []string{"a", "b"}},
+		{`[]`, nil},
+	}
+	for _, tt := range tests {
+		got, err := parseAutocomplete([]byte(tt.body))
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("parseAutocomplete(%s) = %q, %v, want %q", tt.body, got, err, tt.want)
+		}
+	}
+
+	for _, body := range []string{`{"suggestions": []}`, `<html>`, ``} {
+		_, err := parseAutocomplete([]byte(body))
+		if classifyError(err).Code != ErrInvalidResponse {
+			t.Errorf("parseAutocomplete(%q) error = %v, want invalid_response", body, err)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	var requests []string
+	client := newTestSearXNG(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		switch r.URL.Query().Get("q") {
+		case "go":
+			w.Write([]byte(`["go", ["Go", "golang", " golang ", "GOLANG tutorial", "", "gopher"]]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	})
+	suggest := func(args SuggestArgs) (string, *SuggestOutput) {
+		t.Helper()
+		result, output, err := handleSuggest(context.Background(), &mcp.CallToolRequest{}, client, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return toolText(result), output
+	}
+
+	// Blanks, repeats and the query itself are dropped
+	_, output := suggest(SuggestArgs{Query: " go ", Backend: "duckduckgo", Language: "en"})
+	if want := []string{"golang", "GOLANG tutorial", "gopher"}; !slices.Equal(output.Suggestions, want) {
+		t.Errorf("suggestions = %q, want %q", output.Suggestions, want)
+	}
+	if !strings.Contains(requests[0], "autocomplete=duckduckgo") || !strings.Contains(requests[0], "language=en") {
+		t.Errorf("request = %s, want the backend and language passed on", requests[0])
+	}
+
+	// Completions are cached, but empty answers aren't
+	suggest(SuggestArgs{Query: "GO", Backend: "duckduckgo", Language: "en"})
+	text, _ := suggest(SuggestArgs{Query: "nothing"})
+	suggest(SuggestArgs{Query: "nothing"})
+	if len(requests) != 3 {
+		t.Errorf("made %d requests, want 3", len(requests))
+	}
+	if !strings.Contains(text, "No Suggestions") {
+		t.Errorf("empty answer output = %s", text)
+	}
+
+	if _, output := suggest(SuggestArgs{Query: "  "}); output.Error == nil || output.Error.Code != ErrInvalidArgument {
+		t.Errorf("blank query error = %+v", output.Error)
+	}
+}
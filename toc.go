@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	headingRegex   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	sectionIDRegex = regexp.MustCompile(`^\d+(\.\d+)*$`)
+)
+
+// tocEntry is one heading in a document's table of contents. IDs are
+// hierarchical section numbers ("2", "2.1", "2.1.3") derived purely from
+// the heading structure, so the same page always yields the same IDs.
+type tocEntry struct {
+	ID    string
+	Level int
+	Title string
+	Path  []string
+
+	// Offset and Size are in characters (runes), matching startChar.
+	Offset int
+	Size   int
+
+	start, end int // byte range of the whole section, heading included
+}
+
+// buildTOC parses the ATX headings of a Markdown document, skipping
+// anything inside fenced code blocks. Each section runs until the next
+// heading of the same or a higher level.
+func buildTOC(content string) []tocEntry {
+	blocks := markdownBlocks(content)
+	inFence := func(pos int) bool {
+		i := sort.Search(len(blocks), func(i int) bool { return blocks[i].end > pos })
+		return i < len(blocks) && blocks[i].start <= pos && isFenceLine(content[blocks[i].start:])
+	}
+
+	type frame struct {
+		level int
+		n     int
+		title string
+	}
+	var stack []frame
+	var entries []tocEntry
+	runes := 0
+	prev := 0
+
+	for lineStart := 0; lineStart < len(content); {
+		next := len(content)
+		if idx := strings.IndexByte(content[lineStart:], '\n'); idx >= 0 {
+			next = lineStart + idx + 1
+		}
+		line := strings.TrimRight(content[lineStart:next], "\r\n")
+
+		m := headingRegex.FindStringSubmatch(line)
+		if m == nil || inFence(lineStart) {
+			lineStart = next
+			continue
+		}
+
+		level := len(m[1])
+		title := m[2]
+
+		// Pop deeper headings; a heading that replaces a popped sibling
+		// position (e.g. h1, h3, h2) continues that position's numbering
+		// so IDs never repeat.
+		var popped *frame
+		for len(stack) > 0 && stack[len(stack)-1].level > level {
+			f := stack[len(stack)-1]
+			popped = &f
+			stack = stack[:len(stack)-1]
+		}
+		switch {
+		case len(stack) > 0 && stack[len(stack)-1].level == level:
+			stack[len(stack)-1].n++
+			stack[len(stack)-1].title = title
+		case popped != nil:
+			stack = append(stack, frame{level: level, n: popped.n + 1, title: title})
+		default:
+			stack = append(stack, frame{level: level, n: 1, title: title})
+		}
+
+		ids := make([]string, len(stack))
+		path := make([]string, len(stack))
+		for i, f := range stack {
+			ids[i] = strconv.Itoa(f.n)
+			path[i] = f.title
+		}
+
+		runes += utf8.RuneCountInString(content[prev:lineStart])
+		prev = lineStart
+		entries = append(entries, tocEntry{
+			ID:     strings.Join(ids, "."),
+			Level:  level,
+			Title:  title,
+			Path:   path,
+			Offset: runes,
+			start:  lineStart,
+			end:    len(content),
+		})
+
+		lineStart = next
+	}
+
+	// Close each section at the next heading of the same or higher level.
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			if entries[j].Level <= entries[i].Level {
+				entries[i].end = entries[j].start
+				break
+			}
+		}
+		entries[i].Size = utf8.RuneCountInString(content[entries[i].start:entries[i].end])
+	}
+
+	return entries
+}
+
+func isFenceLine(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "```") || strings.HasPrefix(s, "~~~")
+}
+
+// renderTOC formats a table of contents as a nested Markdown list with
+// each section's ID, character offset and size.
+func renderTOC(entries []tocEntry) string {
+	if len(entries) == 0 {
+		return "No headings found."
+	}
+
+	minLevel := entries[0].Level
+	for _, e := range entries {
+		minLevel = min(minLevel, e.Level)
+	}
+
+	output := "# Table of Contents\n\n"
+	for _, e := range entries {
+		indent := strings.Repeat("  ", e.Level-minLevel)
+		output += fmt.Sprintf("%s- [%s] %s (offset %d, %d chars)\n", indent, e.ID, e.Title, e.Offset, e.Size)
+	}
+	output += "\nSelect a section with section=\"<id>\" (e.g. \"" + entries[0].ID + "\") or an exact path such as \"" + strings.Join(entries[len(entries)-1].Path, " > ") + "\"."
+
+	return output
+}
+
+// maxSectionSuggestions caps how many section IDs an unmatched or
+// ambiguous selector error lists.
+const maxSectionSuggestions = 5
+
+// findSection resolves a section selector against a table of contents.
+// In order it tries: a section ID ("2.1"), an exact heading path
+// ("Guide > Usage > Configuration"), the tail of exactly one heading's
+// path ("Usage > Configuration", for pages whose title heading callers
+// leave out), and an exact heading title. Matching is case-insensitive.
+// A selector that matches several headings, or none, is an
+// invalid_argument error listing the candidates or the nearest sections.
+func findSection(entries []tocEntry, selector string) (*tocEntry, error) {
+	selector = strings.TrimSpace(selector)
+	if len(entries) == 0 {
+		return nil, invalidArgument("section %q not found: the page has no headings", selector)
+	}
+
+	if sectionIDRegex.MatchString(selector) {
+		for i := range entries {
+			if entries[i].ID == selector {
+				return &entries[i], nil
+			}
+		}
+	}
+
+	if strings.Contains(selector, ">") {
+		parts := strings.Split(selector, ">")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		for i := range entries {
+			if pathEqual(entries[i].Path, parts) {
+				return &entries[i], nil
+			}
+		}
+		var tails []*tocEntry
+		for i := range entries {
+			if len(entries[i].Path) > len(parts) && pathEqual(entries[i].Path[len(entries[i].Path)-len(parts):], parts) {
+				tails = append(tails, &entries[i])
+			}
+		}
+		if section, err := uniqueSection(selector, tails); section != nil || err != nil {
+			return section, err
+		}
+	}
+
+	var titled []*tocEntry
+	for i := range entries {
+		if strings.EqualFold(entries[i].Title, selector) {
+			titled = append(titled, &entries[i])
+		}
+	}
+	if section, err := uniqueSection(selector, titled); section != nil || err != nil {
+		return section, err
+	}
+
+	return nil, invalidArgument("section %q not found; nearest sections: %s", selector, describeSections(nearestSections(entries, selector)))
+}
+
+// uniqueSection returns the only candidate, an ambiguity error if there
+// are several, or neither if there are none.
+func uniqueSection(selector string, candidates []*tocEntry) (*tocEntry, error) {
+	switch len(candidates) {
+	case 0:
+		return nil, nil
+	case 1:
+		return candidates[0], nil
+	}
+	return nil, invalidArgument("section %q is ambiguous; it matches %s. Select one by ID", selector, describeSections(candidates))
+}
+
+// nearestSections ranks headings by how much their titles have in common
+// with selector, falling back to the first sections of the page.
+func nearestSections(entries []tocEntry, selector string) []*tocEntry {
+	lower := strings.ToLower(selector)
+	words := make(map[string]bool)
+	for _, w := range tokenize(selector) {
+		words[w] = true
+	}
+
+	type scored struct {
+		entry *tocEntry
+		score int
+	}
+	var candidates []scored
+	for i := range entries {
+		title := strings.ToLower(entries[i].Title)
+		score := 0
+		if strings.Contains(title, lower) || strings.Contains(lower, title) {
+			score += 2
+		}
+		for _, w := range tokenize(title) {
+			if words[w] {
+				score++
+			}
+		}
+		if score > 0 {
+			candidates = append(candidates, scored{&entries[i], score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	var nearest []*tocEntry
+	for _, c := range candidates[:min(len(candidates), maxSectionSuggestions)] {
+		nearest = append(nearest, c.entry)
+	}
+	if len(nearest) == 0 {
+		for i := range entries[:min(len(entries), maxSectionSuggestions)] {
+			nearest = append(nearest, &entries[i])
+		}
+	}
+	return nearest
+}
+
+// describeSections lists sections as "2.1 (Usage > Configuration)".
+func describeSections(sections []*tocEntry) string {
+	var parts []string
+	for _, e := range sections[:min(len(sections), maxSectionSuggestions)] {
+		parts = append(parts, fmt.Sprintf("%s (%s)", e.ID, strings.Join(e.Path, " > ")))
+	}
+	if len(sections) > maxSectionSuggestions {
+		parts = append(parts, fmt.Sprintf("and %d more", len(sections)-maxSectionSuggestions))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func pathEqual(path, parts []string) bool {
+	if len(path) != len(parts) {
+		return false
+	}
+	for i := range path {
+		if !strings.EqualFold(path[i], parts[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const tocDoc = `# Guide
+
+## Install
+
+### Linux
+
+### macOS
+
+## Usage
+
+### Configuration
+
+### Linux
+
+## FAQ
+`
+
+func TestBuildTOCIDs(t *testing.T) {
+	var got []string
+	for _, e := range buildTOC(tocDoc) {
+		got = append(got, e.ID+" "+strings.Join(e.Path, " > "))
+	}
+	want := []string{
+		"1 Guide",
+		"1.1 Guide > Install",
+		"1.1.1 Guide > Install > Linux",
+		"1.1.2 Guide > Install > macOS",
+		"1.2 Guide > Usage",
+		"1.2.1 Guide > Usage > Configuration",
+		"1.2.2 Guide > Usage > Linux",
+		"1.3 Guide > FAQ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("buildTOC =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestFindSection(t *testing.T) {
+	entries := buildTOC(tocDoc)
+	tests := []struct {
+		selector string
+		wantID   string
+		wantErr  string
+	}{
+		{selector: "1.2.1", wantID: "1.2.1"},
+		{selector: "Guide > Usage > Linux", wantID: "1.2.2"},
+		{selector: "usage > configuration", wantID: "1.2.1"},
+		{selector: "Install > Linux", wantID: "1.1.1"},
+		{selector: "macOS", wantID: "1.1.2"},
+		{selector: " FAQ ", wantID: "1.3"},
+		{selector: "Linux", wantErr: `section "Linux" is ambiguous; it matches 1.1.1 (Guide > Install > Linux), 1.2.2 (Guide > Usage > Linux)`},
+		{selector: "Config", wantErr: `section "Config" not found; nearest sections: 1.2.1 (Guide > Usage > Configuration)`},
+		{selector: "Usage > Config", wantErr: "not found"},
+		{selector: "9.9", wantErr: "nearest sections: 1 (Guide), 1.1 (Guide > Install)"},
+	}
+	for _, tt := range tests {
+		section, err := findSection(entries, tt.selector)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("findSection(%q) error = %v, want %q", tt.selector, err, tt.wantErr)
+			} else if classifyError(err).Code != ErrInvalidArgument {
+				t.Errorf("findSection(%q) error code = %s, want %s", tt.selector, classifyError(err).Code, ErrInvalidArgument)
+			}
+		case err != nil:
+			t.Errorf("findSection(%q) error = %v", tt.selector, err)
+		case section.ID != tt.wantID:
+			t.Errorf("findSection(%q) = %s, want %s", tt.selector, section.ID, tt.wantID)
+		}
+	}
+}
+
+func TestFindSectionWithoutHeadings(t *testing.T) {
+	if _, err := findSection(buildTOC("just text"), "Intro"); err == nil || !strings.Contains(err.Error(), "no headings") {
+		t.Errorf("findSection on a page without headings: error = %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"unicode/utf8"
+)
+
+// approxCharsPerToken is the rough number of ASCII characters per model
+// token. Real tokenizers vary, but ~4 chars/token holds well enough for
+// English prose and Markdown to keep output under a caller's budget.
+const approxCharsPerToken = 4
+
+// estimateTokens approximates how many model tokens s will consume.
+// ASCII text is counted at approxCharsPerToken chars per token; every
+// non-ASCII rune (CJK, emoji, accented letters) is counted as a full
+// token, since tokenizers rarely merge those.
+func estimateTokens(s string) int {
+	var c tokenCounter
+	c.add(s)
+	return c.tokens()
+}
+
+// truncateToTokenBudget returns the longest prefix of content that fits
+// in maxTokens, cut at a Markdown-safe boundary (see snapChunkEnd). The
+// second return value reports whether anything was cut.
+func truncateToTokenBudget(content string, maxTokens int) (string, bool) {
+	end := budgetCut(content, 0, len(content), maxTokens)
+	return content[:end], end < len(content)
+}
+
+// budgetCut returns the byte offset at which content[start:end] must be
+// cut to fit in maxTokens, or end if it already fits. The cut is snapped
+// against the whole of content, so structure that began before start
+// (e.g. an open code fence) is taken into account. A budget too small to
+// reach any safe boundary still ends on a whole word, overshooting it
+// slightly, so that tiny budgets make readable progress.
+func budgetCut(content string, start, end, maxTokens int) int {
+	if maxTokens <= 0 {
+		return end
+	}
+
+	var counted tokenCounter
+	for i, r := range content[start:end] {
+		counted.addRune(r)
+		if counted.tokens() > maxTokens {
+			return finishWord(content, snapChunkEnd(content, markdownBlocks(content), start, start+i), end)
+		}
+	}
+	return end
+}
+
+// tokenCounter accumulates estimateTokens incrementally so callers
+// scanning forward through a document don't re-count the prefix.
+type tokenCounter struct {
+	ascii int
+	other int
+}
+
+func (c *tokenCounter) add(s string) {
+	for _, r := range s {
+		c.addRune(r)
+	}
+}
+
+func (c *tokenCounter) addRune(r rune) {
+	if r < utf8.RuneSelf {
+		c.ascii++
+	} else {
+		c.other++
+	}
+}
+
+func (c *tokenCounter) tokens() int {
+	return (c.ascii+approxCharsPerToken-1)/approxCharsPerToken + c.other
+}
@@ -9,54 +9,85 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type URLReader struct {
-	cache      *Cache
+	cache *Cache
+
+	mu         sync.RWMutex
 	httpClient *http.Client
+	maxBytes   int
 }
 
 // URLReadArgs defines the parameters for URL reading
 type URLReadArgs struct {
 	URL            string `json:"url" jsonschema:"URL to read"`
-	StartChar      int    `json:"startChar,omitempty" jsonschema:"starting character position for content extraction (default: 0)"`
-	MaxLength      int    `json:"maxLength,omitempty" jsonschema:"maximum number of characters to return"`
-	Section        string `json:"section,omitempty" jsonschema:"extract content under a specific heading"`
+	StartChar      int    `json:"startChar,omitempty" jsonschema:"starting character (Unicode code point) position for content extraction (default: 0)"`
+	MaxLength      int    `json:"maxLength,omitempty" jsonschema:"maximum number of characters to return; the cut is moved back to avoid splitting code blocks, tables or links"`
+	Section        string `json:"section,omitempty" jsonschema:"extract one section: a section ID from readHeadings (e.g. '2.1'), an exact heading path (e.g. 'Usage > Configuration'), or an exact heading title; ambiguous or unknown selectors are errors"`
 	ParagraphRange string `json:"paragraphRange,omitempty" jsonschema:"return specific paragraph ranges (e.g., '1-5', '3', '10-')"`
-	ReadHeadings   bool   `json:"readHeadings,omitempty" jsonschema:"return only a list of headings instead of full content"`
+	ReadHeadings   bool   `json:"readHeadings,omitempty" jsonschema:"return a table of contents (section IDs, offsets and sizes) instead of full content"`
+	MaxTokens      int    `json:"maxTokens,omitempty" jsonschema:"approximate token budget for the returned content; output is trimmed at a paragraph boundary and the next startChar is reported"`
+	Find           string `json:"find,omitempty" jsonschema:"search the page for this phrase (case-insensitive) and return only the matching passages with their offsets"`
+	FindRegex      bool   `json:"findRegex,omitempty" jsonschema:"treat find as a Go regular expression instead of a literal phrase"`
+	FindContext    int    `json:"findContext,omitempty" jsonschema:"characters of context to include on each side of a find match (default: 200, max: 2000)"`
+	Query          string `json:"query,omitempty" jsonschema:"return the passages of the page most relevant to this question (BM25 ranking over heading-aware chunks) instead of the full content"`
+	TopK           int    `json:"topK,omitempty" jsonschema:"number of passages to return with query (default: 5, max: 20)"`
+	Cursor         string `json:"cursor,omitempty" jsonschema:"continuation cursor returned by a previous url_read call; fetches the next chunk of the same document (url may be omitted)"`
+}
+
+func NewURLReader(settings URLReaderSettings, cache *Cache, proxyConfig *ProxyConfig) *URLReader {
+	r := &URLReader{cache: cache}
+	r.Reconfigure(settings, proxyConfig)
+	return r
 }
 
-func NewURLReader(cache *Cache, proxyConfig *ProxyConfig) *URLReader {
+// Reconfigure switches to new timeout, size limit and proxy settings.
+// Fetches already in flight finish with the old ones.
+func (r *URLReader) Reconfigure(settings URLReaderSettings, proxyConfig *ProxyConfig) {
 	client := &http.Client{
-		Timeout: 30 * time.Second, // Increased timeout for large pages
+		Timeout: time.Duration(settings.Timeout) * time.Second,
 	}
 
+	var transport http.RoundTripper
 	if proxyConfig != nil && proxyConfig.Transport != nil {
-		client.Transport = proxyConfig.Transport
+		transport = proxyConfig.Transport
 	}
+	client.Transport = countUsage(transport)
 
-	return &URLReader{
-		cache:      cache,
-		httpClient: client,
-	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.httpClient = client
+	r.maxBytes = settings.MaxBytes
+}
+
+// current returns the HTTP client and page size limit in effect.
+func (r *URLReader) current() (*http.Client, int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.httpClient, r.maxBytes
 }
 
 func (r *URLReader) FetchAndConvert(ctx context.Context, urlStr string) (string, error) {
 	// Validate URL
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
-		return "", fmt.Errorf("invalid URL: %w", err)
+		return "", invalidArgument("invalid URL: %v", err)
 	}
 
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return "", fmt.Errorf("URL must use http or https scheme")
+		return "", invalidArgument("URL must use http or https scheme")
 	}
 
-	// Check cache
-	if cached := r.cache.Get(urlStr); cached != "" {
+	// Check cache. Keyed by canonical URL so that tracking-parameter and
+	// http/https/www variants of a page share one entry.
+	cacheKey := canonicalizeURL(urlStr)
+	if cached := r.cache.Get(cacheKey); cached != "" {
 		return cached, nil
 	}
 
@@ -69,33 +100,116 @@ func (r *URLReader) FetchAndConvert(ctx context.Context, urlStr string) (string,
 	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; MCP-SearXNG-Go/1.0)")
 
 	// Execute request
-	resp, err := r.httpClient.Do(req)
+	httpClient, maxBytes := r.current()
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch URL: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		return "", httpStatusError(resp, parsedURL.Host, "")
 	}
 
-	// Read body with size limit (10MB max to prevent memory issues)
-	const maxBodySize = 10 * 1024 * 1024
-	limitedReader := io.LimitReader(resp.Body, maxBodySize)
+	// Only text formats convert to anything useful
+	if contentType := resp.Header.Get("Content-Type"); !isReadableContentType(contentType) {
+		return "", newToolError(ErrUnsupportedType, nil, "unsupported content type %q", contentType)
+	}
+
+	// Read body with size limit (url_reader.max_bytes, 10MB by default)
+	// to prevent memory issues
+	maxBodySize := int64(maxBytes)
+	if resp.ContentLength > maxBodySize {
+		return "", newToolError(ErrTooLarge, nil, "page is %d bytes, over the %d byte limit", resp.ContentLength, maxBodySize)
+	}
+	limitedReader := io.LimitReader(resp.Body, maxBodySize+1)
 	body, err := io.ReadAll(limitedReader)
 	if err != nil {
 		return "", fmt.Errorf("failed to read response: %w", err)
 	}
+	if int64(len(body)) > maxBodySize {
+		return "", newToolError(ErrTooLarge, nil, "page is over the %d byte limit", maxBodySize)
+	}
 
 	// Convert HTML to Markdown (simplified conversion)
 	markdown := htmlToMarkdown(string(body))
 
 	// Cache result
-	r.cache.Set(urlStr, markdown)
+	r.cache.Set(cacheKey, markdown)
 
 	return markdown, nil
 }
 
+// FetchImage downloads an image, such as a search result thumbnail,
+// through the reader's HTTP client so the same proxy settings apply.
+// Images larger than maxBytes or not served as an image are rejected,
+// and failures are classified like url_read's. Images are not cached.
+func (r *URLReader) FetchImage(ctx context.Context, urlStr string, maxBytes int64) ([]byte, string, error) {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, "", invalidArgument("invalid URL: %v", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, "", invalidArgument("URL must use http or https scheme")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; MCP-SearXNG-Go/1.0)")
+	req.Header.Set("Accept", "image/*")
+
+	httpClient, _ := r.current()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", classifyError(fmt.Errorf("failed to fetch image: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", httpStatusError(resp, parsedURL.Host, "")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, "", classifyError(fmt.Errorf("failed to read image: %w", err))
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", newToolError(ErrTooLarge, nil, "image is over the %d byte limit", maxBytes)
+	}
+
+	// Trust the server's type if it says image (sniffing misses SVG),
+	// otherwise sniff in case it's a mislabelled image.
+	mimeType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	mimeType = strings.TrimSpace(strings.ToLower(mimeType))
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, "", newToolError(ErrUnsupportedType, nil, "not an image (%s)", mimeType)
+	}
+
+	return data, mimeType, nil
+}
+
+// isReadableContentType reports whether a response of this type can be
+// converted to Markdown: HTML, XML, JSON and any text/* type. A missing
+// type is given the benefit of the doubt.
+func isReadableContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	switch {
+	case mediaType == "", strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/xhtml+xml", mediaType == "application/xml", mediaType == "application/json":
+		return true
+	case strings.HasSuffix(mediaType, "+xml"), strings.HasSuffix(mediaType, "+json"):
+		return true
+	}
+	return false
+}
+
 func htmlToMarkdown(html string) string {
 	// Simple HTML to Markdown conversion
 	// Remove script and style tags (Go regex doesn't support backreferences like \1)
@@ -147,43 +261,55 @@ func htmlToMarkdown(html string) string {
 	return strings.TrimSpace(content)
 }
 
-func handleURLRead(ctx context.Context, req *mcp.CallToolRequest, reader *URLReader, args URLReadArgs) (result *mcp.CallToolResult, _ any, err error) {
+func handleURLRead(ctx context.Context, req *mcp.CallToolRequest, reader *URLReader, args URLReadArgs) (result *mcp.CallToolResult, out any, err error) {
 	// Add panic recovery to prevent crashes
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("panic in URL read: %v", r)
-			result = &mcp.CallToolResult{
-				IsError: true,
-				Content: []mcp.Content{
-					&mcp.TextContent{Text: fmt.Sprintf("Internal error: %v", r)},
-				},
-			}
+			terr := newToolError(ErrInternal, nil, "panic in URL read: %v", r)
+			result, out, err = toolErrorResult("Internal error", terr), &ErrorOutput{Error: terr}, nil
 		}
 	}()
 
+	// Resume from a continuation cursor
+	var cursor *readCursor
+	if args.Cursor != "" {
+		cursor, err = decodeReadCursor(args.Cursor)
+		if err == nil {
+			args, err = cursor.applyTo(args)
+		}
+		if err != nil {
+			terr := invalidArgument("%v", err)
+			return toolErrorResult("", terr), &ErrorOutput{Error: terr}, nil
+		}
+	}
+
 	// Validate required parameter
 	if args.URL == "" {
-		return &mcp.CallToolResult{
-			IsError: true,
-			Content: []mcp.Content{
-				&mcp.TextContent{Text: "url parameter is required"},
-			},
-		}, nil, nil
+		terr := invalidArgument("url parameter is required")
+		return toolErrorResult("", terr), &ErrorOutput{Error: terr}, nil
+	}
+
+	// Validate find pattern before fetching
+	if args.Find != "" {
+		if _, err := compileFindPattern(args.Find, args.FindRegex); err != nil {
+			terr := invalidArgument("%v", err)
+			return toolErrorResult("", terr), &ErrorOutput{Error: terr}, nil
+		}
 	}
 
 	// Fetch content
 	content, err := reader.FetchAndConvert(ctx, args.URL)
 	if err != nil {
-		return &mcp.CallToolResult{
-			IsError: true,
-			Content: []mcp.Content{
-				&mcp.TextContent{Text: fmt.Sprintf("Failed to read URL: %v", err)},
-			},
-		}, nil, nil
+		terr := classifyError(err)
+		return toolErrorResult("Failed to read URL", terr), &ErrorOutput{Error: terr}, nil
 	}
 
 	// Apply pagination options
-	content = applyPaginationOptions(content, args)
+	content, err = applyPaginationOptions(content, args, cursor)
+	if err != nil {
+		terr := classifyError(err)
+		return toolErrorResult("", terr), &ErrorOutput{Error: terr}, nil
+	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
@@ -192,15 +318,49 @@ func handleURLRead(ctx context.Context, req *mcp.CallToolRequest, reader *URLRea
 	}, nil, nil
 }
 
-func applyPaginationOptions(content string, args URLReadArgs) string {
+func applyPaginationOptions(content string, args URLReadArgs, cursor *readCursor) (string, error) {
 	// Read headings only
 	if args.ReadHeadings {
-		return extractHeadings(content)
+		headings, truncated := truncateToTokenBudget(renderTOC(buildTOC(content)), args.MaxTokens)
+		if truncated {
+			headings += fmt.Sprintf("\n\n---\n*Heading list truncated to fit maxTokens=%d. Raise maxTokens to see the rest.*", args.MaxTokens)
+		}
+		return headings, nil
+	}
+
+	content, err := extractScopedContent(content, args)
+	if err != nil {
+		return "", err
+	}
+
+	// In-page search
+	if args.Find != "" {
+		return findInContent(content, args), nil
+	}
+
+	// Relevance-ranked passages
+	if args.Query != "" {
+		return rankContent(content, args), nil
+	}
+
+	// Character-level pagination
+	if cursor != nil || args.StartChar > 0 || args.MaxLength > 0 || args.MaxTokens > 0 {
+		content = readChunk(content, args, cursor)
 	}
 
+	return content, nil
+}
+
+// extractScopedContent applies the section and paragraph-range selectors,
+// i.e. everything that narrows the document before character pagination.
+func extractScopedContent(content string, args URLReadArgs) (string, error) {
 	// Extract specific section
 	if args.Section != "" {
-		content = extractSection(content, args.Section)
+		section, err := extractSection(content, args.Section)
+		if err != nil {
+			return "", err
+		}
+		content = section
 	}
 
 	// Extract paragraph range
@@ -208,61 +368,104 @@ func applyPaginationOptions(content string, args URLReadArgs) string {
 		content = extractParagraphRange(content, args.ParagraphRange)
 	}
 
-	// Character-level pagination
-	if args.StartChar > 0 || args.MaxLength > 0 {
-		content = applyCharacterPagination(content, args.StartChar, args.MaxLength)
+	return content, nil
+}
+
+// findInContent runs url_read's find option over the scoped content.
+// The pattern has already been validated by handleURLRead.
+func findInContent(content string, args URLReadArgs) string {
+	re, err := compileFindPattern(args.Find, args.FindRegex)
+	if err != nil {
+		return err.Error()
+	}
+
+	contextChars := args.FindContext
+	if contextChars <= 0 {
+		contextChars = defaultFindContext
+	}
+	contextChars = min(contextChars, maxFindContext)
+
+	passages, total := findPassages(content, re, contextChars)
+	output, truncated := truncateToTokenBudget(renderFindResults(args.Find, passages, total), args.MaxTokens)
+	if truncated {
+		output += fmt.Sprintf("\n\n---\n*Truncated to fit maxTokens=%d. Lower findContext or raise maxTokens to see all passages.*", args.MaxTokens)
 	}
 
-	return content
+	return output
 }
 
-func extractHeadings(content string) string {
-	lines := strings.Split(content, "\n")
-	var headings []string
+// rankContent runs url_read's query option over the scoped content.
+func rankContent(content string, args URLReadArgs) string {
+	topK := args.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+	topK = min(topK, maxTopK)
 
-	for _, line := range lines {
-		if strings.HasPrefix(line, "#") {
-			headings = append(headings, line)
-		}
+	passages := chunkPassages(content)
+	ranked := rankPassages(passages, args.Query)
+	output, truncated := truncateToTokenBudget(renderRankedPassages(args.Query, ranked, len(passages), topK), args.MaxTokens)
+	if truncated {
+		output += fmt.Sprintf("\n\n---\n*Truncated to fit maxTokens=%d. Lower topK or raise maxTokens to see all passages.*", args.MaxTokens)
 	}
 
-	return strings.Join(headings, "\n")
+	return output
 }
 
-func extractSection(content, sectionHeading string) string {
-	lines := strings.Split(content, "\n")
-	sectionRegex := regexp.MustCompile(`(?i)^#{1,6}\s*.*` + regexp.QuoteMeta(sectionHeading) + `.*$`)
+// readChunk returns one page of scoped content, starting at the cursor
+// (or startChar) and bounded by maxLength and maxTokens, followed by a
+// footer with the chunk's position and a cursor for the next chunk.
+// Positions are in characters (runes).
+func readChunk(scoped string, args URLReadArgs, cursor *readCursor) string {
+	start := max(args.StartChar, 0)
+	chunkIndex := 1
+	resynced := false
+	if cursor != nil {
+		start, resynced = cursor.locate(scoped)
+		chunkIndex = cursor.Chunk + 1
+	} else if args.MaxLength > 0 {
+		chunkIndex = start/args.MaxLength + 1
+	}
 
-	startIndex := -1
-	currentLevel := 0
+	startByte, endByte := chunkBounds(scoped, start, args.MaxLength)
+	cut := budgetCut(scoped, startByte, endByte, args.MaxTokens)
+	truncated := cut < endByte
+	chunk := scoped[startByte:cut]
 
-	// Find section start
-	for i, line := range lines {
-		if sectionRegex.MatchString(line) {
-			startIndex = i
-			// Count heading level
-			currentLevel = len(strings.Split(line, " ")[0])
-			break
+	total := utf8.RuneCountInString(scoped)
+	start = min(start, total)
+	end := start + utf8.RuneCountInString(chunk)
+
+	footer := fmt.Sprintf("\n\n---\n*Chunk %d · characters %d-%d of %d", chunkIndex, start, end, total)
+	if end < total {
+		footer += " · more remains"
+		if truncated {
+			footer += fmt.Sprintf(" (trimmed to fit maxTokens=%d)", args.MaxTokens)
 		}
+	} else {
+		footer += " · end of document"
 	}
-
-	if startIndex == -1 {
-		return ""
+	footer += "*"
+	if resynced {
+		footer += "\n*The cached page changed since the previous chunk; resumed at the matching text.*"
+	}
+	if end < total {
+		next := nextReadCursor(args, scoped, end, chunkIndex)
+		footer += fmt.Sprintf("\n*Continue with cursor=\"%s\" (or startChar=%d).*", encodeReadCursor(next), end)
 	}
 
-	// Find section end
-	endIndex := len(lines)
-	for i := startIndex + 1; i < len(lines); i++ {
-		if strings.HasPrefix(lines[i], "#") {
-			level := len(strings.Split(lines[i], " ")[0])
-			if level <= currentLevel {
-				endIndex = i
-				break
-			}
-		}
+	return chunk + footer
+}
+
+// extractSection returns the section selected by a section ID, heading
+// path or heading title (see findSection), heading line included.
+func extractSection(content, selector string) (string, error) {
+	section, err := findSection(buildTOC(content), selector)
+	if err != nil {
+		return "", err
 	}
 
-	return strings.Join(lines[startIndex:endIndex], "\n")
+	return strings.TrimRight(content[section.start:section.end], "\n"), nil
 }
 
 func extractParagraphRange(content, rangeStr string) string {
@@ -305,22 +508,28 @@ func extractParagraphRange(content, rangeStr string) string {
 	return strings.Join(filtered[start:end], "\n\n")
 }
 
+// applyCharacterPagination returns maxLength characters (runes, not
+// bytes) of content starting at startChar. A cut that would split a
+// code fence, table, link or multi-byte character is pulled back to the
+// nearest safe boundary, so a chunk may come back slightly shorter.
 func applyCharacterPagination(content string, startChar, maxLength int) string {
-	if startChar >= len(content) {
-		return ""
-	}
+	start, end := chunkBounds(content, startChar, maxLength)
+	return content[start:end]
+}
 
-	if startChar < 0 {
-		startChar = 0
+// chunkBounds is applyCharacterPagination's arithmetic: it returns the
+// byte range of the requested chunk.
+func chunkBounds(content string, startChar, maxLength int) (int, int) {
+	start := byteOffset(content, startChar)
+	if start >= len(content) {
+		return len(content), len(content)
 	}
 
 	end := len(content)
 	if maxLength > 0 {
-		end = startChar + maxLength
-		if end > len(content) {
-			end = len(content)
-		}
+		end = start + byteOffset(content[start:], maxLength)
+		end = snapChunkEnd(content, markdownBlocks(content), start, end)
 	}
 
-	return content[startChar:end]
+	return start, end
 }
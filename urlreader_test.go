@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// paginationDocs are documents mixing prose, structure and multi-byte
+// text. Their fences and tables are short, so any chunk of a few hundred
+// characters has room to keep them whole.
+var paginationDocs = map[string]string{
+	"ascii": strings.Repeat("Plain English prose with a [link to docs](https://example.com/a/b) and `inline code`.\n\n"+
+		"```go\nfmt.Println(1)\n```\n\n| k | v |\n|---|---|\n| a | 1 |\n\n", 12),
+	"cjk": strings.Repeat("日本語の文章です。これはページ分割のテストです。中文的段落也在这里。\n\n"+
+		"```\n表示(値)\n```\n\n| 名前 | 値 |\n|---|---|\n| 甲 | 乙 |\n\n", 12),
+	"emoji": strings.Repeat("Emoji 😀🎉👍🏽 between words, and a flag 🇯🇵 too.\n\n"+
+		"```\n😀 := 1\n```\n\nMixed: 日本 café naïve 😀.\n\n", 12),
+}
+
+var nextStartRegex = regexp.MustCompile(`startChar=(\d+)\)`)
+
+// readAllChunks pages through doc with readChunk, following each chunk's
+// reported startChar, and returns the chunks' text.
+func readAllChunks(t *testing.T, doc string, args URLReadArgs) []string {
+	t.Helper()
+	var chunks []string
+	total := utf8.RuneCountInString(doc)
+	for start := 0; ; {
+		args.StartChar = start
+		out := readChunk(doc, args, nil)
+		text, footer, ok := strings.Cut(out, "\n\n---\n*Chunk ")
+		if !ok {
+			t.Fatalf("no footer in chunk at %d: %q", start, out)
+		}
+		if !strings.Contains(footer, fmt.Sprintf("characters %d-", start)) {
+			t.Fatalf("chunk at %d reports a different start: %q", start, footer)
+		}
+		chunks = append(chunks, text)
+
+		m := nextStartRegex.FindStringSubmatch(footer)
+		if m == nil {
+			if !strings.Contains(footer, "end of document") {
+				t.Fatalf("chunk at %d has no continuation and isn't the last: %q", start, footer)
+			}
+			return chunks
+		}
+		next, _ := strconv.Atoi(m[1])
+		if next <= start {
+			t.Fatalf("no progress: chunk at %d continues at %d", start, next)
+		}
+		if next != start+utf8.RuneCountInString(text) {
+			t.Fatalf("chunk at %d has %d characters but continues at %d", start, utf8.RuneCountInString(text), next)
+		}
+		if next > total || len(chunks) > total {
+			t.Fatalf("runaway pagination at %d", next)
+		}
+		start = next
+	}
+}
+
+func TestPaginationCoversDocument(t *testing.T) {
+	budgets := []URLReadArgs{
+		{MaxLength: 1},
+		{MaxLength: 3},
+		{MaxLength: 17},
+		{MaxLength: 300},
+		{MaxTokens: 1},
+		{MaxTokens: 2},
+		{MaxTokens: 150},
+		{MaxLength: 400, MaxTokens: 60},
+	}
+	for name, doc := range paginationDocs {
+		for _, args := range budgets {
+			t.Run(fmt.Sprintf("%s/len=%d/tokens=%d", name, args.MaxLength, args.MaxTokens), func(t *testing.T) {
+				chunks := readAllChunks(t, doc, args)
+				for i, chunk := range chunks {
+					if chunk == "" {
+						t.Fatalf("chunk %d is empty", i)
+					}
+					if !utf8.ValidString(chunk) {
+						t.Fatalf("chunk %d is not valid UTF-8: %q", i, chunk)
+					}
+				}
+				if got := strings.Join(chunks, ""); got != doc {
+					t.Fatalf("chunks don't reassemble the document: gap or overlap")
+				}
+			})
+		}
+	}
+}
+
+func TestPaginationKeepsBlocksWhole(t *testing.T) {
+	for name, doc := range paginationDocs {
+		blocks := markdownBlocks(doc)
+		if len(blocks) == 0 {
+			t.Fatalf("%s: test document has no blocks", name)
+		}
+		for _, args := range []URLReadArgs{{MaxLength: 300}, {MaxLength: 500}, {MaxTokens: 150}, {MaxTokens: 400}} {
+			t.Run(fmt.Sprintf("%s/len=%d/tokens=%d", name, args.MaxLength, args.MaxTokens), func(t *testing.T) {
+				pos := 0
+				for _, chunk := range readAllChunks(t, doc, args) {
+					pos += len(chunk)
+					for _, b := range blocks {
+						if b.start < pos && pos < b.end {
+							t.Fatalf("chunk ending at byte %d splits block %q", pos, doc[b.start:b.end])
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTinyTokenBudgetEndsOnWords(t *testing.T) {
+	doc := "Internationalization matters. Pagination should never cut a word in half, even with tiny budgets."
+	chunks := readAllChunks(t, doc, URLReadArgs{MaxTokens: 1})
+	pos := 0
+	for _, chunk := range chunks[:len(chunks)-1] {
+		pos += len(chunk)
+		before, _ := utf8.DecodeLastRuneInString(doc[:pos])
+		after, _ := utf8.DecodeRuneInString(doc[pos:])
+		if isWordRune(before) && isWordRune(after) {
+			t.Errorf("chunk %q ends mid-word", chunk)
+		}
+	}
+}
+
+func TestApplyCharacterPagination(t *testing.T) {
+	tests := []struct {
+		content   string
+		startChar int
+		maxLength int
+		want      string
+	}{
+		{"hello world", 0, 0, "hello world"},
+		{"hello world", 6, 0, "world"},
+		{"hello world", 99, 5, ""},
+		{"日本語テキスト", 2, 3, "語テキ"},
+		{"😀😀😀😀", 1, 2, "😀😀"},
+		{"a\n\nbcdefgh", 0, 5, "a\n\n"},
+	}
+	for _, tt := range tests {
+		got := applyCharacterPagination(tt.content, tt.startChar, tt.maxLength)
+		if got != tt.want {
+			t.Errorf("applyCharacterPagination(%q, %d, %d) = %q, want %q", tt.content, tt.startChar, tt.maxLength, got, tt.want)
+		}
+	}
+}
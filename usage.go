@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// QuotaLimits caps one client's usage. 0 means unlimited.
+type QuotaLimits struct {
+	CallsPerMinute    int `yaml:"calls_per_minute" json:"calls_per_minute"`
+	CallsPerDay       int `yaml:"calls_per_day" json:"calls_per_day"`
+	UpstreamPerMinute int `yaml:"upstream_per_minute" json:"upstream_per_minute"`
+	UpstreamPerDay    int `yaml:"upstream_per_day" json:"upstream_per_day"`
+	BytesPerDay       int `yaml:"bytes_per_day" json:"bytes_per_day"`
+}
+
+// QuotaSettings are the default limits for every client, plus limits
+// for particular clients, which replace the defaults entirely.
+type QuotaSettings struct {
+	QuotaLimits `yaml:",inline"`
+	Clients     map[string]QuotaLimits `yaml:"clients"`
+}
+
+// set reports whether any quota is configured.
+func (s QuotaSettings) set() bool {
+	return s.QuotaLimits != (QuotaLimits{}) || len(s.Clients) > 0
+}
+
+// maxTrackedClients bounds the usage table; past it, clients idle since
+// before today are dropped, and if that isn't enough, the least recently
+// seen one.
+const maxTrackedClients = 1000
+
+// usageCounts counts what a client used in some period.
+type usageCounts struct {
+	Calls    int `json:"calls"`
+	Upstream int `json:"upstream_requests"`
+	Bytes    int `json:"bytes_fetched"`
+}
+
+type clientUsage struct {
+	minute, day time.Time // starts of the current windows
+	thisMinute  usageCounts
+	today       usageCounts
+	total       usageCounts
+	rejected    int
+	lastSeen    time.Time
+}
+
+// Usage accounts tool calls, upstream requests (to SearXNG and to the
+// pages url_read fetches) and bytes fetched per client, and enforces
+// per-minute and per-day quotas on them. Days are UTC.
+type Usage struct {
+	mu       sync.Mutex
+	settings QuotaSettings
+	clients  map[string]*clientUsage
+}
+
+func NewUsage(settings QuotaSettings) *Usage {
+	return &Usage{settings: settings, clients: make(map[string]*clientUsage)}
+}
+
+// Reconfigure switches to new limits. Usage so far still counts.
+func (u *Usage) Reconfigure(settings QuotaSettings) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.settings = settings
+}
+
+func (u *Usage) limits(client string) QuotaLimits {
+	if limits, ok := u.settings.Clients[client]; ok {
+		return limits
+	}
+	return u.settings.QuotaLimits
+}
+
+// client returns the usage of client with its windows rolled forward to
+// now, adding it if it's new. The caller holds u.mu.
+func (u *Usage) client(name string, now time.Time) *clientUsage {
+	c, ok := u.clients[name]
+	if !ok {
+		if len(u.clients) >= maxTrackedClients {
+			u.prune(now)
+		}
+		c = &clientUsage{}
+		u.clients[name] = c
+	}
+	c.roll(now)
+	c.lastSeen = now
+	return c
+}
+
+// roll starts new windows if now is past the current ones.
+func (c *clientUsage) roll(now time.Time) {
+	if minute := now.Truncate(time.Minute); !minute.Equal(c.minute) {
+		c.minute, c.thisMinute = minute, usageCounts{}
+	}
+	if day := startOfDay(now); !day.Equal(c.day) {
+		c.day, c.today = day, usageCounts{}
+	}
+}
+
+func (u *Usage) prune(now time.Time) {
+	today := startOfDay(now)
+	var oldest string
+	for name, c := range u.clients {
+		if c.lastSeen.Before(today) {
+			delete(u.clients, name)
+		} else if oldest == "" || c.lastSeen.Before(u.clients[oldest].lastSeen) {
+			oldest = name
+		}
+	}
+	if len(u.clients) >= maxTrackedClients {
+		delete(u.clients, oldest)
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// startCall counts a tool call by client, or returns a quota_exceeded
+// error if it has no calls, upstream requests or bytes left.
+func (u *Usage) startCall(client string) *ToolError {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := time.Now()
+	c := u.client(client, now)
+	limits := u.limits(client)
+
+	terr := c.exceeded(client, limits, now, true)
+	if terr != nil {
+		c.rejected++
+		return terr
+	}
+	c.thisMinute.Calls++
+	c.today.Calls++
+	c.total.Calls++
+	return nil
+}
+
+// startUpstream counts an upstream request made for client, or returns
+// a quota_exceeded error if it has none left.
+func (u *Usage) startUpstream(client string) *ToolError {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := time.Now()
+	c := u.client(client, now)
+
+	if terr := c.exceeded(client, u.limits(client), now, false); terr != nil {
+		return terr
+	}
+	c.thisMinute.Upstream++
+	c.today.Upstream++
+	c.total.Upstream++
+	return nil
+}
+
+func (u *Usage) addBytes(client string, n int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	c := u.client(client, time.Now())
+	c.thisMinute.Bytes += n
+	c.today.Bytes += n
+	c.total.Bytes += n
+}
+
+// exceeded checks the limits a new tool call (or, without call, a new
+// upstream request) would go over. Bytes are checked before a request,
+// so the request that crosses the limit still completes.
+func (c *clientUsage) exceeded(client string, limits QuotaLimits, now time.Time, call bool) *ToolError {
+	minuteReset := c.minute.Add(time.Minute).Sub(now)
+	dayReset := c.day.AddDate(0, 0, 1).Sub(now)
+
+	switch {
+	case call && limits.CallsPerMinute > 0 && c.thisMinute.Calls >= limits.CallsPerMinute:
+		return quotaExceeded(client, limits.CallsPerMinute, "tool calls per minute", minuteReset)
+	case call && limits.CallsPerDay > 0 && c.today.Calls >= limits.CallsPerDay:
+		return quotaExceeded(client, limits.CallsPerDay, "tool calls per day", dayReset)
+	case limits.UpstreamPerMinute > 0 && c.thisMinute.Upstream >= limits.UpstreamPerMinute:
+		return quotaExceeded(client, limits.UpstreamPerMinute, "upstream requests per minute", minuteReset)
+	case limits.UpstreamPerDay > 0 && c.today.Upstream >= limits.UpstreamPerDay:
+		return quotaExceeded(client, limits.UpstreamPerDay, "upstream requests per day", dayReset)
+	case limits.BytesPerDay > 0 && c.today.Bytes >= limits.BytesPerDay:
+		return quotaExceeded(client, limits.BytesPerDay, "bytes fetched per day", dayReset)
+	}
+	return nil
+}
+
+func quotaExceeded(client string, limit int, what string, reset time.Duration) *ToolError {
+	e := newToolError(ErrQuotaExceeded, nil, "client %q has used its quota of %d %s; it resets in %s",
+		client, limit, what, reset.Round(time.Second))
+	e.RetryAfterSeconds = int(math.Ceil(reset.Seconds()))
+	return e
+}
+
+// clientIdentity names the client making req: the subject of its token
+// if it authenticated, else the name it gave in initialize. Clients choose
+// that name themselves, so without authentication quotas only catch
+// accidents: a client can dodge them, or read another's usage, by
+// renaming itself.
+func clientIdentity(req mcp.Request) string {
+	if extra := req.GetExtra(); extra != nil && extra.TokenInfo != nil {
+		if subject, _ := extra.TokenInfo.Extra["subject"].(string); subject != "" {
+			return subject
+		}
+		return "(token without subject)"
+	}
+	if session, ok := req.GetSession().(*mcp.ServerSession); ok {
+		if params := session.InitializeParams(); params != nil && params.ClientInfo != nil && params.ClientInfo.Name != "" {
+			return params.ClientInfo.Name
+		}
+	}
+	return "(anonymous)"
+}
+
+// callUsage travels in the context of a tool call, so the upstream
+// requests it makes are counted against its client.
+type callUsage struct {
+	usage  *Usage
+	client string
+}
+
+type callUsageKey struct{}
+
+// Middleware is MCP middleware that counts each tool call against its
+// client's quotas, rejecting it once they're used up.
+func (u *Usage) Middleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if method != "tools/call" {
+			return next(ctx, method, req)
+		}
+		client := clientIdentity(req)
+		if terr := u.startCall(client); terr != nil {
+			result := toolErrorResult("", terr)
+			result.StructuredContent = &ErrorOutput{Error: terr}
+			return result, nil
+		}
+		ctx = context.WithValue(ctx, callUsageKey{}, &callUsage{usage: u, client: client})
+		return next(ctx, method, req)
+	}
+}
+
+// usageTransport counts the upstream requests tool calls make, and the
+// response bytes they read, against their clients' quotas and in their
+// audit records. Requests made outside a tool call (health checks,
+// /config refreshes) aren't counted.
+type usageTransport struct {
+	base http.RoundTripper
+}
+
+// countUsage wraps base (nil for the default transport) in a
+// usageTransport.
+func countUsage(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &usageTransport{base: base}
+}
+
+func (t *usageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	call, _ := req.Context().Value(callUsageKey{}).(*callUsage)
+	stats, _ := req.Context().Value(callStatsKey{}).(*callStats)
+	if call == nil && stats == nil {
+		return t.base.RoundTrip(req)
+	}
+	if call != nil {
+		if terr := call.usage.startUpstream(call.client); terr != nil {
+			return nil, terr
+		}
+	}
+	if stats != nil {
+		stats.upstream.Add(1)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, call: call, stats: stats}
+	}
+	return resp, err
+}
+
+type countingBody struct {
+	io.ReadCloser
+	call  *callUsage
+	stats *callStats
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.call != nil {
+		b.call.usage.addBytes(b.call.client, n)
+	}
+	if n > 0 && b.stats != nil {
+		b.stats.bytes.Add(int64(n))
+	}
+	return n, err
+}
+
+// ClientUsageReport is one client's row in the usage resource.
+type ClientUsageReport struct {
+	Client        string      `json:"client"`
+	Limits        QuotaLimits `json:"limits"`
+	ThisMinute    usageCounts `json:"this_minute"`
+	Today         usageCounts `json:"today"`
+	Total         usageCounts `json:"total"`
+	RejectedCalls int         `json:"rejected_calls"`
+	LastSeen      time.Time   `json:"last_seen,omitzero"`
+}
+
+// usageAdminScope lets a token see every client's usage; other clients
+// only see their own.
+const usageAdminScope = "usage:admin"
+
+// Report returns the usage of client, or of every client if client is
+// empty, busiest today first. A client that hasn't made a call yet gets
+// a row with its limits.
+func (u *Usage) Report(client string) []ClientUsageReport {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := time.Now()
+
+	if client != "" {
+		report := ClientUsageReport{Client: client, Limits: u.limits(client)}
+		if c, ok := u.clients[client]; ok {
+			c.roll(now)
+			report.ThisMinute, report.Today, report.Total = c.thisMinute, c.today, c.total
+			report.RejectedCalls, report.LastSeen = c.rejected, c.lastSeen
+		}
+		return []ClientUsageReport{report}
+	}
+
+	reports := make([]ClientUsageReport, 0, len(u.clients))
+	for name, c := range u.clients {
+		c.roll(now)
+		reports = append(reports, ClientUsageReport{
+			Client:        name,
+			Limits:        u.limits(name),
+			ThisMinute:    c.thisMinute,
+			Today:         c.today,
+			Total:         c.total,
+			RejectedCalls: c.rejected,
+			LastSeen:      c.lastSeen,
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Today.Calls != reports[j].Today.Calls {
+			return reports[i].Today.Calls > reports[j].Today.Calls
+		}
+		return reports[i].Client < reports[j].Client
+	})
+	return reports
+}
+
+func createUsageResource(usage *Usage, client string) string {
+	status := map[string]interface{}{
+		"day_starts": "00:00 UTC",
+		"clients":    usage.Report(client),
+	}
+	data, _ := json.MarshalIndent(status, "", "  ")
+	return string(data)
+}
+
+func createUsageResourceHandler(usage *Usage) mcp.ResourceHandler {
+	return func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		client := clientIdentity(req)
+		if extra := req.GetExtra(); extra != nil && extra.TokenInfo != nil && slices.Contains(extra.TokenInfo.Scopes, usageAdminScope) {
+			client = ""
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{
+				{
+					URI:      "usage://mcp-searxng",
+					MIMEType: "application/json",
+					Text:     createUsageResource(usage, client),
+				},
+			},
+		}, nil
+	}
+}
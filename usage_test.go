@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestQuotas(t *testing.T) {
+	u := NewUsage(QuotaSettings{
+		QuotaLimits: QuotaLimits{CallsPerDay: 2, UpstreamPerDay: 10},
+		Clients:     map[string]QuotaLimits{"ci": {CallsPerDay: 3}},
+	})
+
+	for i := range 2 {
+		if terr := u.startCall("alice"); terr != nil {
+			t.Fatalf("call %d: %v", i+1, terr)
+		}
+	}
+	terr := u.startCall("alice")
+	if terr == nil || terr.Code != ErrQuotaExceeded || terr.RetryAfterSeconds <= 0 {
+		t.Fatalf("third call = %+v, want quota_exceeded with retryAfterSeconds", terr)
+	}
+	if terr := u.startCall("bob"); terr != nil {
+		t.Errorf("another client's call: %v", terr)
+	}
+
+	// A listed client gets its own limits instead of the defaults, so
+	// ci has no upstream quota
+	for i := range 3 {
+		if terr := u.startCall("ci"); terr != nil {
+			t.Fatalf("ci call %d: %v", i+1, terr)
+		}
+	}
+	for i := range 20 {
+		if terr := u.startUpstream("ci"); terr != nil {
+			t.Fatalf("ci upstream request %d: %v", i+1, terr)
+		}
+	}
+
+	report := u.Report("alice")
+	if len(report) != 1 || report[0].Today.Calls != 2 || report[0].RejectedCalls != 1 {
+		t.Errorf("alice's report = %+v", report)
+	}
+}
+
+func TestUsageResourceShowsOnlyTheCaller(t *testing.T) {
+	u := NewUsage(QuotaSettings{QuotaLimits: QuotaLimits{CallsPerDay: 100}})
+	u.startCall("alice")
+	u.startCall("bob")
+	u.startCall("bob")
+	handler := createUsageResourceHandler(u)
+
+	read := func(subject string, scopes ...string) []ClientUsageReport {
+		t.Helper()
+		req := &mcp.ReadResourceRequest{
+			Params: &mcp.ReadResourceParams{URI: "usage://mcp-searxng"},
+			Extra: &mcp.RequestExtra{TokenInfo: &auth.TokenInfo{
+				Scopes: scopes,
+				Extra:  map[string]any{"subject": subject},
+			}},
+		}
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var status struct {
+			Clients []ClientUsageReport `json:"clients"`
+		}
+		if err := json.Unmarshal([]byte(result.Contents[0].Text), &status); err != nil {
+			t.Fatal(err)
+		}
+		return status.Clients
+	}
+
+	if clients := read("alice", "resources:usage"); len(clients) != 1 || clients[0].Client != "alice" || clients[0].Today.Calls != 1 {
+		t.Errorf("alice sees %+v, want only her own usage", clients)
+	}
+	// A client that hasn't made a call yet still sees its limits
+	if clients := read("carol"); len(clients) != 1 || clients[0].Client != "carol" || clients[0].Limits.CallsPerDay != 100 {
+		t.Errorf("carol sees %+v, want her limits", clients)
+	}
+	if len(u.Report("")) != 2 {
+		t.Error("reading the usage resource added a client")
+	}
+	clients := read("ops", usageAdminScope)
+	if len(clients) != 2 || clients[0].Client != "bob" || clients[1].Client != "alice" {
+		t.Errorf("admin sees %+v, want every client, busiest first", clients)
+	}
+}
+
+func TestUsageTableStaysBounded(t *testing.T) {
+	u := NewUsage(QuotaSettings{})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Clients all active today: the least recently seen is evicted
+	for i := range maxTrackedClients + 50 {
+		u.client(fmt.Sprintf("client-%d", i), now.Add(time.Duration(i)*time.Millisecond))
+		if len(u.clients) > maxTrackedClients {
+			t.Fatalf("%d clients tracked after adding %d", len(u.clients), i+1)
+		}
+	}
+	if _, ok := u.clients["client-0"]; ok {
+		t.Error("the least recently seen client was kept")
+	}
+	if _, ok := u.clients[fmt.Sprintf("client-%d", maxTrackedClients+49)]; !ok {
+		t.Error("the newest client was evicted")
+	}
+
+	// Seeing a client again makes it recent
+	u.client("client-50", now.Add(time.Hour))
+	u.client("newcomer", now.Add(time.Hour))
+	if _, ok := u.clients["client-50"]; !ok {
+		t.Error("a client seen again was evicted")
+	}
+
+	// Tomorrow, everyone idle since yesterday is dropped at once
+	u.client("tomorrow", now.Add(24*time.Hour))
+	u.client("tomorrow-2", now.Add(24*time.Hour))
+	if len(u.clients) != 2 {
+		t.Errorf("%d clients tracked the next day, want 2", len(u.clients))
+	}
+}